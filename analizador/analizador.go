@@ -0,0 +1,375 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Revisa los logs de una corrida: que los logs obligatorios respeten su formato y que la vida de cada
+// proceso sea coherente (transiciones validas en el kernel, un proceso en una sola CPU a la vez).
+// Uso: analizador [-dir carpeta] [-pid N] [archivo.log...]
+func main() {
+	dir := flag.String("dir", ".", "carpeta con kernel.log, cpu-*.log, memoria.log e io_*.log")
+	pid := flag.Int("pid", -1, "imprime la vida del proceso a traves de todos los logs")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Uso: analizador [-dir carpeta] [-pid N] [archivo.log...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	rutas := flag.Args()
+	if len(rutas) == 0 {
+		rutas = archivosDeLog(*dir)
+	}
+	if len(rutas) == 0 {
+		fmt.Fprintf(os.Stderr, "No hay logs en '%s'\n", *dir)
+		os.Exit(2)
+	}
+
+	analisis := nuevoAnalisis()
+	for _, ruta := range rutas {
+		modulo, ok := moduloDeArchivo(ruta)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "%s: no es el log de ningun modulo\n", ruta)
+			os.Exit(2)
+		}
+		registros, err := leerRegistros(ruta, modulo)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		analisis.procesar(registros)
+	}
+	analisis.revisarEjecucionesSimultaneas()
+
+	if *pid >= 0 {
+		analisis.imprimirProceso(*pid)
+		return
+	}
+
+	for _, problema := range analisis.problemas {
+		fmt.Printf("%s:%d: %s\n", problema.archivo, problema.linea, problema.texto)
+	}
+	fmt.Printf("%d archivos, %d logs obligatorios, %d procesos, %d problemas\n",
+		len(rutas), analisis.obligatorios, len(analisis.estadoProceso), len(analisis.problemas))
+	if len(analisis.problemas) > 0 {
+		os.Exit(1)
+	}
+}
+
+// ------ LECTURA ------ //
+type registro struct {
+	archivo   string
+	linea     int
+	modulo    string
+	nucleo    string // CPU que lo logueo, solo en los logs de cpu
+	tiempo    time.Time
+	mensaje   string
+	completo  string // en texto, el mensaje sin separar los atributos del final
+	atributos map[string]string
+	pid       int // -1 si el log no es de un proceso
+}
+
+// Los logs de cada modulo en el orden en que se escribieron: primero los rotados (.N mas alto es el mas viejo)
+func archivosDeLog(dir string) []string {
+	var rutas []string
+	for _, patron := range []string{"kernel.log", "cpu-*.log", "memoria.log", "io_*.log"} {
+		actuales, _ := filepath.Glob(filepath.Join(dir, patron))
+		for _, actual := range actuales {
+			rotados, _ := filepath.Glob(actual + ".*")
+			sort.Slice(rotados, func(i, j int) bool { return numeroDeRotacion(rotados[i]) > numeroDeRotacion(rotados[j]) })
+			rutas = append(append(rutas, rotados...), actual)
+		}
+	}
+	return rutas
+}
+
+func numeroDeRotacion(ruta string) int {
+	numero, _ := strconv.Atoi(strings.TrimPrefix(filepath.Ext(ruta), "."))
+	return numero
+}
+
+var extensionRotacion = regexp.MustCompile(`\.log(\.\d+)?$`)
+
+func moduloDeArchivo(ruta string) (string, bool) {
+	nombre := extensionRotacion.ReplaceAllString(filepath.Base(ruta), "")
+	switch {
+	case nombre == "kernel", nombre == "memoria":
+		return nombre, true
+	case strings.HasPrefix(nombre, "cpu"):
+		return "cpu", true
+	case strings.HasPrefix(nombre, "io_"):
+		return "io", true
+	}
+	return "", false
+}
+
+// "2006/01/02 15:04:05 NIVEL mensaje clave=valor..." del formato text, o una linea del formato json
+var lineaTexto = regexp.MustCompile(`^(\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}) (DEBUG|INFO|WARN|ERROR) (.*)$`)
+var atributoFinal = regexp.MustCompile(` ([a-z_][a-z0-9_.]*)=("(?:[^"\\]|\\.)*"|\S*)$`)
+
+func leerRegistros(ruta string, modulo string) ([]registro, error) {
+	archivo, err := os.Open(ruta)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo abrir el log '%s': %w", ruta, err)
+	}
+	defer archivo.Close()
+
+	nucleo := extensionRotacion.ReplaceAllString(filepath.Base(ruta), "")
+	var registros []registro
+	lector := bufio.NewScanner(archivo)
+	lector.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for numero := 1; lector.Scan(); numero++ {
+		r := registro{archivo: ruta, linea: numero, modulo: modulo, atributos: map[string]string{}, pid: -1}
+		if !parsearLinea(lector.Text(), &r) {
+			continue // continuacion de un mensaje con saltos de linea
+		}
+		if modulo == "cpu" { // el cpu_id solo viene en el formato json, en texto el nucleo es el archivo
+			r.nucleo = nucleo
+			if id, ok := r.atributos["cpu_id"]; ok {
+				r.nucleo = nucleo + "/" + id
+			}
+		}
+		if pid, err := strconv.Atoi(r.atributos["pid"]); err == nil {
+			r.pid = pid
+		}
+		registros = append(registros, r)
+	}
+	return registros, lector.Err()
+}
+
+func parsearLinea(linea string, r *registro) bool {
+	if strings.HasPrefix(linea, "{") {
+		var campos map[string]any
+		if err := json.Unmarshal([]byte(linea), &campos); err != nil {
+			return false
+		}
+		r.tiempo, _ = time.Parse(time.RFC3339Nano, fmt.Sprint(campos["time"]))
+		r.mensaje = fmt.Sprint(campos["msg"])
+		for clave, valor := range campos {
+			if clave != "time" && clave != "level" && clave != "msg" {
+				r.atributos[clave] = fmt.Sprint(valor)
+			}
+		}
+		return true
+	}
+
+	partes := lineaTexto.FindStringSubmatch(linea)
+	if partes == nil {
+		return false
+	}
+	r.tiempo, _ = time.ParseInLocation("2006/01/02 15:04:05", partes[1], time.Local)
+	mensaje := partes[3]
+	r.completo = mensaje
+	for {
+		atributo := atributoFinal.FindStringSubmatchIndex(mensaje)
+		if atributo == nil {
+			break
+		}
+		clave, valor := mensaje[atributo[2]:atributo[3]], mensaje[atributo[4]:atributo[5]]
+		if sinComillas, err := strconv.Unquote(valor); err == nil {
+			valor = sinComillas
+		}
+		r.atributos[clave] = valor
+		mensaje = mensaje[:atributo[0]]
+	}
+	r.mensaje = mensaje
+	return true
+}
+
+// ------ ANALISIS ------ //
+type problema struct {
+	archivo string
+	linea   int
+	texto   string
+}
+
+// Tramo de ejecucion de un proceso en un nucleo, del primer al ultimo Ejecutando
+type rafaga struct {
+	nucleo   string
+	pid      int
+	inicio   time.Time
+	fin      time.Time
+	registro registro
+}
+
+type analisis struct {
+	problemas     []problema
+	obligatorios  int
+	estadoProceso map[int]string
+	registros     []registro
+	rafagaActual  map[string]*rafaga // por nucleo
+	rafagas       []rafaga
+}
+
+func nuevoAnalisis() *analisis {
+	return &analisis{estadoProceso: map[int]string{}, rafagaActual: map[string]*rafaga{}}
+}
+
+func (a *analisis) reportar(r registro, texto string, argumentos ...any) {
+	a.problemas = append(a.problemas, problema{archivo: r.archivo, linea: r.linea, texto: fmt.Sprintf(texto, argumentos...)})
+}
+
+func (a *analisis) procesar(registros []registro) {
+	for _, r := range registros {
+		f, valores, ok := a.revisarFormato(&r)
+		a.registros = append(a.registros, r)
+		if !ok {
+			continue
+		}
+		switch f.evento {
+		case eventoCreacion, eventoTransicion, eventoFinalizacion:
+			a.transicionar(r, f.evento, valores)
+		case eventoEjecucion:
+			a.ejecutar(r, valores[1])
+		case "interrupcion":
+			a.cerrarRafaga(r.nucleo)
+		}
+	}
+	// cada archivo es un nucleo distinto (o varios), las rafagas abiertas terminan con el archivo
+	for nucleo := range a.rafagaActual {
+		a.cerrarRafaga(nucleo)
+	}
+}
+
+// Devuelve el formato del log obligatorio y los valores de sus %d y %s, si el registro es uno
+func (a *analisis) revisarFormato(r *registro) (formato, []string, bool) {
+	for _, f := range formatos {
+		if f.modulo != r.modulo || !strings.Contains(r.mensaje, f.clave) {
+			continue
+		}
+		a.obligatorios++
+		valores := f.patron.FindStringSubmatch(r.mensaje)
+		if valores == nil && r.completo != r.mensaje {
+			// el valor logueado terminaba en algo como clave=valor y no era un atributo (WRITE 0 a=b)
+			if valores = f.patron.FindStringSubmatch(r.completo); valores != nil {
+				r.mensaje = r.completo
+			}
+		}
+		if valores == nil {
+			a.reportar(*r, "log obligatorio '%s' con formato invalido: %q", f.evento, strings.TrimSpace(r.mensaje))
+			return f, nil, false
+		}
+		if len(valores) < 2 { // la interrupcion no trae el PID en el mensaje
+			return f, valores[1:], true
+		}
+		if pid, err := strconv.Atoi(valores[1]); err == nil && r.pid < 0 {
+			r.pid = pid
+		}
+		return f, valores[1:], true
+	}
+	return formato{}, nil, false
+}
+
+func (a *analisis) transicionar(r registro, evento string, valores []string) {
+	pid := r.pid
+	actual, conocido := a.estadoProceso[pid]
+	switch evento {
+	case eventoCreacion:
+		if conocido {
+			a.reportar(r, "el proceso %d se crea de nuevo (estaba en %s)", pid, actual)
+		}
+		a.estadoProceso[pid] = "NEW"
+
+	case eventoTransicion:
+		desde, hasta := valores[1], valores[2]
+		for _, estado := range []string{desde, hasta} {
+			if !estados[estado] {
+				a.reportar(r, "estado desconocido %s", estado)
+				return
+			}
+		}
+		switch {
+		case !conocido:
+			a.reportar(r, "el proceso %d pasa de %s a %s sin haberse creado", pid, desde, hasta)
+		case actual != desde:
+			a.reportar(r, "el proceso %d pasa de %s a %s pero estaba en %s", pid, desde, hasta, actual)
+		case !transicionValida(desde, hasta):
+			a.reportar(r, "transicion invalida del proceso %d: %s -> %s", pid, desde, hasta)
+		}
+		a.estadoProceso[pid] = hasta
+
+	case eventoFinalizacion:
+		if actual == "EXIT" {
+			a.reportar(r, "el proceso %d finaliza dos veces", pid)
+		}
+		a.estadoProceso[pid] = "EXIT"
+	}
+}
+
+// Las syscalls que sacan al proceso de la CPU cierran la rafaga, igual que una interrupcion
+var instruccionesBloqueantes = []string{"IO", "IO_WAIT", "DUMP_MEMORY", "EXIT"}
+
+func (a *analisis) ejecutar(r registro, instruccion string) {
+	actual := a.rafagaActual[r.nucleo]
+	if actual != nil && actual.pid != r.pid {
+		a.cerrarRafaga(r.nucleo)
+		actual = nil
+	}
+	if actual == nil {
+		actual = &rafaga{nucleo: r.nucleo, pid: r.pid, inicio: r.tiempo, registro: r}
+		a.rafagaActual[r.nucleo] = actual
+	}
+	actual.fin = r.tiempo
+	if slices.Contains(instruccionesBloqueantes, instruccion) {
+		a.cerrarRafaga(r.nucleo)
+	}
+}
+
+func (a *analisis) cerrarRafaga(nucleo string) {
+	if actual := a.rafagaActual[nucleo]; actual != nil {
+		a.rafagas = append(a.rafagas, *actual)
+		delete(a.rafagaActual, nucleo)
+	}
+}
+
+// Dos rafagas del mismo proceso en nucleos distintos no se pueden pisar. Con el formato text los
+// tiempos son de a segundo, asi que solo se detectan solapamientos de mas de un segundo, y los
+// nucleos de una misma CPU no se distinguen
+func (a *analisis) revisarEjecucionesSimultaneas() {
+	for i, primera := range a.rafagas {
+		for _, segunda := range a.rafagas[i+1:] {
+			if primera.pid != segunda.pid || primera.nucleo == segunda.nucleo {
+				continue
+			}
+			if primera.inicio.Before(segunda.fin) && segunda.inicio.Before(primera.fin) {
+				posterior := segunda
+				if segunda.inicio.Before(primera.inicio) {
+					posterior = primera
+				}
+				a.reportar(posterior.registro, "el proceso %d ejecuta en %s y %s a la vez (%s - %s y %s - %s)",
+					primera.pid, primera.nucleo, segunda.nucleo,
+					primera.inicio.Format(time.TimeOnly), primera.fin.Format(time.TimeOnly),
+					segunda.inicio.Format(time.TimeOnly), segunda.fin.Format(time.TimeOnly))
+			}
+		}
+	}
+}
+
+func (a *analisis) imprimirProceso(pid int) {
+	var vida []registro
+	for _, r := range a.registros {
+		if r.pid == pid {
+			vida = append(vida, r)
+		}
+	}
+	sort.SliceStable(vida, func(i, j int) bool { return vida[i].tiempo.Before(vida[j].tiempo) })
+	for _, r := range vida {
+		fmt.Printf("%s  %-14s %s\n", r.tiempo.Format("15:04:05.000"), filepath.Base(r.archivo), strings.TrimSpace(r.mensaje))
+	}
+	for _, problema := range a.problemas {
+		if strings.Contains(problema.texto, fmt.Sprintf("proceso %d ", pid)) {
+			fmt.Printf("%s:%d: %s\n", problema.archivo, problema.linea, problema.texto)
+		}
+	}
+}
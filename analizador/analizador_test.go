@@ -0,0 +1,317 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Las lineas de las pruebas salen de corridas reales (las de testdata y las de abajo), algunas con
+// el PID o la hora cambiados para armar el caso
+
+func TestParsearLinea(t *testing.T) {
+	local := func(texto string) time.Time {
+		tiempo, _ := time.ParseInLocation("2006/01/02 15:04:05", texto, time.Local)
+		return tiempo
+	}
+	casos := []struct {
+		nombre    string
+		linea     string
+		valida    bool
+		tiempo    time.Time
+		mensaje   string
+		atributos map[string]string
+	}{
+		{
+			nombre:  "texto obligatorio",
+			linea:   "2026/10/19 13:12:34 INFO ## (0) Pasa del estado NEW al estado READY",
+			valida:  true,
+			tiempo:  local("2026/10/19 13:12:34"),
+			mensaje: "## (0) Pasa del estado NEW al estado READY",
+		},
+		{
+			nombre:    "texto con atributo",
+			linea:     "2026/10/19 13:12:30 INFO Iniciando módulo Memoria puerto=8002",
+			valida:    true,
+			tiempo:    local("2026/10/19 13:12:30"),
+			mensaje:   "Iniciando módulo Memoria",
+			atributos: map[string]string{"puerto": "8002"},
+		},
+		{
+			nombre:    "texto con atributo entre comillas",
+			linea:     `2026/10/19 13:12:30 ERROR Error leyendo trama de 127.0.0.1:40312 motivo="trama de 70000000 bytes supera el maximo"`,
+			valida:    true,
+			tiempo:    local("2026/10/19 13:12:30"),
+			mensaje:   "Error leyendo trama de 127.0.0.1:40312",
+			atributos: map[string]string{"motivo": "trama de 70000000 bytes supera el maximo"},
+		},
+		{
+			nombre:    "json",
+			linea:     `{"time":"2026-10-19T13:14:21.73993357Z","level":"INFO","msg":"## PID: 1 - Ejecutando: NOOP - []","pid":1,"cpu_id":"1-1","instruccion":"NOOP"}`,
+			valida:    true,
+			tiempo:    time.Date(2026, 10, 19, 13, 14, 21, 739933570, time.UTC),
+			mensaje:   "## PID: 1 - Ejecutando: NOOP - []",
+			atributos: map[string]string{"pid": "1", "cpu_id": "1-1", "instruccion": "NOOP"},
+		},
+		{nombre: "continuacion de un mensaje de varias lineas", linea: "Procesos en exit: []"},
+		{nombre: "linea vacia", linea: ""},
+		{nombre: "json roto", linea: `{"time":"2026-10-19T13:14:21.73993357Z","level":"IN`},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			r := registro{atributos: map[string]string{}, pid: -1}
+			if valida := parsearLinea(caso.linea, &r); valida != caso.valida {
+				t.Fatalf("parsearLinea = %t, se esperaba %t", valida, caso.valida)
+			}
+			if !caso.valida {
+				return
+			}
+			if !r.tiempo.Equal(caso.tiempo) {
+				t.Errorf("tiempo = %v, se esperaba %v", r.tiempo, caso.tiempo)
+			}
+			if r.mensaje != caso.mensaje {
+				t.Errorf("mensaje = %q, se esperaba %q", r.mensaje, caso.mensaje)
+			}
+			if len(r.atributos) != len(caso.atributos) {
+				t.Errorf("atributos = %v, se esperaba %v", r.atributos, caso.atributos)
+			}
+			for clave, valor := range caso.atributos {
+				if r.atributos[clave] != valor {
+					t.Errorf("atributo %s = %q, se esperaba %q", clave, r.atributos[clave], valor)
+				}
+			}
+		})
+	}
+}
+
+func TestFormatos(t *testing.T) {
+	casos := []struct {
+		modulo  string
+		linea   string
+		evento  string
+		valores []string
+	}{
+		{"kernel", "2026/10/19 13:12:31 INFO ## (0) Se crea el proceso - Estado: NEW", eventoCreacion, []string{"0"}},
+		{"kernel", "2026/10/19 13:12:34 INFO ## (0) Pasa del estado RUNNING al estado BLOCKED", eventoTransicion, []string{"0", "RUNNING", "BLOCKED"}},
+		{"kernel", "2026/10/19 13:12:34 INFO ## (0) - Solicitó syscall - DUMP MEMORY", "syscall", []string{"0", "DUMP MEMORY"}},
+		{"kernel", "2026/10/19 13:12:34 INFO ## (0) - Bloqueado por IO: DISCO", "bloqueo_io", []string{"0", "DISCO"}},
+		{"kernel", "2026/10/19 13:12:34 INFO ## (0) finalizó IO y pasa a READY", "fin_io", []string{"0"}},
+		{"kernel", "2026/10/19 13:14:22 INFO ## (3) - Desalojado por algoritmo SJF/SRT", "desalojo", []string{"3", "algoritmo SJF/SRT"}},
+		{"kernel", "2026/10/19 13:12:34 INFO ## (0) - Finaliza el proceso ", eventoFinalizacion, []string{"0"}},
+		{"kernel", "2026/10/19 13:12:34 INFO ## (0) - Métricas de estado: NEW (1) (0), READY (3) (0), RUNNING (3) (2), BLOCKED (2) (502), SUSPENDED_BLOCKED (0) (0), SUSPENDED_READY (0) (0), EXIT (1) (0) - Motivo: EXIT", "metricas",
+			[]string{"0", "1", "0", "3", "0", "3", "2", "2", "502", "0", "0", "0", "0", "1", "0", "EXIT"}},
+
+		{"cpu", "2026/10/19 13:13:53 DEBUG ## PID 1 - FETCH - Program Counter: 10", "fetch", []string{"1", "10"}},
+		{"cpu", "2026/10/19 13:12:34 INFO ## PID: 0 - Ejecutando: WRITE - [0 HOLA]", eventoEjecucion, []string{"0", "WRITE", "[0 HOLA]"}},
+		{"cpu", "2026/10/19 13:14:22 INFO ## Llega interrupcion al puerto Interrupt", "interrupcion", []string{}},
+		{"cpu", "2026/10/19 13:07:17 INFO PID: 0 - TLB HIT - Pagina: 3", "tlb_hit", []string{"0", "3"}},
+		{"cpu", "2026/10/19 13:07:17 INFO PID: 0 - TLB MISS - Pagina: 0", "tlb_miss", []string{"0", "0"}},
+		{"cpu", "2026/10/19 13:07:17 INFO PID: 0 - OBTENER MARCO - Pagina: 3 - Marco: 3", "obtener_marco", []string{"0", "3", "3"}},
+		{"cpu", "2026/10/19 13:07:17 INFO PID: 0 - Acción: LEER - Dirección Física: 96 - Valor: LA_SWITCH_2", "acceso", []string{"0", "LEER", "96", "LA_SWITCH_2"}},
+		{"cpu", "2026/10/19 13:12:34 INFO PID: 0 - Acción: ESCRIBIR - Dirección Física: 20 - Valor: a=b", "acceso", []string{"0", "ESCRIBIR", "20", "a=b"}},
+		{"cpu", "2026/10/19 13:07:17 INFO PID: 0 - Cache Hit - Pagina: 1", "cache_hit", []string{"0", "1"}},
+		{"cpu", "2026/10/19 13:07:17 INFO PID: 0 - Cache Miss - Pagina: 3", "cache_miss", []string{"0", "3"}},
+		{"cpu", "2026/10/19 13:07:17 INFO PID: 0 - Cache Add - Pagina: 3", "cache_add", []string{"0", "3"}},
+		{"cpu", "2026/10/19 13:07:17 INFO PID: 0 - Memory Update - Página: 0 - Frame: 0", "memory_update", []string{"0", "0", "0"}},
+
+		{"memoria", "2026/10/19 13:12:34 INFO ## PID: 0 - Proceso Creado - Tamaño: 256", "creacion_memoria", []string{"0", "256"}},
+		{"memoria", "2026/10/19 13:12:34 INFO ## PID: 0 - Proceso Destruido - Métricas - Acc.T.Pag: 7; Inst.Sol.: 2; SWAP: 0; Mem.Prin.: 0; Lec.Mem.: 1; Esc.Mem.: 1", "destruccion_memoria",
+			[]string{"0", "7", "2", "0", "0", "1", "1"}},
+		{"memoria", "2026/10/19 13:12:34 INFO ## PID 0 - Obtener Instruccion: 4 - Instruccion: IO DISCO 500", "obtener_instruccion", []string{"0", "4", "IO DISCO 500"}},
+		{"memoria", "2026/10/19 13:12:34 INFO ## PID: 0 - Lectura - Dir.Física: 0 - Tamaño: 64", "lectura", []string{"0", "0", "64"}},
+		{"memoria", "2026/10/19 13:12:34 INFO ## PID: 0 - Escritura - Dir.Física: 0 - Tamaño: 64", "escritura", []string{"0", "0", "64"}},
+		{"memoria", "2026/10/19 13:12:34 INFO ## PID: 0 - Memory Dump solicitado", "dump", []string{"0"}},
+
+		{"io", "2026/10/19 13:12:34 INFO ## PID: 0 - Inicio de IO - Tiempo: 500", "inicio_io", []string{"0", "500"}},
+		{"io", "2026/10/19 13:12:34 INFO ## PID: 0 - Fin de IO", "fin_io", []string{"0"}},
+	}
+
+	probados := map[string]bool{}
+	for _, caso := range casos {
+		probados[caso.modulo+"/"+caso.evento] = true
+		t.Run(caso.modulo+"/"+caso.evento, func(t *testing.T) {
+			r := registro{modulo: caso.modulo, atributos: map[string]string{}, pid: -1}
+			parsearLinea(caso.linea, &r)
+			a := nuevoAnalisis()
+			f, valores, ok := a.revisarFormato(&r)
+			if !ok || f.evento != caso.evento {
+				t.Fatalf("formato %q (%t), se esperaba %q; problemas: %v", f.evento, ok, caso.evento, a.problemas)
+			}
+			if !slices.Equal(valores, caso.valores) {
+				t.Errorf("valores = %q, se esperaba %q", valores, caso.valores)
+			}
+			if len(caso.valores) > 0 && r.pid < 0 {
+				t.Error("el PID no se tomo del mensaje")
+			}
+		})
+	}
+	for _, f := range formatos {
+		if !probados[f.modulo+"/"+f.evento] {
+			t.Errorf("el formato %s/%s no tiene una linea de prueba", f.modulo, f.evento)
+		}
+	}
+}
+
+func TestFormatosInvalidos(t *testing.T) {
+	casos := []struct {
+		modulo string
+		linea  string
+	}{
+		{"kernel", "2026/10/19 13:12:34 INFO ## (0) Pasa del estado NEW al estado"},
+		{"kernel", "2026/10/19 13:12:34 INFO ## 0 - Bloqueado por IO: DISCO"},
+		{"cpu", "2026/10/19 13:12:34 INFO PID: 0 - TLB HIT - Pagina: tres"},
+		{"memoria", "2026/10/19 13:12:34 INFO ## PID: 0 - Proceso Creado - Tamanio: 256"},
+	}
+	for _, caso := range casos {
+		r := registro{modulo: caso.modulo, atributos: map[string]string{}, pid: -1}
+		parsearLinea(caso.linea, &r)
+		a := nuevoAnalisis()
+		if _, _, ok := a.revisarFormato(&r); ok || len(a.problemas) != 1 {
+			t.Errorf("%q: ok=%t, problemas %v; se esperaba un formato invalido", caso.linea, ok, a.problemas)
+		}
+	}
+
+	// los logs libres que comparten palabras con un obligatorio no se revisan
+	r := registro{modulo: "cpu", atributos: map[string]string{}, pid: -1}
+	parsearLinea("2026/10/19 13:13:50 DEBUG PID: 0 - Acción: EXIT", &r)
+	a := nuevoAnalisis()
+	if _, _, ok := a.revisarFormato(&r); ok || len(a.problemas) != 0 || a.obligatorios != 0 {
+		t.Errorf("un log libre se tomo como obligatorio: %v", a.problemas)
+	}
+}
+
+func TestTransicionValida(t *testing.T) {
+	casos := []struct {
+		desde, hasta string
+		valida       bool
+	}{
+		{"NEW", "READY", true},
+		{"READY", "RUNNING", true},
+		{"RUNNING", "READY", true},
+		{"RUNNING", "BLOCKED", true},
+		{"BLOCKED", "READY", true},
+		{"BLOCKED", "SUSPENDED_BLOCKED", true},
+		{"SUSPENDED_BLOCKED", "SUSPENDED_READY", true},
+		{"SUSPENDED_READY", "READY", true},
+		{"NEW", "EXIT", true},
+		{"SUSPENDED_BLOCKED", "EXIT", true},
+		{"NEW", "RUNNING", false},
+		{"READY", "BLOCKED", false},
+		{"BLOCKED", "RUNNING", false},
+		{"SUSPENDED_BLOCKED", "READY", false},
+		{"SUSPENDED_READY", "RUNNING", false},
+		{"EXIT", "EXIT", false},
+		{"EXIT", "READY", false},
+	}
+	for _, caso := range casos {
+		if valida := transicionValida(caso.desde, caso.hasta); valida != caso.valida {
+			t.Errorf("transicionValida(%s, %s) = %t, se esperaba %t", caso.desde, caso.hasta, valida, caso.valida)
+		}
+	}
+}
+
+// Escribe las lineas en un log de cpu y las lee como el analizador
+func analizarCPU(t *testing.T, lineas ...string) *analisis {
+	t.Helper()
+	ruta := filepath.Join(t.TempDir(), "cpu-1.log")
+	if err := os.WriteFile(ruta, []byte(strings.Join(lineas, "\n")+"\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	registros, err := leerRegistros(ruta, "cpu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := nuevoAnalisis()
+	a.procesar(registros)
+	a.revisarEjecucionesSimultaneas()
+	return a
+}
+
+func TestRevisarEjecucionesSimultaneas(t *testing.T) {
+	t.Run("el mismo proceso en dos nucleos a la vez", func(t *testing.T) {
+		a := analizarCPU(t,
+			`{"time":"2026-10-19T13:14:21.739917516Z","level":"INFO","msg":"## PID: 1 - Ejecutando: NOOP - []","pid":1,"cpu_id":"1-1","instruccion":"NOOP"}`,
+			`{"time":"2026-10-19T13:14:21.73993357Z","level":"INFO","msg":"## PID: 1 - Ejecutando: NOOP - []","pid":1,"cpu_id":"1-0","instruccion":"NOOP"}`,
+			`{"time":"2026-10-19T13:14:21.73994045Z","level":"INFO","msg":"## PID: 1 - Ejecutando: NOOP - []","pid":1,"cpu_id":"1-1","instruccion":"NOOP"}`,
+			`{"time":"2026-10-19T13:14:21.739946785Z","level":"INFO","msg":"## PID: 1 - Ejecutando: NOOP - []","pid":1,"cpu_id":"1-0","instruccion":"NOOP"}`,
+		)
+		if len(a.problemas) != 1 || !strings.Contains(a.problemas[0].texto, "el proceso 1 ejecuta en cpu-1/1-1 y cpu-1/1-0 a la vez") {
+			t.Errorf("problemas = %v", a.problemas)
+		}
+	})
+
+	t.Run("el proceso pasa de un nucleo a otro despues de una interrupcion", func(t *testing.T) {
+		a := analizarCPU(t,
+			`{"time":"2026-10-19T13:14:21.739917516Z","level":"INFO","msg":"## PID: 1 - Ejecutando: NOOP - []","pid":1,"cpu_id":"1-1","instruccion":"NOOP"}`,
+			`{"time":"2026-10-19T13:14:21.73993357Z","level":"INFO","msg":"## PID: 1 - Ejecutando: IO - [DISCO 3000]","pid":1,"cpu_id":"1-1","instruccion":"IO"}`,
+			`{"time":"2026-10-19T13:14:24.74001Z","level":"INFO","msg":"## PID: 1 - Ejecutando: NOOP - []","pid":1,"cpu_id":"1-0","instruccion":"NOOP"}`,
+			`{"time":"2026-10-19T13:14:24.74002Z","level":"INFO","msg":"## Llega interrupcion al puerto Interrupt","pid":1,"cpu_id":"1-0"}`,
+			`{"time":"2026-10-19T13:14:24.74004Z","level":"INFO","msg":"## PID: 1 - Ejecutando: NOOP - []","pid":1,"cpu_id":"1-1","instruccion":"NOOP"}`,
+		)
+		if len(a.problemas) != 0 {
+			t.Errorf("problemas = %v", a.problemas)
+		}
+		if len(a.rafagas) != 3 {
+			t.Errorf("%d rafagas, se esperaban 3", len(a.rafagas))
+		}
+	})
+
+	t.Run("procesos distintos en dos nucleos", func(t *testing.T) {
+		a := analizarCPU(t,
+			`{"time":"2026-10-19T13:14:21.73993357Z","level":"INFO","msg":"## PID: 1 - Ejecutando: NOOP - []","pid":1,"cpu_id":"1-1","instruccion":"NOOP"}`,
+			`{"time":"2026-10-19T13:14:21.740044503Z","level":"INFO","msg":"## PID: 2 - Ejecutando: NOOP - []","pid":2,"cpu_id":"1-0","instruccion":"NOOP"}`,
+			`{"time":"2026-10-19T13:14:21.74005Z","level":"INFO","msg":"## PID: 1 - Ejecutando: NOOP - []","pid":1,"cpu_id":"1-1","instruccion":"NOOP"}`,
+		)
+		if len(a.problemas) != 0 {
+			t.Errorf("problemas = %v", a.problemas)
+		}
+	})
+}
+
+func TestCorridas(t *testing.T) {
+	casos := []struct {
+		dir          string
+		obligatorios int
+		procesos     int
+		problemas    []string
+	}{
+		// DUMP_MEMORY saca al proceso de BLOCKED sin loguear la transicion
+		{"dump", 42, 1, []string{"kernel.log:15: el proceso 0 pasa de READY a RUNNING pero estaba en BLOCKED"}},
+		// CPU con dos nucleos en json y el resto en texto
+		{"dos_nucleos", 125, 6, nil},
+	}
+	for _, caso := range casos {
+		t.Run(caso.dir, func(t *testing.T) {
+			a := nuevoAnalisis()
+			rutas := archivosDeLog(filepath.Join("testdata", caso.dir))
+			if len(rutas) != 4 {
+				t.Fatalf("logs = %v, se esperaban los 4 modulos", rutas)
+			}
+			for _, ruta := range rutas {
+				modulo, _ := moduloDeArchivo(ruta)
+				registros, err := leerRegistros(ruta, modulo)
+				if err != nil {
+					t.Fatal(err)
+				}
+				a.procesar(registros)
+			}
+			a.revisarEjecucionesSimultaneas()
+
+			var problemas []string
+			for _, p := range a.problemas {
+				problemas = append(problemas, fmt.Sprintf("%s:%d: %s", filepath.Base(p.archivo), p.linea, p.texto))
+			}
+			if !slices.Equal(problemas, caso.problemas) {
+				t.Errorf("problemas = %q, se esperaba %q", problemas, caso.problemas)
+			}
+			if a.obligatorios != caso.obligatorios || len(a.estadoProceso) != caso.procesos {
+				t.Errorf("%d logs obligatorios y %d procesos, se esperaban %d y %d", a.obligatorios, len(a.estadoProceso), caso.obligatorios, caso.procesos)
+			}
+		})
+	}
+}
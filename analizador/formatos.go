@@ -0,0 +1,98 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ------ FORMATOS DE LOS LOGS OBLIGATORIOS ------ //
+// Cada formato se escribe igual que el fmt.Sprintf del modulo que lo loguea. Una linea que contiene la
+// clave del formato es ese evento y tiene que respetarlo entero; las que no contienen ninguna clave
+// son logs libres y no se revisan.
+
+type formato struct {
+	modulo string // kernel, cpu, memoria o io
+	evento string
+	clave  string
+	patron *regexp.Regexp
+}
+
+func nuevoFormato(modulo string, evento string, clave string, spec string) formato {
+	patron := regexp.QuoteMeta(spec)
+	patron = strings.NewReplacer("%d", `(-?\d+)`, "%s", `(.*)`, "%v", `(.*)`).Replace(patron)
+	// algunos mensajes terminan con espacios o un salto de linea (parametros vacios, "Finaliza el proceso \n")
+	return formato{modulo: modulo, evento: evento, clave: clave, patron: regexp.MustCompile("^" + patron + `\s*$`)}
+}
+
+// Eventos que usa el analisis de estados
+const (
+	eventoCreacion     = "creacion"
+	eventoTransicion   = "transicion"
+	eventoFinalizacion = "finalizacion"
+	eventoEjecucion    = "ejecucion"
+)
+
+var formatos = []formato{
+	// kernel
+	nuevoFormato("kernel", eventoCreacion, "Se crea el proceso", "## (%d) Se crea el proceso - Estado: NEW"),
+	nuevoFormato("kernel", eventoTransicion, "Pasa de", "## (%d) Pasa del estado %s al estado %s"),
+	nuevoFormato("kernel", "syscall", "Solicitó syscall", "## (%d) - Solicitó syscall - %s"),
+	nuevoFormato("kernel", "bloqueo_io", "Bloqueado por IO:", "## (%d) - Bloqueado por IO: %s"),
+	nuevoFormato("kernel", "fin_io", "finalizó IO y pasa", "## (%d) finalizó IO y pasa a READY"),
+	nuevoFormato("kernel", "desalojo", "Desalojado por", "## (%d) - Desalojado por %s"),
+	nuevoFormato("kernel", eventoFinalizacion, "Finaliza el proceso", "## (%d) - Finaliza el proceso"),
+	nuevoFormato("kernel", "metricas", "Métricas de estado", "## (%d) - Métricas de estado: NEW (%d) (%d), READY (%d) (%d), RUNNING (%d) (%d), BLOCKED (%d) (%d), SUSPENDED_BLOCKED (%d) (%d), SUSPENDED_READY (%d) (%d), EXIT (%d) (%d) - Motivo: %s"),
+
+	// cpu
+	nuevoFormato("cpu", "fetch", "FETCH", "## PID %d - FETCH - Program Counter: %d"),
+	nuevoFormato("cpu", eventoEjecucion, "Ejecutando:", "## PID: %d - Ejecutando: %s - %s"),
+	nuevoFormato("cpu", "interrupcion", "Llega interrupcion", "## Llega interrupcion al puerto Interrupt"),
+	nuevoFormato("cpu", "tlb_hit", "TLB HIT", "PID: %d - TLB HIT - Pagina: %d"),
+	nuevoFormato("cpu", "tlb_miss", "TLB MISS", "PID: %d - TLB MISS - Pagina: %d"),
+	nuevoFormato("cpu", "obtener_marco", "OBTENER MARCO", "PID: %d - OBTENER MARCO - Pagina: %d - Marco: %d"),
+	nuevoFormato("cpu", "acceso", "- Dirección Física:", "PID: %d - Acción: %s - Dirección Física: %d - Valor: %s"),
+	nuevoFormato("cpu", "cache_hit", "Cache Hit", "PID: %d - Cache Hit - Pagina: %d"),
+	nuevoFormato("cpu", "cache_miss", "Cache Miss", "PID: %d - Cache Miss - Pagina: %d"),
+	nuevoFormato("cpu", "cache_add", "Cache Add", "PID: %d - Cache Add - Pagina: %d"),
+	nuevoFormato("cpu", "memory_update", "Memory Update", "PID: %d - Memory Update - Página: %d - Frame: %d"),
+
+	// memoria
+	nuevoFormato("memoria", "creacion_memoria", "Proceso Creado", "## PID: %d - Proceso Creado - Tamaño: %d"),
+	nuevoFormato("memoria", "destruccion_memoria", "Proceso Destruido", "## PID: %d - Proceso Destruido - Métricas - Acc.T.Pag: %d; Inst.Sol.: %d; SWAP: %d; Mem.Prin.: %d; Lec.Mem.: %d; Esc.Mem.: %d"),
+	nuevoFormato("memoria", "obtener_instruccion", "Obtener Instruccion", "## PID %d - Obtener Instruccion: %d - Instruccion: %s"),
+	nuevoFormato("memoria", "lectura", "- Lectura -", "## PID: %d - Lectura - Dir.Física: %d - Tamaño: %d"),
+	nuevoFormato("memoria", "escritura", "- Escritura -", "## PID: %d - Escritura - Dir.Física: %d - Tamaño: %d"),
+	nuevoFormato("memoria", "dump", "Memory Dump", "## PID: %d - Memory Dump solicitado"),
+
+	// io
+	nuevoFormato("io", "inicio_io", "Inicio de IO", "## PID: %d - Inicio de IO - Tiempo: %d"),
+	nuevoFormato("io", "fin_io", "Fin de IO", "## PID: %d - Fin de IO"),
+}
+
+// ------ MAQUINA DE ESTADOS DEL KERNEL ------ //
+var estados = map[string]bool{
+	"NEW": true, "READY": true, "RUNNING": true, "BLOCKED": true,
+	"SUSPENDED_BLOCKED": true, "SUSPENDED_READY": true, "EXIT": true,
+}
+
+// A EXIT se puede llegar desde cualquier estado (finalizacion por error, IO caida...)
+var transicionesValidas = map[string][]string{
+	"NEW":               {"READY"},
+	"READY":             {"RUNNING"},
+	"RUNNING":           {"READY", "BLOCKED"},
+	"BLOCKED":           {"READY", "SUSPENDED_BLOCKED"},
+	"SUSPENDED_BLOCKED": {"SUSPENDED_READY"},
+	"SUSPENDED_READY":   {"READY"},
+}
+
+func transicionValida(desde string, hasta string) bool {
+	if hasta == "EXIT" {
+		return desde != "EXIT"
+	}
+	for _, estado := range transicionesValidas[desde] {
+		if estado == hasta {
+			return true
+		}
+	}
+	return false
+}
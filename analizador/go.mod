@@ -0,0 +1,3 @@
+module analizador
+
+go 1.24
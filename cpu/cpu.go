@@ -1,8 +1,8 @@
 package main
 
 import (
+	"context"
 	"cpu/utils"
-	"encoding/json"
 	"fmt"
 	"globales"
 	"log/slog"
@@ -38,11 +38,7 @@ func main() {
 
 	// ------ LOGGING ------ //
 	// globales.ConfigurarLogger("cpu.log", utils.ClientConfig.LOG_LEVEL) // configurar logger
-	globales.ConfigurarLogger(logFileName, utils.ClientConfig.LOG_LEVEL) // configurar logger
-
-	if utils.ClientConfig == nil {
-		slog.Error("No se pudo crear el config")
-	}
+	globales.ConfigurarLogger(logFileName, utils.ClientConfig.LOG_LEVEL, utils.ClientConfig.ConfigLog) // configurar logger
 
 	// ------ INICIALIZACION DE VARIABLES ------ //
 	puerto := ":" + strconv.Itoa(utils.ClientConfig.PORT_CPU)
@@ -55,10 +51,6 @@ func main() {
 
 	mux := http.NewServeMux()
 
-	// ------ INICIALIZACION DEL SERVIDOR ------ //
-	mux.HandleFunc(fmt.Sprintf("/cpu/%s/ejecutarProceso", utils.IdCpu), utils.EjecutarProceso)
-	mux.HandleFunc(fmt.Sprintf("/cpu/%s/interruptDesalojo", utils.IdCpu), utils.InterrumpirPorDesalojo)
-
 	slog.Info(fmt.Sprintf("El puerto es %s", puerto))
 
 	// ------ INICIALIZACION DEL CLIENTE ------ //
@@ -66,32 +58,40 @@ func main() {
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
 
-	handshakeCPU := globales.HandshakeCPU{
+	globales.ConfigurarRPC(utils.ClientConfig.ConfigRPC)
+	if err := globales.IniciarSpans("cpu-"+utils.IdCpu, utils.ClientConfig.SPANS_FILE); err != nil {
+		slog.Error(err.Error())
+	}
+	utils.RegistrarTransporteMemoria()
+
+	// Memoria nos pasa los datos acerca de la paginacion y se guarda a donde avisar las invalidaciones por ASID
+	handshakeMemoria := globales.HandshakeCPU{
 		ID_CPU:   utils.IdCpu,
-		PORT_CPU: utils.ClientConfig.PORT_CPU, 
+		PORT_CPU: utils.ClientConfig.PORT_CPU,
 		IP_CPU:   utils.ClientConfig.IP_CPU,
 	}
-
-	// Le enviamos un struct que igual no va a usar para que memoria le pase los datos acerca de la paginacion
-
-	_, parametrosMemoriaByte := globales.GenerarYEnviarPaquete(&globales.HandshakeCPU{}, ip_memoria, puerto_memoria, "/cpu/handshake")
-	// globales.GenerarYEnviarPaquete(&mensaje, ip_memoria, puerto_memoria, "/kernel/paqueteKernel")
-
-	var parametrosMemoria globales.ParametrosMemoria
-	err := json.Unmarshal(parametrosMemoriaByte, &parametrosMemoria)
+	// sin los parametros de paginacion la CPU no puede traducir ninguna direccion
+	parametrosMemoria, err := globales.Llamar[globales.ParametrosMemoria](context.Background(), ip_memoria, puerto_memoria, "/cpu/handshake", &handshakeMemoria)
 	if err != nil {
-		slog.Error(fmt.Sprintf("Error al parsear parametros de memoria: %v", err))
-	} else {
-		slog.Info(fmt.Sprintf("Parametros de memoria: %d entradas, %d tamanio pagina, %d niveles", parametrosMemoria.CantidadEntradas, parametrosMemoria.TamanioPagina, parametrosMemoria.CantidadNiveles))
-		utils.TamanioPagina = parametrosMemoria.TamanioPagina
-		utils.CantidadEntradas = parametrosMemoria.CantidadEntradas
-		utils.CantidadNiveles = parametrosMemoria.CantidadNiveles
-
-		for i := range utils.MemoriaCache {
-			utils.MemoriaCache[i].Datos = make([]byte, utils.TamanioPagina)
-		}
+		slog.Error(fmt.Sprintf("No se pudieron obtener los parametros de memoria: %v", err))
+		os.Exit(1)
+	}
+	slog.Info(fmt.Sprintf("Parametros de memoria: %d entradas, %d tamanio pagina, %d niveles", parametrosMemoria.CantidadEntradas, parametrosMemoria.TamanioPagina, parametrosMemoria.CantidadNiveles))
+	utils.TamanioPagina = parametrosMemoria.TamanioPagina
+	utils.CantidadEntradas = parametrosMemoria.CantidadEntradas
+	utils.CantidadNiveles = parametrosMemoria.CantidadNiveles
+
+	utils.InicializarNucleos()
 
+	// ------ INICIALIZACION DEL SERVIDOR ------ //
+	// cada nucleo tiene sus propias rutas, el kernel lo ve como una CPU mas
+	for _, nucleo := range utils.Nucleos {
+		mux.HandleFunc(fmt.Sprintf("/cpu/%s/ejecutarProceso", nucleo.ID), conNucleo(nucleo, utils.EjecutarProceso))
+		mux.HandleFunc(fmt.Sprintf("/cpu/%s/interruptDesalojo", nucleo.ID), conNucleo(nucleo, utils.InterrumpirPorDesalojo))
+		mux.HandleFunc(fmt.Sprintf("/cpu/%s/heartbeat", nucleo.ID), conNucleo(nucleo, utils.AtenderHeartbeat))
+		mux.HandleFunc(fmt.Sprintf("/cpu/%s/debug", nucleo.ID), conNucleo(nucleo, utils.Depurar))
 	}
+	mux.HandleFunc("/memoria/invalidar_asid", utils.InvalidarASID)
 
 	entradas := utils.MMU(4160) // Ejemplo de paginacion
 	desplazamiento := 4160 % utils.TamanioPagina
@@ -100,7 +100,11 @@ func main() {
 
 	go escucharPeticiones(puerto, mux)
 
-	globales.GenerarYEnviarPaquete(&handshakeCPU, ip_kernel, puerto_kernel, "/cpu/handshake")
+	for _, nucleo := range utils.Nucleos {
+		if err := globales.Enviar(context.Background(), ip_kernel, puerto_kernel, "/cpu/handshake", handshakeNucleo(nucleo)); err != nil {
+			slog.Error(fmt.Sprintf("CPU %s - No se pudo hacer el handshake con el kernel: %v", nucleo.ID, err))
+		}
+	}
 
 	//utils.IO("jose", 3000)
 	//utils.INIT_PROC("archivo.txt", 3000)
@@ -110,14 +114,36 @@ func main() {
 	<-sigChan
 
 	slog.Info("Cerrando modulo CPU ...")
+	utils.ReportarEstadisticas()
+	utils.ReportarTransporteMemoria()
+	utils.CerrarTrazas()
 
 	// TODO: Al cerrar el modulo CPU, deberia enviar un mensaje al kernel para que lo elimine de la lista de CPUs activas
-	globales.GenerarYEnviarPaquete(&handshakeCPU, ip_kernel, puerto_kernel, "/cpu/desconectar")
+	for _, nucleo := range utils.Nucleos {
+		if err := globales.Enviar(context.Background(), ip_kernel, puerto_kernel, "/cpu/desconectar", handshakeNucleo(nucleo)); err != nil {
+			slog.Error(fmt.Sprintf("CPU %s - No se pudo avisar la desconexion al kernel: %v", nucleo.ID, err))
+		}
+	}
+	globales.CerrarSpans()
+
+}
+
+func handshakeNucleo(nucleo *utils.Nucleo) *globales.HandshakeCPU {
+	return &globales.HandshakeCPU{
+		ID_CPU:   nucleo.ID,
+		PORT_CPU: utils.ClientConfig.PORT_CPU,
+		IP_CPU:   utils.ClientConfig.IP_CPU,
+	}
+}
 
+func conNucleo(nucleo *utils.Nucleo, handler func(*utils.Nucleo, http.ResponseWriter, *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		handler(nucleo, w, r)
+	}
 }
 
 func escucharPeticiones(puerto string, mux *http.ServeMux) {
-	err := http.ListenAndServe(puerto, mux)
+	err := http.ListenAndServe(puerto, globales.Rastrear(mux))
 	if err != nil {
 		slog.Error(fmt.Sprintf("Error al iniciar el servidor: %s", err.Error()))
 		//panic(err)
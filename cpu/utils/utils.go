@@ -1,16 +1,19 @@
 package utils
 
 import (
-	"bytes"
+	"context"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"globales"
-	"io"
+	"globales/pseudocodigo"
+	"globales/traza"
 	"log"
 	"log/slog"
 	"math"
 	"net/http"
-	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -18,20 +21,17 @@ import (
 )
 
 type EntradaTLB struct {
+	ASID                    int       `json:"asid"`                 // PID dueño de la traduccion
 	NUMERO_PAG              int       `json:"numero_pagina"`        // Número de página
 	NUMERO_MARCO            int       `json:"numero_marco"`         // Número de marco de página
+	SOLO_LECTURA            bool      `json:"solo_lectura"`         // proteccion de la pagina (MPROTECT)
 	TIEMPO_DESDE_REFERENCIA time.Time `json:"tiempo_de_referencia"` // Dirección física del marco de página
 }
 
 // --------- VARIABLES DEL CPU --------- //
 var ClientConfig *Config
 
-var desalojar bool
-var ejecutandoPID int // lo agregamos para poder ejecutar exit y dump_memory
-var ModificarPC bool  // si ejecutamos un GOTO o un IO, no incrementamos el PC
-var PC int
 var IdCpu string
-var dejarDeEjecutar bool
 
 var TamanioPagina int
 var CantidadEntradas int
@@ -43,21 +43,103 @@ var tlbHabilitada bool = false
 var algoritmoTLB string   // FIFO o LRU
 var algoritmoCache string // CLOCK o CLOCK-M
 
-var TLB []EntradaTLB
 
-var MemoriaCache []EntradaCache // Cache de memoria
+var icacheHabilitada bool = false
+
+// Contexto de ejecucion de un nucleo. El modulo levanta CORES nucleos y cada uno se registra en el kernel como una CPU
+type Nucleo struct {
+	ID              string // IdCpu si hay un solo nucleo, IdCpu-N si hay varios
+	PC              int
+	Registros       globales.Registros // registros de proposito general del proceso en ejecucion
+	ejecutandoPID   int                // lo agregamos para poder ejecutar exit y dump_memory
+	tamanioProceso  int                // las direcciones validas del proceso van de 0 a tamanioProceso-1
+	dejarDeEjecutar bool
+	ModificarPC     bool // si ejecutamos un GOTO o un IO, no incrementamos el PC
+	TLB             []EntradaTLB
+	Cache           *CacheDatos     // propia del nucleo o compartida por todos (CACHE_SHARED)
+	ICache          []EntradaICache // cache de instrucciones, se conserva entre rafagas porque la clave incluye el PID
+	mutexEjecucion  sync.Mutex
+	Estadisticas    Estadisticas
+
+	// invalidaciones de memoria que se aplican al empezar el proximo ciclo, asi no hace falta
+	// tomar mutexEjecucion (que puede estar tomado esperando al kernel)
+	invalidacionesPendientes []globales.InvalidacionASID
+	mutexInvalidaciones      sync.Mutex
+
+	// controlador de interrupciones: se encolan desde los handlers y el timer, y se atienden en CHECK_INTERRUPT
+	interrupciones      []interrupcionPendiente
+	mutexInterrupciones sync.Mutex
+	timerQuantum        *time.Timer
+
+	// depurador: el nucleo se detiene antes de ejecutar una instruccion con breakpoint y espera un PASO o CONTINUAR
+	breakpoints         map[int]map[int]bool // PID -> PC
+	modoPaso            bool                 // detenerse en la proxima instruccion
+	detenido            bool
+	instruccionDetenida string
+	pidDetenido         int // PID y PC al detenerse, copiados con mutexEjecucion tomado
+	pcDetenido          int
+	reanudar            chan bool // true = PASO, false = CONTINUAR
+	mutexDepurador      sync.Mutex
+
+	traza  *traza.Escritor // nil si no se graba (TRACE_DIR vacio)
+	acceso traza.Evento    // acceso a memoria en curso, lo completan la traduccion y la cache
+}
+
+type interrupcionPendiente struct {
+	tipo      string // uno de los globales.Interrupcion*
+	pid       int
+	excepcion *globales.Excepcion // solo para los fallos
+}
+
+// Menor valor = se atiende primero
+var prioridadInterrupcion = map[string]int{
+	globales.InterrupcionFallo:    0,
+	globales.InterrupcionQuantum:  1,
+	globales.InterrupcionDesalojo: 2,
+	globales.InterrupcionFinIO:    3,
+}
+
+// Aciertos de TLB y cache de paginas del nucleo, para comparar con y sin ASIDs
+type Estadisticas struct {
+	TLB_HITS     int
+	TLB_MISSES   int
+	CACHE_HITS   int
+	CACHE_MISSES int
+
+	DESALOJOS_SUCIOS       int // paginas modificadas que se bajaron a memoria al reemplazarlas
+	ESCRITURAS_PAGINA      int // pedidos a /cpu/escribir_pagina
+	ESCRITURAS_DIRECCION   int // pedidos a /cpu/escribir_direccion (sin cache o sin write-allocate)
+	BYTES_ESCRITOS_MEMORIA int
+}
 
-var punteroMemoriaCache int // Puntero para la cache, para saber donde escribir la proxima entrada
+// Cache de paginas. Si es compartida, el mutex serializa los accesos de los distintos nucleos
+type CacheDatos struct {
+	Entradas []EntradaCache
+	puntero  int // Puntero para la cache, para saber donde escribir la proxima entrada
+	mutex    sync.Mutex
+}
+
+var Nucleos []*Nucleo
 
-var mutexEjecucion sync.Mutex
+type EntradaICache struct {
+	PID                     int
+	PC                      int
+	Instruccion             string
+	TIEMPO_DESDE_REFERENCIA time.Time
+}
 
 type EntradaCache struct {
+	asid           int // PID dueño de la pagina, la cache puede tener paginas de distintos procesos
 	nroPagina      int
 	Datos          []byte
 	nroMarco       int // para facilitar la traduccion de direccion logica a fisica
 	bitDeUso       bool
 	bitModificado  bool
 	entradaOcupada bool
+	soloLectura    bool
+	tiempoCarga    time.Time // FIFO
+	tiempoUso      time.Time // LRU
+	usos           int       // LFU
 }
 
 // --------- ESTRUCTURAS DEL CPU --------- //
@@ -74,19 +156,60 @@ type Config struct {
 	CACHE_REPLACEMENT string `json:"cache_replacement"`
 	CACHE_DELAY       int    `json:"cache_delay"`
 	LOG_LEVEL         string `json:"log_level"`
+
+	ICACHE_ENTRIES     int    `json:"icache_entries"`     // 0 = sin cache de instrucciones
+	ICACHE_REPLACEMENT string `json:"icache_replacement"` // FIFO o LRU
+	ICACHE_PREFETCH    int    `json:"icache_prefetch"`    // instrucciones siguientes que se traen junto con un miss
+
+	CORES        int  `json:"cores"`        // nucleos que levanta el modulo, por defecto 1
+	CACHE_SHARED bool `json:"cache_shared"` // una sola cache de paginas para todos los nucleos; si no, cada uno tiene la suya con coherencia MSI
+
+	ASIDS bool `json:"asids"` // TLB y cache etiquetadas por PID: no se vacian en cada cambio de contexto, las invalida memoria
+
+	CACHE_WRITE_POLICY   string `json:"cache_write_policy"`   // write-back (por defecto) o write-through
+	CACHE_WRITE_ALLOCATE bool   `json:"cache_write_allocate"` // si es false, un miss de escritura va directo a memoria. Por defecto true
+
+	QUANTUM int `json:"quantum"` // ms que puede ejecutar un proceso antes de la interrupcion de timer, 0 = sin timer
+
+	TRACE_DIR string `json:"trace_dir"` // si no esta vacio, cada nucleo graba su traza en TRACE_DIR/cpu-<id>.trace
+
+	MEMORY_TRANSPORT string `json:"memory_transport"` // http (por defecto) o tcp
+	MEMORY_TCP_PORT  int    `json:"memory_tcp_port"`  // puerto TCP_PORT de memoria, para el transporte tcp
+
+	globales.ConfigRPC // rpc_timeout, rpc_retries, rpc_backoff y spans_file
+	globales.ConfigLog // log_format, log_stdout, log_max_size, log_max_age y log_max_files
 }
 
 // --------- INICIALIZACION DEL MODULO --------- //
+// Lo que tiene que cumplir el config para que la CPU arranque, con los defaults de los campos opcionales
+var esquemaConfig = globales.Esquema{
+	"port_cpu":             {Obligatorio: true, Rango: []float64{1, 65535}},
+	"ip_cpu":               {Obligatorio: true},
+	"port_memory":          {Obligatorio: true, Rango: []float64{1, 65535}},
+	"ip_memory":            {Obligatorio: true},
+	"port_kernel":          {Obligatorio: true, Rango: []float64{1, 65535}},
+	"ip_kernel":            {Obligatorio: true},
+	"tlb_entries":          {Rango: []float64{0}},
+	"tlb_replacement":      {Valores: []string{"FIFO", "LRU"}},
+	"cache_entries":        {Rango: []float64{0}},
+	"cache_replacement":    {Valores: []string{"CLOCK", "CLOCK-M", "FIFO", "LRU", "LFU"}},
+	"cache_delay":          {Rango: []float64{0}},
+	"icache_entries":       {Rango: []float64{0}},
+	"icache_replacement":   {Valores: []string{"FIFO", "LRU"}},
+	"icache_prefetch":      {Rango: []float64{0}},
+	"cores":                {Defecto: 1, Rango: []float64{1}},
+	"cache_write_policy":   {Defecto: "write-back", Valores: []string{"write-back", "write-through"}},
+	"cache_write_allocate": {Defecto: true},
+	"quantum":              {Rango: []float64{0}},
+	"memory_transport":     {Defecto: "http", Valores: []string{"http", "tcp"}},
+	"memory_tcp_port":      {Rango: []float64{0, 65535}},
+}
+
 func IniciarConfiguracion(filePath string) *Config {
-	var config *Config
-	configFile, err := os.Open(filePath)
-	if err != nil {
+	config := &Config{}
+	if err := globales.CargarConfiguracion("cpu", filePath, config, esquemaConfig); err != nil {
 		log.Fatal(err.Error())
 	}
-	defer configFile.Close()
-
-	jsonParser := json.NewDecoder(configFile)
-	jsonParser.Decode(&config)
 
 	slog.Debug(fmt.Sprintf("Configuración cargada: %+v", *config))
 
@@ -95,88 +218,148 @@ func IniciarConfiguracion(filePath string) *Config {
 
 	if config.CACHE_ENTRIES > 0 {
 		cacheHabilitada = true
-		MemoriaCache = make([]EntradaCache, config.CACHE_ENTRIES)
-		for i := range MemoriaCache {
-			MemoriaCache[i] = EntradaCache{
-				nroPagina:      -1, // Inicializamos con -1 para indicar que no hay pagina cargada
-				Datos:          nil,
-				bitDeUso:       false,
-				bitModificado:  false,
-				entradaOcupada: false,
-			}
-		}
-
-		punteroMemoriaCache = 0 // Inicializamos el puntero de la cache
 	}
 
 	if config.TLB_ENTRIES > 0 {
 		tlbHabilitada = true
 	}
 
+	if config.ICACHE_ENTRIES > 0 {
+		icacheHabilitada = true
+	}
+
 	slog.Debug(fmt.Sprintf("%v", tlbHabilitada))
 
 	return config
 }
 
+// Crea los nucleos del modulo. Se llama despues del handshake con memoria porque la cache necesita el tamaño de pagina
+func InicializarNucleos() {
+	cantidadNucleos := max(ClientConfig.CORES, 1)
+
+	var cacheCompartida *CacheDatos
+	if ClientConfig.CACHE_SHARED {
+		cacheCompartida = nuevaCacheDatos()
+	}
+
+	Nucleos = make([]*Nucleo, cantidadNucleos)
+	for i := range Nucleos {
+		nucleo := &Nucleo{ID: IdCpu, Cache: cacheCompartida, breakpoints: make(map[int]map[int]bool), reanudar: make(chan bool)}
+		if cantidadNucleos > 1 {
+			nucleo.ID = fmt.Sprintf("%s-%d", IdCpu, i)
+		}
+		if nucleo.Cache == nil {
+			nucleo.Cache = nuevaCacheDatos()
+		}
+		if icacheHabilitada {
+			nucleo.ICache = make([]EntradaICache, 0, ClientConfig.ICACHE_ENTRIES)
+		}
+		if ClientConfig.TRACE_DIR != "" {
+			escritor, err := traza.Crear(filepath.Join(ClientConfig.TRACE_DIR, fmt.Sprintf("cpu-%s.trace", nucleo.ID)), TamanioPagina)
+			if err != nil {
+				slog.Error(err.Error())
+			}
+			nucleo.traza = escritor
+		}
+		Nucleos[i] = nucleo
+	}
+
+	slog.Debug(fmt.Sprintf("Nucleos inicializados: %d, cache compartida: %t", cantidadNucleos, ClientConfig.CACHE_SHARED))
+}
+
+func nuevaCacheDatos() *CacheDatos {
+	cache := &CacheDatos{}
+	if cacheHabilitada {
+		cache.Entradas = make([]EntradaCache, ClientConfig.CACHE_ENTRIES)
+		for i := range cache.Entradas {
+			cache.Entradas[i] = entradaCacheVacia()
+		}
+	}
+	return cache
+}
+
 // --------- CICLO DE INSTRUCCIÓN --------- //
-func EjecutarProceso(w http.ResponseWriter, r *http.Request) {
+func EjecutarProceso(nucleo *Nucleo, w http.ResponseWriter, r *http.Request) {
 
-	desalojar = false
-	dejarDeEjecutar = false
+	nucleo.dejarDeEjecutar = false
 
 	paquete := globales.ProcesoAEjecutar{}
 	paquete = globales.DecodificarPaquete(w, r, &paquete)
 
 	// Aqui se ejecuta el proceso
 	// slog.Info(fmt.Sprintf("Ejecutando proceso con PID: %d", paquete.PID))
-	ejecutandoPID = paquete.PID
+	nucleo.mutexEjecucion.Lock() // el depurador los lee en cualquier momento
+	nucleo.ejecutandoPID = paquete.PID
+	nucleo.PC = paquete.PC
+	nucleo.Registros = paquete.REGISTROS
+	nucleo.tamanioProceso = paquete.TAMANIO
+	nucleo.mutexEjecucion.Unlock()
 
-	PC = paquete.PC
+	slog.Debug(fmt.Sprintf("CPU %s ejecutando PID %d en PC %d", nucleo.ID, paquete.PID, paquete.PC))
 
-	slog.Debug(fmt.Sprintf("CPU %s ejecutando PID %d en PC %d", IdCpu, paquete.PID, paquete.PC))
+	descartarInterrupciones(nucleo) // las que hayan quedado de la rafaga anterior
+	iniciarTimerQuantum(nucleo, paquete.PID)
+	nucleo.traza.Registrar(traza.Evento{Tipo: traza.InicioRafaga, PID: paquete.PID, PC: nucleo.PC})
 
-	for !desalojar && !dejarDeEjecutar {
+	var interrupcion *interrupcionPendiente
+	for !nucleo.dejarDeEjecutar && interrupcion == nil {
 		// time.Sleep(100 * time.Millisecond)
-		mutexEjecucion.Lock()
-		ModificarPC = true // por defecto incrementamos el PC
+		nucleo.mutexEjecucion.Lock()
+		aplicarInvalidaciones(nucleo)
+		nucleo.ModificarPC = true // por defecto incrementamos el PC
 
-		slog.Debug(fmt.Sprintf("## PID %d - FETCH - Program Counter: %d", paquete.PID, PC)) // log obligatorio
+		slog.Debug(fmt.Sprintf("## PID %d - FETCH - Program Counter: %d", paquete.PID, nucleo.PC), "pid", paquete.PID, "pc", nucleo.PC, "cpu_id", nucleo.ID) // log obligatorio
 		// FASE FETCH
-		instruccion := buscarInstruccion(paquete.PID, PC) // Buscar instruccion a memoria con el PC del proeso
+		instruccion, err := buscarInstruccion(nucleo, paquete.PID, nucleo.PC) // Buscar instruccion a memoria con el PC del proeso
+		if err != nil {
+			errorDeMemoria(nucleo, err)
+		} else {
+			registrarInstruccion(nucleo, instruccion)
+			esperarDepurador(nucleo, instruccion)
 
-		// DECODE y EXECUTE
-		DecodeAndExecute(instruccion)
-		if ModificarPC { // el if es por si ejecuta GOTO
-			PC++
+			// DECODE y EXECUTE
+			DecodeAndExecute(nucleo, instruccion)
+			if nucleo.ModificarPC { // el if es por si ejecuta GOTO
+				nucleo.PC++
+			}
 		}
-		mutexEjecucion.Unlock()
-	}
 
-	// CHECK_INTERRUPT
-	if desalojar && !dejarDeEjecutar {
-		procesoInterrumpido := globales.Interrupcion{
-			PID: ejecutandoPID,
-			PC:  PC,
+		// CHECK_INTERRUPT
+		if nucleo.dejarDeEjecutar {
+			descartarInterrupciones(nucleo) // el proceso ya se fue al kernel por una syscall
+		} else {
+			interrupcion = siguienteInterrupcion(nucleo)
 		}
-		slog.Debug("ENVIANDO PROCESO INTERRUMPIDO")
-		globales.GenerarYEnviarPaquete(&procesoInterrumpido, ClientConfig.IP_KERNEL, ClientConfig.PORT_KERNEL, "/cpu/interrupt")
+		nucleo.mutexEjecucion.Unlock()
+	}
+
+	detenerTimerQuantum(nucleo)
+	if interrupcion != nil {
+		atenderInterrupcion(nucleo, interrupcion)
 	}
 
 	handshakeCPU := globales.HandshakeCPU{
-		ID_CPU:   IdCpu,
+		ID_CPU:   nucleo.ID,
 		PORT_CPU: ClientConfig.PORT_CPU,
 		IP_CPU:   ClientConfig.IP_CPU,
 		//DISPONIBLE: nil,
 	}
 
-	slog.Debug(fmt.Sprintf("Desalojar proceso: %t, dejar de ejecutar: %t", desalojar, dejarDeEjecutar))
+	slog.Debug(fmt.Sprintf("Interrumpido: %t, dejar de ejecutar: %t", interrupcion != nil, nucleo.dejarDeEjecutar))
 
-	slog.Debug(fmt.Sprintf("Entradas TLB: %v", TLB))
-	EliminarEntradasTLB()
-	limpiarCache()
+	slog.Debug(fmt.Sprintf("Entradas TLB: %v", nucleo.TLB))
+	if !ClientConfig.ASIDS {
+		EliminarEntradasTLB(nucleo)
+		limpiarCache(nucleo)
+	} else {
+		// con ASIDs las entradas sobreviven a la rafaga y las invalida memoria, pero las modificadas se bajan
+		// igual: el proceso puede volver a ejecutar en otro modulo CPU, que no ve esta cache
+		escribirPaginasModificadas(nucleo)
+	}
+	nucleo.traza.Registrar(traza.Evento{Tipo: traza.FinRafaga, PID: paquete.PID, PC: nucleo.PC})
 
 	slog.Debug("RECONECTANDOME CON KERNEL")
-	go globales.GenerarYEnviarPaquete(&handshakeCPU, ClientConfig.IP_KERNEL, ClientConfig.PORT_KERNEL, "/cpu/handshake")
+	go enviarAlKernel(nucleo.ejecutandoPID, "/cpu/handshake", &handshakeCPU)
 	slog.Debug("RECONECTADO CON KERNEL")
 
 	w.WriteHeader(http.StatusOK)
@@ -184,31 +367,107 @@ func EjecutarProceso(w http.ResponseWriter, r *http.Request) {
 
 }
 
-func buscarInstruccion(pid int, pc int) string {
+func buscarInstruccion(nucleo *Nucleo, pid int, pc int) (string, error) {
+	if icacheHabilitada {
+		return buscarInstruccionEnICache(nucleo, pid, pc)
+	}
+
 	pedidoInstruccion := globales.PeticionInstruccion{
 		PC:  pc,
 		PID: pid,
 	}
 	tiempoAntes := time.Now()
 	// Enviar pedido a memoria
-	_, respBody := globales.GenerarYEnviarPaquete(&pedidoInstruccion, ClientConfig.IP_MEMORY, ClientConfig.PORT_MEMORY, "/cpu/buscar_instruccion")
+	instruccion, err := globales.Llamar[string](context.Background(), ClientConfig.IP_MEMORY, ClientConfig.PORT_MEMORY, "/cpu/buscar_instruccion", &pedidoInstruccion)
+	if err != nil {
+		return "", err
+	}
 	tiempoEnBusquedaInstruccion := time.Since(tiempoAntes).Milliseconds()
 	slog.Debug(fmt.Sprintf("Instruccion recibida de la memoria. Tiempo de retardo: %d", tiempoEnBusquedaInstruccion))
-	// Convertir los bytes del cuerpo a un string.
-	bodyString := string(respBody)
-	var instruccion string
+	return instruccion, nil
+}
+
+// --------- CACHE DE INSTRUCCIONES --------- //
+func buscarInstruccionEnICache(nucleo *Nucleo, pid int, pc int) (string, error) {
+	for i := range nucleo.ICache {
+		if nucleo.ICache[i].PID == pid && nucleo.ICache[i].PC == pc {
+			slog.Info(fmt.Sprintf("PID: %d - ICACHE HIT - PC: %d", pid, pc))
+			nucleo.ICache[i].TIEMPO_DESDE_REFERENCIA = time.Now()
+			return nucleo.ICache[i].Instruccion, nil
+		}
+	}
+
+	slog.Info(fmt.Sprintf("PID: %d - ICACHE MISS - PC: %d", pid, pc))
+
+	// traigo la instruccion pedida y las siguientes en un solo pedido
+	pedido := globales.PeticionInstrucciones{
+		PC:       pc,
+		PID:      pid,
+		CANTIDAD: 1 + max(ClientConfig.ICACHE_PREFETCH, 0),
+	}
+	tiempoAntes := time.Now()
+	instrucciones, err := globales.Llamar[[]string](context.Background(), ClientConfig.IP_MEMORY, ClientConfig.PORT_MEMORY, "/cpu/buscar_instrucciones", &pedido)
+	if err != nil {
+		return "", err
+	}
+	if len(instrucciones) == 0 {
+		return "", fmt.Errorf("memoria no devolvio la instruccion del PC %d", pc)
+	}
+	slog.Debug(fmt.Sprintf("Instrucciones recibidas de la memoria. Tiempo de retardo: %d", time.Since(tiempoAntes).Milliseconds()))
 
-	json.Unmarshal([]byte(bodyString), &instruccion)
-	return instruccion
+	for i, instruccion := range instrucciones {
+		guardarEnICache(nucleo, pid, pc+i, instruccion)
+	}
+	return instrucciones[0], nil
+}
+
+func guardarEnICache(nucleo *Nucleo, pid int, pc int, instruccion string) {
+	nuevaEntrada := EntradaICache{
+		PID:                     pid,
+		PC:                      pc,
+		Instruccion:             instruccion,
+		TIEMPO_DESDE_REFERENCIA: time.Now(),
+	}
+
+	for i := range nucleo.ICache {
+		if nucleo.ICache[i].PID == pid && nucleo.ICache[i].PC == pc {
+			nucleo.ICache[i] = nuevaEntrada // ya estaba (por un prefetch anterior)
+			return
+		}
+	}
+
+	if len(nucleo.ICache) < ClientConfig.ICACHE_ENTRIES {
+		nucleo.ICache = append(nucleo.ICache, nuevaEntrada)
+		return
+	}
+
+	if ClientConfig.ICACHE_REPLACEMENT == "LRU" {
+		indiceMenosUsado := 0
+		for i, entrada := range nucleo.ICache {
+			if entrada.TIEMPO_DESDE_REFERENCIA.Before(nucleo.ICache[indiceMenosUsado].TIEMPO_DESDE_REFERENCIA) {
+				indiceMenosUsado = i
+			}
+		}
+		slog.Debug(fmt.Sprintf("Reemplazando entrada ICACHE por LRU: PID %d, PC %d", nucleo.ICache[indiceMenosUsado].PID, nucleo.ICache[indiceMenosUsado].PC))
+		nucleo.ICache[indiceMenosUsado] = nuevaEntrada
+	} else { // FIFO: la primera entrada es la mas vieja
+		slog.Debug(fmt.Sprintf("Reemplazando entrada ICACHE por FIFO: PID %d, PC %d", nucleo.ICache[0].PID, nucleo.ICache[0].PC))
+		nucleo.ICache = append(nucleo.ICache[1:], nuevaEntrada)
+	}
 }
 
-func DecodeAndExecute(instruccion string) {
+func DecodeAndExecute(nucleo *Nucleo, instruccion string) {
 	sliceInstruccion := strings.Split(instruccion, " ")
 
 	nombreInstruccion := sliceInstruccion[0]
 	parametros := sliceInstruccion[1:]
 
-	slog.Info(fmt.Sprintf("## PID: %d - Ejecutando: %s - %s", ejecutandoPID, nombreInstruccion, parametros)) // log obligatorio
+	slog.Info(fmt.Sprintf("## PID: %d - Ejecutando: %s - %s", nucleo.ejecutandoPID, nombreInstruccion, parametros), "pid", nucleo.ejecutandoPID, "cpu_id", nucleo.ID, "instruccion", nombreInstruccion) // log obligatorio
+
+	if err := pseudocodigo.ValidarInstruccion(instruccion); err != nil {
+		lanzarExcepcion(nucleo, globales.ExcepcionInstruccionInvalida, err.Error())
+		return
+	}
 
 	switch nombreInstruccion {
 	case "NOOP":
@@ -216,76 +475,568 @@ func DecodeAndExecute(instruccion string) {
 		datos := sliceInstruccion[2]
 		direccion, err := strconv.Atoi(sliceInstruccion[1])
 		if err == nil { // sacar si hay que sumarle 1 al PC
-			WRITE(direccion, datos)
+			WRITE(nucleo, direccion, datos)
 		}
 
 	case "READ":
 		direccion, err1 := strconv.Atoi(sliceInstruccion[1])
 		tamanio, err2 := strconv.Atoi(sliceInstruccion[2])
 		if err1 == nil && err2 == nil { // sacar si hay que sumarle 1 al PC
-			READ(direccion, tamanio)
+			READ(nucleo, direccion, tamanio)
 		}
 
 	case "GOTO":
-		ModificarPC = false
+		nucleo.ModificarPC = false
 		nuevoPC, err := strconv.Atoi(sliceInstruccion[1])
 		if err == nil { // sacar si hay que sumarle 1 al PC
-			PC = nuevoPC
+			nucleo.PC = nuevoPC
 		}
 	case "IO": // syscall
 		nombre := sliceInstruccion[1]
 		tiempo, err := strconv.Atoi(sliceInstruccion[2])
 		if err == nil {
-			IO(nombre, tiempo)
+			IO(nucleo, nombre, tiempo)
+		}
+	case "IO_ASYNC": // syscall, el proceso sigue ejecutando
+		nombre := sliceInstruccion[1]
+		tiempo, err1 := strconv.Atoi(sliceInstruccion[2])
+		handle, err2 := strconv.Atoi(sliceInstruccion[3])
+		if err1 == nil && err2 == nil {
+			IO_ASYNC(nucleo, nombre, tiempo, handle)
+		}
+	case "IO_WAIT": // syscall, solo bloquea si la IO no terminó
+		handle, err := strconv.Atoi(sliceInstruccion[1])
+		if err == nil {
+			IO_WAIT(nucleo, handle)
 		}
 	case "INIT_PROC": // syscall
 		archivoDeInstrucc := sliceInstruccion[1]
 		tamanio, err := strconv.Atoi(sliceInstruccion[2])
+		prioridad := 0 // la prioridad es opcional, por defecto 0
+		if len(sliceInstruccion) > 3 {
+			prioridad, _ = strconv.Atoi(sliceInstruccion[3])
+		}
 		if err == nil {
-			INIT_PROC(archivoDeInstrucc, tamanio)
+			INIT_PROC(nucleo, archivoDeInstrucc, tamanio, prioridad)
 		}
 
+	case "SET":
+		if len(parametros) == 2 {
+			SET(nucleo, parametros[0], parametros[1])
+		}
+	case "SUM":
+		if len(parametros) == 2 {
+			SUM(nucleo, parametros[0], parametros[1])
+		}
+	case "SUB":
+		if len(parametros) == 2 {
+			SUB(nucleo, parametros[0], parametros[1])
+		}
+	case "MOV_IN": // MOV_IN registro direccion
+		if len(parametros) == 2 {
+			MOV_IN(nucleo, parametros[0], parametros[1])
+		}
+	case "MOV_OUT": // MOV_OUT direccion registro
+		if len(parametros) == 2 {
+			MOV_OUT(nucleo, parametros[0], parametros[1])
+		}
+	case "JNZ": // JNZ registro pc
+		if len(parametros) == 2 {
+			JNZ(nucleo, parametros[0], parametros[1])
+		}
+	case "MPROTECT": // MPROTECT direccion tamanio RO|RW
+		if len(parametros) != 3 {
+			lanzarExcepcion(nucleo, globales.ExcepcionInstruccionInvalida, fmt.Sprintf("MPROTECT espera 3 parametros, tiene %d", len(parametros)))
+			return
+		}
+		MPROTECT(nucleo, parametros[0], parametros[1], parametros[2])
+
 	case "DUMP_MEMORY": // syscall
-		DUMP_MEMORY()
+		DUMP_MEMORY(nucleo)
 
 	case "EXIT": // syscall
-		EXIT()
+		EXIT(nucleo)
 	}
 }
 
+// --------- HEARTBEAT DEL KERNEL --------- //
+func AtenderHeartbeat(nucleo *Nucleo, w http.ResponseWriter, r *http.Request) {
+	slog.Debug(fmt.Sprintf("Heartbeat recibido del Kernel - PID en ejecucion: %d", nucleo.ejecutandoPID))
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
 // --------- INTERRUMPIR UN PROCESO POR DESALOJO --------- //
-func InterrumpirPorDesalojo(w http.ResponseWriter, r *http.Request) {
-	mutexEjecucion.Lock()
+func InterrumpirPorDesalojo(nucleo *Nucleo, w http.ResponseWriter, r *http.Request) {
 	var peticion globales.Interrupcion
 	peticion = globales.DecodificarPaquete(w, r, &peticion)
 
-	if peticion.PID != ejecutandoPID {
-		slog.Error(fmt.Sprintf("La interrupción recibida no corresponde al PID %d, sino al PID %d", ejecutandoPID, peticion.PID))
-		desalojar = false
-	} else {
-		desalojar = true
-		slog.Debug(fmt.Sprintf("Interrupción recibida para PID %d, PC actualizado a %d", peticion.PID, PC))
+	tipo := peticion.MOTIVO
+	if _, ok := prioridadInterrupcion[tipo]; !ok || tipo == globales.InterrupcionFallo {
+		tipo = globales.InterrupcionDesalojo // los fallos solo los genera la CPU
+	}
+	// si no corresponde al proceso en ejecucion se descarta en CHECK_INTERRUPT
+	levantarInterrupcion(nucleo, interrupcionPendiente{tipo: tipo, pid: peticion.PID})
+	slog.Debug(fmt.Sprintf("Interrupción %s recibida para PID %d", tipo, peticion.PID))
+
+	slog.Info("## Llega interrupcion al puerto Interrupt", "pid", peticion.PID, "cpu_id", nucleo.ID) // log obligatorio
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
+// --------- CONTROLADOR DE INTERRUPCIONES --------- //
+func levantarInterrupcion(nucleo *Nucleo, interrupcion interrupcionPendiente) {
+	nucleo.mutexInterrupciones.Lock()
+	nucleo.interrupciones = append(nucleo.interrupciones, interrupcion)
+	nucleo.mutexInterrupciones.Unlock()
+}
+
+// Saca la interrupcion mas prioritaria del proceso en ejecucion. Las demas se descartan porque
+// cualquiera de ellas saca al proceso de la CPU
+func siguienteInterrupcion(nucleo *Nucleo) *interrupcionPendiente {
+	nucleo.mutexInterrupciones.Lock()
+	defer nucleo.mutexInterrupciones.Unlock()
+
+	var elegida *interrupcionPendiente
+	for i := range nucleo.interrupciones {
+		interrupcion := &nucleo.interrupciones[i]
+		if interrupcion.pid != nucleo.ejecutandoPID {
+			slog.Debug(fmt.Sprintf("Se descarta la interrupción %s del PID %d, ejecuta el PID %d", interrupcion.tipo, interrupcion.pid, nucleo.ejecutandoPID))
+			continue
+		}
+		if elegida == nil || prioridadInterrupcion[interrupcion.tipo] < prioridadInterrupcion[elegida.tipo] {
+			elegida = interrupcion
+		}
+	}
+	if elegida == nil {
+		nucleo.interrupciones = nucleo.interrupciones[:0]
+		return nil
+	}
+	atendida := *elegida
+	nucleo.interrupciones = nil
+	return &atendida
+}
+
+func descartarInterrupciones(nucleo *Nucleo) {
+	nucleo.mutexInterrupciones.Lock()
+	nucleo.interrupciones = nil
+	nucleo.mutexInterrupciones.Unlock()
+}
+
+// Los fallos finalizan el proceso, el resto lo devuelve al kernel para que lo replanifique
+func atenderInterrupcion(nucleo *Nucleo, interrupcion *interrupcionPendiente) {
+	slog.Debug(fmt.Sprintf("PID: %d - Atendiendo interrupción %s en PC %d", nucleo.ejecutandoPID, interrupcion.tipo, nucleo.PC))
+	nucleo.traza.Registrar(traza.Evento{Tipo: traza.Interrupcion, PID: nucleo.ejecutandoPID, PC: nucleo.PC, Detalle: interrupcion.tipo})
+
+	if interrupcion.tipo == globales.InterrupcionFallo {
+		go enviarAlKernel(nucleo.ejecutandoPID, "/cpu/excepcion", interrupcion.excepcion)
+		return
+	}
+
+	procesoInterrumpido := globales.Interrupcion{
+		PID:       nucleo.ejecutandoPID,
+		PC:        nucleo.PC,
+		MOTIVO:    interrupcion.tipo,
+		REGISTROS: nucleo.Registros,
+	}
+	slog.Debug("ENVIANDO PROCESO INTERRUMPIDO")
+	enviarAlKernel(nucleo.ejecutandoPID, "/cpu/interrupt", &procesoInterrumpido)
+}
+
+func iniciarTimerQuantum(nucleo *Nucleo, pid int) {
+	if ClientConfig.QUANTUM <= 0 {
+		return
 	}
-	mutexEjecucion.Unlock()
+	nucleo.timerQuantum = time.AfterFunc(time.Duration(ClientConfig.QUANTUM)*time.Millisecond, func() {
+		levantarInterrupcion(nucleo, interrupcionPendiente{tipo: globales.InterrupcionQuantum, pid: pid})
+	})
+}
 
-	slog.Info("## Llega interrupcion al puerto Interrupt") // log obligatorio
+func detenerTimerQuantum(nucleo *Nucleo) {
+	if nucleo.timerQuantum != nil {
+		nucleo.timerQuantum.Stop()
+		nucleo.timerQuantum = nil
+	}
+}
+
+// --------- DEPURADOR --------- //
+
+// Vista de una entrada de la cache de paginas para el depurador
+type EntradaCacheDepuracion struct {
+	ASID         int    `json:"asid"`
+	PAGINA       int    `json:"pagina"`
+	MARCO        int    `json:"marco"`
+	USO          bool   `json:"uso"`
+	MODIFICADO   bool   `json:"modificado"`
+	SOLO_LECTURA bool   `json:"solo_lectura"`
+	DATOS        string `json:"datos"`
+}
+
+type EstadoDepuracion struct {
+	DETENIDO    bool                     `json:"detenido"`
+	PID         int                      `json:"pid"`
+	PC          int                      `json:"pc"`
+	INSTRUCCION string                   `json:"instruccion"`
+	REGISTROS   globales.Registros       `json:"registros"`
+	BREAKPOINTS map[int][]int            `json:"breakpoints"`
+	TLB         []EntradaTLB             `json:"tlb"`
+	CACHE       []EntradaCacheDepuracion `json:"cache"`
+}
+
+// Se llama con mutexEjecucion tomado, entre FETCH y DECODE. Mientras el nucleo esta detenido se libera
+// mutexEjecucion para poder inspeccionarlo; el kernel lo sigue viendo en RUNNING.
+func esperarDepurador(nucleo *Nucleo, instruccion string) {
+	nucleo.mutexDepurador.Lock()
+	if !nucleo.modoPaso && !nucleo.breakpoints[nucleo.ejecutandoPID][nucleo.PC] {
+		nucleo.mutexDepurador.Unlock()
+		return
+	}
+	nucleo.detenido = true
+	nucleo.instruccionDetenida = instruccion
+	nucleo.pidDetenido, nucleo.pcDetenido = nucleo.ejecutandoPID, nucleo.PC
+	nucleo.mutexDepurador.Unlock()
+
+	slog.Info(fmt.Sprintf("## PID: %d - Detenido por el depurador en PC %d: %s", nucleo.ejecutandoPID, nucleo.PC, instruccion))
+	detenerTimerQuantum(nucleo) // el quantum vuelve a empezar al reanudar
+	nucleo.mutexEjecucion.Unlock()
+
+	paso := <-nucleo.reanudar
+
+	nucleo.mutexEjecucion.Lock()
+	aplicarInvalidaciones(nucleo) // las que llegaron mientras estaba detenido
+	iniciarTimerQuantum(nucleo, nucleo.ejecutandoPID)
+	nucleo.mutexDepurador.Lock()
+	nucleo.modoPaso = paso
+	nucleo.mutexDepurador.Unlock()
+}
+
+func Depurar(nucleo *Nucleo, w http.ResponseWriter, r *http.Request) {
+	var comando globales.ComandoDepuracion
+	comando = globales.DecodificarPaquete(w, r, &comando)
+
+	switch comando.ACCION {
+	case "BREAKPOINT":
+		nucleo.mutexDepurador.Lock()
+		if nucleo.breakpoints[comando.PID] == nil {
+			nucleo.breakpoints[comando.PID] = make(map[int]bool)
+		}
+		nucleo.breakpoints[comando.PID][comando.PC] = true
+		nucleo.mutexDepurador.Unlock()
+		slog.Info(fmt.Sprintf("## CPU %s - Breakpoint en PID %d, PC %d", nucleo.ID, comando.PID, comando.PC))
+
+	case "QUITAR_BREAKPOINT":
+		nucleo.mutexDepurador.Lock()
+		delete(nucleo.breakpoints[comando.PID], comando.PC)
+		nucleo.mutexDepurador.Unlock()
+
+	case "PASO", "CONTINUAR":
+		nucleo.mutexDepurador.Lock()
+		if !nucleo.detenido {
+			nucleo.mutexDepurador.Unlock()
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte("no hay un proceso detenido"))
+			return
+		}
+		nucleo.detenido = false
+		nucleo.mutexDepurador.Unlock()
+		nucleo.reanudar <- comando.ACCION == "PASO"
+
+	case "ESTADO":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(estadoDepuracion(nucleo))
+		return
+
+	case "MEMORIA":
+		// con mutexEjecucion tomado el nucleo no puede reanudar mientras se leen su TLB, su cache y su PID
+		nucleo.mutexEjecucion.Lock()
+		defer nucleo.mutexEjecucion.Unlock()
+		nucleo.mutexDepurador.Lock()
+		detenido := nucleo.detenido
+		nucleo.mutexDepurador.Unlock()
+		if !detenido {
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte("no hay un proceso detenido"))
+			return
+		}
+		if comando.TAMANIO <= 0 || comando.DIRECCION < 0 || comando.DIRECCION+comando.TAMANIO > nucleo.tamanioProceso {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("rango fuera del proceso"))
+			return
+		}
+		contenido, err := inspeccionarMemoria(nucleo, comando.DIRECCION, comando.TAMANIO)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte(fmt.Sprintf("no se pudo leer la memoria: %v", err)))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write(contenido)
+		return
+
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf("accion desconocida: %s", comando.ACCION)))
+		return
+	}
 
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("ok"))
 }
 
+// La TLB y la cache solo se muestran con el nucleo detenido, si no estan cambiando en cada ciclo
+func estadoDepuracion(nucleo *Nucleo) EstadoDepuracion {
+	nucleo.mutexDepurador.Lock()
+	estado := EstadoDepuracion{
+		DETENIDO:    nucleo.detenido,
+		BREAKPOINTS: make(map[int][]int),
+	}
+	if nucleo.detenido {
+		estado.INSTRUCCION = nucleo.instruccionDetenida
+		estado.PID, estado.PC = nucleo.pidDetenido, nucleo.pcDetenido
+	}
+	for pid, pcs := range nucleo.breakpoints {
+		for pc := range pcs {
+			estado.BREAKPOINTS[pid] = append(estado.BREAKPOINTS[pid], pc)
+		}
+		slices.Sort(estado.BREAKPOINTS[pid])
+	}
+	nucleo.mutexDepurador.Unlock()
+
+	if !estado.DETENIDO {
+		// el PID y el PC los escribe el ciclo de instruccion con mutexEjecucion tomado
+		nucleo.mutexEjecucion.Lock()
+		estado.PID, estado.PC = nucleo.ejecutandoPID, nucleo.PC
+		nucleo.mutexEjecucion.Unlock()
+		return estado
+	}
+
+	nucleo.mutexEjecucion.Lock()
+	estado.REGISTROS = nucleo.Registros
+	estado.TLB = slices.Clone(nucleo.TLB)
+	nucleo.mutexEjecucion.Unlock()
+
+	nucleo.Cache.mutex.Lock()
+	for _, entrada := range nucleo.Cache.Entradas {
+		if !entrada.entradaOcupada {
+			continue
+		}
+		estado.CACHE = append(estado.CACHE, EntradaCacheDepuracion{
+			ASID:         entrada.asid,
+			PAGINA:       entrada.nroPagina,
+			MARCO:        entrada.nroMarco,
+			USO:          entrada.bitDeUso,
+			MODIFICADO:   entrada.bitModificado,
+			SOLO_LECTURA: entrada.soloLectura,
+			DATOS:        string(entrada.Datos),
+		})
+	}
+	nucleo.Cache.mutex.Unlock()
+	return estado
+}
+
+// Lee memoria del proceso detenido sin tocar la TLB ni la cache: si la pagina esta en cache se toma
+// de ahi (puede estar modificada), si no se traduce y se lee directo de memoria.
+// Se llama con mutexEjecucion tomado y el nucleo detenido.
+func inspeccionarMemoria(nucleo *Nucleo, direccionLogica int, tamanio int) ([]byte, error) {
+	contenido := make([]byte, 0, tamanio)
+	for tamanio > 0 {
+		nroPagina := direccionLogica / TamanioPagina
+		offset := direccionLogica % TamanioPagina
+		tamanioEnPagina := min(tamanio, TamanioPagina-offset)
+
+		datos, ok := leerPaginaDeCache(nucleo, nroPagina)
+		if ok {
+			contenido = append(contenido, datos[offset:offset+tamanioEnPagina]...)
+		} else {
+			nroMarco := -1
+			if tlbHabilitada && EstaEnTLB(nucleo, nroPagina) {
+				nroMarco, _ = obtenerMarcoTLB(nucleo, nroPagina)
+			} else {
+				marcoStruct := globales.ObtenerMarco{
+					PID:              nucleo.ejecutandoPID,
+					Entradas_Nivel_X: MMU(direccionLogica),
+				}
+				marco, err := globales.Llamar[globales.MarcoObtenido](context.Background(), ClientConfig.IP_MEMORY, ClientConfig.PORT_MEMORY, "/cpu/obtener_marco", &marcoStruct)
+				if err != nil {
+					return nil, err
+				}
+				nroMarco = marco.NUMERO_MARCO
+			}
+			if nroMarco < 0 {
+				return nil, fmt.Errorf("la pagina %d no tiene marco", nroPagina)
+			}
+
+			peticion := globales.LeerMemoria{
+				DIRECCION: nroMarco*TamanioPagina + offset,
+				PID:       nucleo.ejecutandoPID,
+				TAMANIO:   tamanioEnPagina,
+			}
+			datos, err := globales.Llamar[[]byte](context.Background(), ClientConfig.IP_MEMORY, ClientConfig.PORT_MEMORY, "/cpu/leer_direccion", &peticion)
+			if err != nil {
+				return nil, err
+			}
+			contenido = append(contenido, datos...)
+		}
+
+		direccionLogica += tamanioEnPagina
+		tamanio -= tamanioEnPagina
+	}
+	return contenido, nil
+}
+
+func leerPaginaDeCache(nucleo *Nucleo, nroPagina int) ([]byte, bool) {
+	if !cacheHabilitada {
+		return nil, false
+	}
+	nucleo.Cache.mutex.Lock()
+	defer nucleo.Cache.mutex.Unlock()
+	for _, entrada := range nucleo.Cache.Entradas {
+		if entrada.entradaOcupada && entrada.asid == nucleo.ejecutandoPID && entrada.nroPagina == nroPagina {
+			return slices.Clone(entrada.Datos), true
+		}
+	}
+	return nil, false
+}
+
 // --------- INSTRUCCIONES --------- //
-func WRITE(direccionLogica int, datos string) {
+func WRITE(nucleo *Nucleo, direccionLogica int, datos string) {
+	escribirEnMemoria(nucleo, direccionLogica, []byte(datos))
+}
+
+func READ(nucleo *Nucleo, direccionLogica int, tamanio int) {
+	leerDeMemoria(nucleo, direccionLogica, tamanio)
+}
+
+// Escribe bytes crudos en la direccion logica, partiendo el acceso en una escritura por pagina
+func escribirEnMemoria(nucleo *Nucleo, direccionLogica int, datos []byte) {
+	if !accesoValido(nucleo, direccionLogica, len(datos)) {
+		return
+	}
+
+	for len(datos) > 0 {
+		largo := min(len(datos), TamanioPagina-direccionLogica%TamanioPagina)
+		iniciarAcceso(nucleo, traza.Escritura, direccionLogica, largo)
+		escribirEnPagina(nucleo, direccionLogica, datos[:largo])
+		nucleo.traza.Registrar(nucleo.acceso)
+		direccionLogica += largo
+		datos = datos[largo:]
+	}
+}
+
+// Lee bytes crudos de la direccion logica, partiendo el acceso en una lectura por pagina.
+// Devuelve nil si no se pudo leer.
+func leerDeMemoria(nucleo *Nucleo, direccionLogica int, tamanio int) []byte {
+	if !accesoValido(nucleo, direccionLogica, tamanio) {
+		return nil
+	}
+
+	contenido := make([]byte, 0, tamanio)
+	for tamanio > 0 {
+		largo := min(tamanio, TamanioPagina-direccionLogica%TamanioPagina)
+		iniciarAcceso(nucleo, traza.Lectura, direccionLogica, largo)
+		parte := leerDePagina(nucleo, direccionLogica, largo)
+		nucleo.traza.Registrar(nucleo.acceso)
+		if parte == nil {
+			return nil
+		}
+		contenido = append(contenido, parte...)
+		direccionLogica += largo
+		tamanio -= largo
+	}
+	return contenido
+}
+
+// --------- TRAZA --------- //
+var syscalls = map[string]bool{"IO": true, "IO_ASYNC": true, "IO_WAIT": true, "INIT_PROC": true, "DUMP_MEMORY": true, "EXIT": true}
+
+func registrarInstruccion(nucleo *Nucleo, instruccion string) {
+	if nucleo.traza == nil {
+		return
+	}
+	nucleo.traza.Registrar(traza.Evento{Tipo: traza.Fetch, PID: nucleo.ejecutandoPID, PC: nucleo.PC, Detalle: instruccion})
+	if opcode, _, _ := strings.Cut(instruccion, " "); syscalls[opcode] {
+		nucleo.traza.Registrar(traza.Evento{Tipo: traza.Syscall, PID: nucleo.ejecutandoPID, PC: nucleo.PC, Detalle: opcode})
+	}
+}
+
+func iniciarAcceso(nucleo *Nucleo, tipo traza.TipoEvento, direccionLogica int, tamanio int) {
+	nucleo.acceso = traza.Evento{
+		Tipo:      tipo,
+		PID:       nucleo.ejecutandoPID,
+		PC:        nucleo.PC,
+		Direccion: direccionLogica,
+		Tamanio:   tamanio,
+		Marco:     -1,
+		TLB:       traza.NoAplica,
+		Cache:     traza.NoAplica,
+	}
+}
+
+// Un acceso fuera del espacio del proceso es un fallo: se avisa al kernel y el proceso deja de ejecutar
+func accesoValido(nucleo *Nucleo, direccionLogica int, tamanio int) bool {
+	if direccionLogica >= 0 && tamanio >= 0 && direccionLogica+tamanio <= nucleo.tamanioProceso {
+		return true
+	}
+	lanzarExcepcion(nucleo, globales.ExcepcionAccesoInvalido, fmt.Sprintf("acceso fuera del proceso: %d-%d (tamaño %d)", direccionLogica, direccionLogica+tamanio-1, nucleo.tamanioProceso))
+	return false
+}
+
+func lanzarExcepcion(nucleo *Nucleo, tipo string, motivo string) {
+	slog.Error(fmt.Sprintf("PID: %d - Excepcion %s en PC %d: %s", nucleo.ejecutandoPID, tipo, nucleo.PC, motivo))
+
+	excepcion := globales.Excepcion{
+		PID:    nucleo.ejecutandoPID,
+		PC:     nucleo.PC,
+		TIPO:   tipo,
+		MOTIVO: motivo,
+	}
+	levantarInterrupcion(nucleo, interrupcionPendiente{tipo: globales.InterrupcionFallo, pid: nucleo.ejecutandoPID, excepcion: &excepcion})
+}
+
+// Memoria no pudo atender un pedido del proceso: sin ese dato no puede seguir ejecutando
+func errorDeMemoria(nucleo *Nucleo, err error) {
+	lanzarExcepcion(nucleo, globales.ExcepcionErrorMemoria, err.Error())
+}
+
+// Escribe en una sola pagina, pasando por la cache si esta habilitada
+func escribirEnPagina(nucleo *Nucleo, direccionLogica int, datos []byte) {
 
 	if cacheHabilitada {
 		nroPagina := direccionLogica / TamanioPagina
 		offset := direccionLogica % TamanioPagina
-		indiceEntradaCache := buscarEntradaCache(nroPagina, direccionLogica)
-		contenidoPagina := MemoriaCache[indiceEntradaCache].Datos
+
+		if coherenciaMSI() { // el snoop y la escritura local van juntos, sino otro nucleo puede cargar una copia vieja en el medio
+			mutexCoherencia.Lock()
+			defer mutexCoherencia.Unlock()
+		}
+		snoopOtrosNucleos(nucleo, nroPagina, true) // MSI: el resto de los nucleos pierde su copia antes de escribir
+		nucleo.Cache.mutex.Lock()
+		defer nucleo.Cache.mutex.Unlock()
+
+		if !ClientConfig.CACHE_WRITE_ALLOCATE && !estaEnCache(nucleo, nroPagina) {
+			// no-write-allocate: el miss de escritura no carga la pagina, se escribe directo en memoria
+			slog.Info(fmt.Sprintf("PID: %d - Cache Miss - Pagina: %d", nucleo.ejecutandoPID, nroPagina), "pid", nucleo.ejecutandoPID, "page", nroPagina, "cpu_id", nucleo.ID) // log obligatorio
+			nucleo.Estadisticas.CACHE_MISSES++
+			nucleo.acceso.Cache = traza.Miss
+			escribirDireccionEnMemoria(nucleo, direccionLogica, datos)
+			return
+		}
+
+		indiceEntradaCache, err := buscarEntradaCache(nucleo, nroPagina, direccionLogica)
+		if err != nil {
+			errorDeMemoria(nucleo, err)
+			return
+		}
+		if nucleo.Cache.Entradas[indiceEntradaCache].soloLectura {
+			lanzarExcepcion(nucleo, globales.ExcepcionViolacionProteccion, fmt.Sprintf("escritura en pagina de solo lectura: %d", nroPagina))
+			return
+		}
+		contenidoPagina := nucleo.Cache.Entradas[indiceEntradaCache].Datos
 
 		//contenido := contenidoPagina[offset : offset+tamanio] // Obtenemos el contenido de la pagina desde el offset hasta el tamanio solicitado
-		slog.Debug(fmt.Sprintf("PID: %d - WRITE - Pagina: %d, Offset: %d, Datos: %s , IndiceCache: %d", ejecutandoPID, nroPagina, offset, datos, indiceEntradaCache))
-		direccionFisica := MemoriaCache[indiceEntradaCache].nroMarco*TamanioPagina + offset // direccion fisica
+		slog.Debug(fmt.Sprintf("PID: %d - WRITE - Pagina: %d, Offset: %d, Datos: %s , IndiceCache: %d", nucleo.ejecutandoPID, nroPagina, offset, datos, indiceEntradaCache))
+		direccionFisica := nucleo.Cache.Entradas[indiceEntradaCache].nroMarco*TamanioPagina + offset // direccion fisica
 		//j := 0
 
 		slog.Debug(fmt.Sprintf("LONGITUD CONTENIDO DE LA PAGINA: %d", len(contenidoPagina)))
@@ -304,174 +1055,380 @@ func WRITE(direccionLogica int, datos string) {
 
 		slog.Debug(fmt.Sprintf("Contenido de la pagina despues de escribir: %s", string(contenidoPagina)))
 
-		MemoriaCache[indiceEntradaCache].Datos = contenidoPagina // Actualizamos los datos de la pagina en la cache
+		nucleo.Cache.Entradas[indiceEntradaCache].Datos = contenidoPagina // Actualizamos los datos de la pagina en la cache
 
-		MemoriaCache[indiceEntradaCache].bitModificado = true // Marcamos la pagina como modificada
+		nucleo.Cache.Entradas[indiceEntradaCache].bitModificado = true // Marcamos la pagina como modificada
+
+		if ClientConfig.CACHE_WRITE_POLICY == "write-through" { // la pagina se baja en cada escritura y queda limpia
+			if err := escribirPaginaCacheEnMemoria(nucleo, indiceEntradaCache); err != nil {
+				errorDeMemoria(nucleo, err)
+				return
+			}
+			nucleo.Cache.Entradas[indiceEntradaCache].bitModificado = false
+		}
 
-		slog.Info(fmt.Sprintf("PID: %d - Acción: ESCRIBIR - Dirección Física: %d - Valor: %s", ejecutandoPID, direccionFisica, string(datos))) // log obligatorio
+		slog.Info(fmt.Sprintf("PID: %d - Acción: ESCRIBIR - Dirección Física: %d - Valor: %s", nucleo.ejecutandoPID, direccionFisica, string(datos)), "pid", nucleo.ejecutandoPID, "address", direccionFisica, "cpu_id", nucleo.ID) // log obligatorio
 
 	} else {
-		var direccionFisica int
-		nroPagina := direccionLogica / TamanioPagina
-		offset := direccionLogica % TamanioPagina
-		nroMarco := traduccionDireccionLogica(nroPagina, direccionLogica)
+		escribirDireccionEnMemoria(nucleo, direccionLogica, datos)
+	}
+}
 
-		direccionFisica = nroMarco*TamanioPagina + offset
+// Escribe directo en memoria, sin pasar por la cache
+func escribirDireccionEnMemoria(nucleo *Nucleo, direccionLogica int, datos []byte) {
+	var direccionFisica int
+	nroPagina := direccionLogica / TamanioPagina
+	offset := direccionLogica % TamanioPagina
+	nroMarco, soloLectura, err := traduccionDireccionLogica(nucleo, nroPagina, direccionLogica)
+	if err != nil {
+		errorDeMemoria(nucleo, err)
+		return
+	}
+	if soloLectura {
+		lanzarExcepcion(nucleo, globales.ExcepcionViolacionProteccion, fmt.Sprintf("escritura en pagina de solo lectura: %d", nroPagina))
+		return
+	}
 
-		peticion := globales.EscribirMemoria{
-			DIRECCION: direccionFisica,
-			PID:       ejecutandoPID,
-			DATOS:     datos,
-		}
+	direccionFisica = nroMarco*TamanioPagina + offset
 
-		resp, _ := globales.GenerarYEnviarPaquete(&peticion, ClientConfig.IP_MEMORY, ClientConfig.PORT_MEMORY, "/cpu/escribir_direccion")
-		if resp.StatusCode != http.StatusOK {
-			slog.Error(fmt.Sprintf("Error al escribir en memoria: %s", resp.Status))
-			return
-		} else {
-			slog.Info(fmt.Sprintf("PID: %d - Acción: ESCRIBIR - Dirección Física: %d - Valor: %s", ejecutandoPID, direccionFisica, datos)) // log obligatorio
-		}
+	peticion := globales.EscribirMemoria{
+		DIRECCION: direccionFisica,
+		PID:       nucleo.ejecutandoPID,
+		DATOS:     datos,
+	}
+
+	nucleo.Estadisticas.ESCRITURAS_DIRECCION++
+	nucleo.Estadisticas.BYTES_ESCRITOS_MEMORIA += len(datos)
+	err = globales.Enviar(context.Background(), ClientConfig.IP_MEMORY, ClientConfig.PORT_MEMORY, "/cpu/escribir_direccion", &peticion)
+	if globales.EstadoDeError(err) == http.StatusForbidden {
+		lanzarExcepcion(nucleo, globales.ExcepcionViolacionProteccion, fmt.Sprintf("escritura en pagina de solo lectura: %d", nroPagina))
+		return
 	}
+	if err != nil {
+		errorDeMemoria(nucleo, err)
+		return
+	}
+	slog.Info(fmt.Sprintf("PID: %d - Acción: ESCRIBIR - Dirección Física: %d - Valor: %s", nucleo.ejecutandoPID, direccionFisica, datos), "pid", nucleo.ejecutandoPID, "address", direccionFisica, "cpu_id", nucleo.ID) // log obligatorio
 }
 
-func READ(direccionLogica int, tamanio int) {
+// Lee de una sola pagina, pasando por la cache si esta habilitada. Devuelve nil si no se pudo leer.
+func leerDePagina(nucleo *Nucleo, direccionLogica int, tamanio int) []byte {
 
 	if cacheHabilitada {
 		nroPagina := direccionLogica / TamanioPagina
 		offset := direccionLogica % TamanioPagina
-		indiceEntradaCache := buscarEntradaCache(nroPagina, direccionLogica)
-		contenidoPagina := MemoriaCache[indiceEntradaCache].Datos
-		slog.Debug(fmt.Sprintf("PID: %d - LEER - Pagina: %d, Offset: %d , IndiceCache: %d", ejecutandoPID, nroPagina, offset, indiceEntradaCache))
+
+		if coherenciaMSI() {
+			mutexCoherencia.Lock()
+			defer mutexCoherencia.Unlock()
+		}
+		snoopOtrosNucleos(nucleo, nroPagina, false) // MSI: si otro nucleo la tiene modificada, la baja a memoria y queda compartida
+		nucleo.Cache.mutex.Lock()
+		defer nucleo.Cache.mutex.Unlock()
+
+		indiceEntradaCache, err := buscarEntradaCache(nucleo, nroPagina, direccionLogica)
+		if err != nil {
+			errorDeMemoria(nucleo, err)
+			return nil
+		}
+		contenidoPagina := nucleo.Cache.Entradas[indiceEntradaCache].Datos
+		slog.Debug(fmt.Sprintf("PID: %d - LEER - Pagina: %d, Offset: %d , IndiceCache: %d", nucleo.ejecutandoPID, nroPagina, offset, indiceEntradaCache))
 
 		contenido := contenidoPagina[offset : offset+tamanio] // Obtenemos el contenido de la pagina desde el offset hasta el tamanio solicitado
 
-		direccionFisica := MemoriaCache[indiceEntradaCache].nroMarco*TamanioPagina + offset                                                    // direccion fisica
-		slog.Info(fmt.Sprintf("PID: %d - Acción: LEER - Dirección Física: %d - Valor: %s", ejecutandoPID, direccionFisica, string(contenido))) // log obligatorio
+		direccionFisica := nucleo.Cache.Entradas[indiceEntradaCache].nroMarco*TamanioPagina + offset                                                    // direccion fisica
+		slog.Info(fmt.Sprintf("PID: %d - Acción: LEER - Dirección Física: %d - Valor: %s", nucleo.ejecutandoPID, direccionFisica, string(contenido)), "pid", nucleo.ejecutandoPID, "address", direccionFisica, "cpu_id", nucleo.ID) // log obligatorio
+		return contenido
 
 	} else {
 
 		var direccionFisica int
 		nroPagina := direccionLogica / TamanioPagina
 		offset := direccionLogica % TamanioPagina
-		nroMarco := traduccionDireccionLogica(nroPagina, direccionLogica)
+		nroMarco, _, err := traduccionDireccionLogica(nucleo, nroPagina, direccionLogica)
+		if err != nil {
+			errorDeMemoria(nucleo, err)
+			return nil
+		}
 
 		direccionFisica = nroMarco*TamanioPagina + offset
 
 		peticion := globales.LeerMemoria{
 			DIRECCION: direccionFisica,
-			PID:       ejecutandoPID,
+			PID:       nucleo.ejecutandoPID,
 			TAMANIO:   tamanio,
 		}
 
-		resp, body := globales.GenerarYEnviarPaquete(&peticion, ClientConfig.IP_MEMORY, ClientConfig.PORT_MEMORY, "/cpu/leer_direccion")
-		if resp.StatusCode != http.StatusOK {
-			slog.Error(fmt.Sprintf("Error al escribir en memoria: %s", resp.Status))
-			return
-		} else {
-			contenido, err := io.ReadAll(bytes.NewReader(body))
-			if err == nil {
-				slog.Info(fmt.Sprintf("PID: %d - Acción: LEER - Dirección Física: %d - Valor: %s", ejecutandoPID, direccionFisica, string(contenido))) // log obligatorio
-			} else {
-				fmt.Print("error leyendo body")
-			}
+		contenido, err := globales.Llamar[[]byte](context.Background(), ClientConfig.IP_MEMORY, ClientConfig.PORT_MEMORY, "/cpu/leer_direccion", &peticion)
+		if err != nil {
+			errorDeMemoria(nucleo, err)
+			return nil
 		}
+		slog.Info(fmt.Sprintf("PID: %d - Acción: LEER - Dirección Física: %d - Valor: %s", nucleo.ejecutandoPID, direccionFisica, string(contenido)), "pid", nucleo.ejecutandoPID, "address", direccionFisica, "cpu_id", nucleo.ID) // log obligatorio
+		return contenido
+	}
+}
+
+// --------- REGISTROS --------- //
+const tamanioRegistro = 4 // los registros se guardan en memoria como uint32 little endian
+
+func registro(nucleo *Nucleo, nombre string) *uint32 {
+	switch nombre {
+	case "AX":
+		return &nucleo.Registros.AX
+	case "BX":
+		return &nucleo.Registros.BX
+	case "CX":
+		return &nucleo.Registros.CX
+	case "DX":
+		return &nucleo.Registros.DX
+	}
+	return nil
+}
+
+// Un operando puede ser un registro o un valor inmediato
+func valorOperando(nucleo *Nucleo, operando string) (uint32, error) {
+	if reg := registro(nucleo, operando); reg != nil {
+		return *reg, nil
+	}
+	valor, err := strconv.ParseUint(operando, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("operando invalido: %s", operando)
+	}
+	return uint32(valor), nil
+}
+
+// Devuelve el registro destino y el valor del operando, logueando si alguno es invalido
+func registroYOperando(nucleo *Nucleo, nombreRegistro string, operando string) (*uint32, uint32, bool) {
+	reg := registro(nucleo, nombreRegistro)
+	if reg == nil {
+		lanzarExcepcion(nucleo, globales.ExcepcionInstruccionInvalida, fmt.Sprintf("registro invalido: %s", nombreRegistro))
+		return nil, 0, false
+	}
+	valor, err := valorOperando(nucleo, operando)
+	if err != nil {
+		lanzarExcepcion(nucleo, globales.ExcepcionInstruccionInvalida, err.Error())
+		return nil, 0, false
+	}
+	return reg, valor, true
+}
+
+func SET(nucleo *Nucleo, nombreRegistro string, operando string) {
+	if reg, valor, ok := registroYOperando(nucleo, nombreRegistro, operando); ok {
+		*reg = valor
+	}
+}
+
+func SUM(nucleo *Nucleo, nombreRegistro string, operando string) {
+	if reg, valor, ok := registroYOperando(nucleo, nombreRegistro, operando); ok {
+		*reg += valor
+	}
+}
+
+func SUB(nucleo *Nucleo, nombreRegistro string, operando string) {
+	if reg, valor, ok := registroYOperando(nucleo, nombreRegistro, operando); ok {
+		*reg -= valor
+	}
+}
+
+func MOV_IN(nucleo *Nucleo, nombreRegistro string, operandoDireccion string) {
+	reg, direccion, ok := registroYOperando(nucleo, nombreRegistro, operandoDireccion)
+	if !ok {
+		return
+	}
+	datos := leerDeMemoria(nucleo, int(direccion), tamanioRegistro)
+	if len(datos) != tamanioRegistro {
+		slog.Error(fmt.Sprintf("PID: %d - MOV_IN - No se pudo leer la direccion %d", nucleo.ejecutandoPID, direccion))
+		return
+	}
+	*reg = binary.LittleEndian.Uint32(datos)
+}
+
+func MOV_OUT(nucleo *Nucleo, operandoDireccion string, nombreRegistro string) {
+	reg, direccion, ok := registroYOperando(nucleo, nombreRegistro, operandoDireccion)
+	if !ok {
+		return
+	}
+	datos := make([]byte, tamanioRegistro)
+	binary.LittleEndian.PutUint32(datos, *reg)
+	escribirEnMemoria(nucleo, int(direccion), datos)
+}
+
+// Salta a la instruccion indicada si el registro no es cero
+func JNZ(nucleo *Nucleo, nombreRegistro string, operandoPC string) {
+	reg, nuevoPC, ok := registroYOperando(nucleo, nombreRegistro, operandoPC)
+	if !ok || *reg == 0 {
+		return
+	}
+	nucleo.ModificarPC = false
+	nucleo.PC = int(nuevoPC)
+}
+
+// Cambia la proteccion de las paginas del rango. Memoria invalida las traducciones cacheadas
+// (bajando antes las paginas modificadas) para que la nueva proteccion se respete en todos los nucleos
+func MPROTECT(nucleo *Nucleo, operandoDireccion string, operandoTamanio string, proteccion string) {
+	direccion, err1 := valorOperando(nucleo, operandoDireccion)
+	tamanio, err2 := strconv.Atoi(operandoTamanio)
+	if err1 != nil || err2 != nil || (proteccion != "RO" && proteccion != "RW") {
+		lanzarExcepcion(nucleo, globales.ExcepcionInstruccionInvalida, fmt.Sprintf("MPROTECT invalido: %s %s %s", operandoDireccion, operandoTamanio, proteccion))
+		return
+	}
+	if !accesoValido(nucleo, int(direccion), tamanio) {
+		return
+	}
+
+	solicitud := globales.SolicitudMProtect{
+		PID:          nucleo.ejecutandoPID,
+		DIRECCION:    int(direccion),
+		TAMANIO:      tamanio,
+		SOLO_LECTURA: proteccion == "RO",
+	}
+	if err := globales.Enviar(context.Background(), ClientConfig.IP_MEMORY, ClientConfig.PORT_MEMORY, "/cpu/mprotect", &solicitud); err != nil {
+		errorDeMemoria(nucleo, err) // sin la proteccion pedida el proceso no puede seguir
 	}
 }
 
 // --------- SYSCALLS --------- //
-func IO(nombre string, tiempo int) {
+func IO(nucleo *Nucleo, nombre string, tiempo int) {
 	var solicitud = globales.SolicitudIO{
 		NOMBRE: nombre,
 		TIEMPO: tiempo,
-		PID:    ejecutandoPID,
-		PC:     PC + 1,
+		PID:       nucleo.ejecutandoPID,
+		PC:        nucleo.PC + 1,
+		REGISTROS: nucleo.Registros,
 	}
-	go globales.GenerarYEnviarPaquete(&solicitud, ClientConfig.IP_KERNEL, ClientConfig.PORT_KERNEL, "/cpu/solicitarIO")
+	go enviarAlKernel(nucleo.ejecutandoPID, "/cpu/solicitarIO", &solicitud)
 
-	dejarDeEjecutar = true
+	nucleo.dejarDeEjecutar = true
 }
 
-func INIT_PROC(archivo_pseudocodigo string, tamanio_proceso int) {
+func IO_ASYNC(nucleo *Nucleo, nombre string, tiempo int, handle int) {
+	var solicitud = globales.SolicitudIO{
+		NOMBRE: nombre,
+		TIEMPO: tiempo,
+		PID:    nucleo.ejecutandoPID,
+		PC:     nucleo.PC + 1,
+		HANDLE: handle,
+	}
+	// es sincronica porque el kernel puede finalizar el proceso (dispositivo inexistente o cola llena)
+	if err := globales.Enviar(context.Background(), ClientConfig.IP_KERNEL, ClientConfig.PORT_KERNEL, "/cpu/solicitarIOAsincrona", &solicitud); err != nil {
+		slog.Error(fmt.Sprintf("PID: %d - No se pudo solicitar la IO asincrona con handle %d: %v", nucleo.ejecutandoPID, handle, err))
+		nucleo.dejarDeEjecutar = true
+	}
+}
+
+func IO_WAIT(nucleo *Nucleo, handle int) {
+	var solicitud = globales.SolicitudEsperaIO{
+		PID:       nucleo.ejecutandoPID,
+		PC:        nucleo.PC + 1,
+		HANDLE:    handle,
+		REGISTROS: nucleo.Registros,
+	}
+	respuesta, err := globales.Llamar[[]byte](context.Background(), ClientConfig.IP_KERNEL, ClientConfig.PORT_KERNEL, "/cpu/esperarIO", &solicitud)
+	if err != nil {
+		slog.Error(fmt.Sprintf("PID: %d - IO_WAIT del handle %d fallo: %v", nucleo.ejecutandoPID, handle, err))
+	}
+	if err != nil || string(respuesta) == "bloqueado" {
+		nucleo.dejarDeEjecutar = true
+	}
+}
+
+func INIT_PROC(nucleo *Nucleo, archivo_pseudocodigo string, tamanio_proceso int, prioridad int) {
 	var solicitud = globales.SolicitudProceso{
 		ARCHIVO_PSEUDOCODIGO: archivo_pseudocodigo,
 		TAMAÑO_PROCESO:       tamanio_proceso,
-		PID:                  ejecutandoPID,
+		PID:                  nucleo.ejecutandoPID,
+		PRIORIDAD:            prioridad,
 	}
-	go globales.GenerarYEnviarPaquete(&solicitud, ClientConfig.IP_KERNEL, ClientConfig.PORT_KERNEL, "/cpu/iniciarProceso")
+	go enviarAlKernel(nucleo.ejecutandoPID, "/cpu/iniciarProceso", &solicitud)
 }
 
-func DUMP_MEMORY() {
+func DUMP_MEMORY(nucleo *Nucleo) {
 	var solicitud = globales.SolicitudDump{
-		PID: ejecutandoPID,
-		PC:  PC + 1,
+		PID:       nucleo.ejecutandoPID,
+		PC:        nucleo.PC + 1,
+		REGISTROS: nucleo.Registros,
 	}
-	go globales.GenerarYEnviarPaquete(&solicitud, ClientConfig.IP_KERNEL, ClientConfig.PORT_KERNEL, "/cpu/dumpearMemoria")
-	dejarDeEjecutar = true
+	go enviarAlKernel(nucleo.ejecutandoPID, "/cpu/dumpearMemoria", &solicitud)
+	nucleo.dejarDeEjecutar = true
 }
 
-func EXIT() {
+func EXIT(nucleo *Nucleo) {
 	var pid = globales.PID{
-		NUMERO_PID: ejecutandoPID,
+		NUMERO_PID: nucleo.ejecutandoPID,
 	}
 
-	go globales.GenerarYEnviarPaquete(&pid, ClientConfig.IP_KERNEL, ClientConfig.PORT_KERNEL, "/cpu/terminarProceso")
-	slog.Debug(fmt.Sprintf("PID: %d - Acción: EXIT", ejecutandoPID))
-	dejarDeEjecutar = true
+	go enviarAlKernel(nucleo.ejecutandoPID, "/cpu/terminarProceso", &pid)
+	slog.Debug(fmt.Sprintf("PID: %d - Acción: EXIT", nucleo.ejecutandoPID))
+	nucleo.dejarDeEjecutar = true
+}
+
+// Para los avisos al kernel que no cambian lo que sigue ejecutando la CPU: si fallan solo queda el log
+func enviarAlKernel(pid int, ruta string, paquete any) {
+	if err := globales.Enviar(context.Background(), ClientConfig.IP_KERNEL, ClientConfig.PORT_KERNEL, ruta, paquete); err != nil {
+		slog.Error(fmt.Sprintf("PID: %d - No se pudo enviar %s al kernel: %v", pid, ruta, err))
+	}
 }
 
 // --------- TRADUCCIÓN DE DIRECCIÓN --------- //
-func traduccionDireccionLogica(nroPagina int, direccionLogica int) int {
+// Devuelve el marco de la pagina y si esta protegida contra escritura
+func traduccionDireccionLogica(nucleo *Nucleo, nroPagina int, direccionLogica int) (int, bool, error) {
 	if tlbHabilitada {
-		if EstaEnTLB(nroPagina) { // TLB Hit
-			slog.Info(fmt.Sprintf("PID: %d - TLB HIT - Pagina: %d", ejecutandoPID, nroPagina)) // log obligatorio
+		if EstaEnTLB(nucleo, nroPagina) { // TLB Hit
+			nucleo.Estadisticas.TLB_HITS++
+			slog.Info(fmt.Sprintf("PID: %d - TLB HIT - Pagina: %d", nucleo.ejecutandoPID, nroPagina), "pid", nucleo.ejecutandoPID, "page", nroPagina, "cpu_id", nucleo.ID) // log obligatorio
 
-			nroMarcoInt := obtenerMarcoTLB(nroPagina)
-			slog.Info(fmt.Sprintf("PID: %d - OBTENER MARCO - Pagina: %d - Marco: %d", ejecutandoPID, nroPagina, nroMarcoInt)) // log obligatorio
+			nroMarcoInt, soloLectura := obtenerMarcoTLB(nucleo, nroPagina)
+			nucleo.acceso.TLB, nucleo.acceso.Marco = traza.Hit, nroMarcoInt
+			slog.Info(fmt.Sprintf("PID: %d - OBTENER MARCO - Pagina: %d - Marco: %d", nucleo.ejecutandoPID, nroPagina, nroMarcoInt), "pid", nucleo.ejecutandoPID, "page", nroPagina, "frame", nroMarcoInt, "cpu_id", nucleo.ID) // log obligatorio
 			// Actualizar tiempo de referencia de la entrada TLB
-			for i := range TLB {
-				if TLB[i].NUMERO_PAG == nroPagina {
-					TLB[i].TIEMPO_DESDE_REFERENCIA = time.Now() // Actualizar el tiempo de uso de la entrada TLB
+			for i := range nucleo.TLB {
+				if nucleo.TLB[i].ASID == nucleo.ejecutandoPID && nucleo.TLB[i].NUMERO_PAG == nroPagina {
+					nucleo.TLB[i].TIEMPO_DESDE_REFERENCIA = time.Now() // Actualizar el tiempo de uso de la entrada TLB
 				}
 			}
-			return nroMarcoInt // direccion fisica
+			return nroMarcoInt, soloLectura, nil // direccion fisica
 		} else { // TLB Miss
 
-			slog.Info(fmt.Sprintf("PID: %d - TLB MISS - Pagina: %d", ejecutandoPID, nroPagina))
-			nroMarcoInt := accederAMarco(nroPagina, direccionLogica)
-			saveTLB(nroPagina, nroMarcoInt)
-			return nroMarcoInt
+			slog.Info(fmt.Sprintf("PID: %d - TLB MISS - Pagina: %d", nucleo.ejecutandoPID, nroPagina))
+			nucleo.Estadisticas.TLB_MISSES++
+			nucleo.acceso.TLB = traza.Miss
+			nroMarcoInt, soloLectura, err := accederAMarco(nucleo, nroPagina, direccionLogica)
+			if err != nil {
+				return -1, false, err
+			}
+			saveTLB(nucleo, nroPagina, nroMarcoInt, soloLectura)
+			return nroMarcoInt, soloLectura, nil
 		}
 	} else {
 		slog.Debug("TLB DESHABILITADA")
-		return accederAMarco(nroPagina, direccionLogica)
+		return accederAMarco(nucleo, nroPagina, direccionLogica)
 	}
 
 }
 
-func accederAMarco(nroPagina int, direccionLogica int) int {
+func accederAMarco(nucleo *Nucleo, nroPagina int, direccionLogica int) (int, bool, error) {
 
 	entrada_nivel_X := MMU(direccionLogica)
 
 	marcoStruct := globales.ObtenerMarco{
-		PID:              ejecutandoPID,
+		PID:              nucleo.ejecutandoPID,
 		Entradas_Nivel_X: entrada_nivel_X,
 	}
 
-	_, nroMarco := globales.GenerarYEnviarPaquete(&marcoStruct, ClientConfig.IP_MEMORY, ClientConfig.PORT_MEMORY, "/cpu/obtener_marco")
-	marco, err := io.ReadAll(bytes.NewReader(nroMarco))
-
+	marco, err := globales.Llamar[globales.MarcoObtenido](context.Background(), ClientConfig.IP_MEMORY, ClientConfig.PORT_MEMORY, "/cpu/obtener_marco", &marcoStruct)
 	if err != nil {
-		slog.Error(fmt.Sprintf("Error al leer el cuerpo de la respuesta: %v", err))
+		return -1, false, fmt.Errorf("no se pudo obtener el marco de la pagina %d: %w", nroPagina, err)
 	}
-	nroMarcoInt, _ := strconv.Atoi(string(marco))
 
-	slog.Info(fmt.Sprintf("PID: %d - OBTENER MARCO - Pagina: %d - Marco: %d", ejecutandoPID, nroPagina, nroMarcoInt)) // log obligatorio
-	return nroMarcoInt
+	slog.Info(fmt.Sprintf("PID: %d - OBTENER MARCO - Pagina: %d - Marco: %d", nucleo.ejecutandoPID, nroPagina, marco.NUMERO_MARCO), "pid", nucleo.ejecutandoPID, "page", nroPagina, "frame", marco.NUMERO_MARCO, "cpu_id", nucleo.ID) // log obligatorio
+	nucleo.acceso.Marco = marco.NUMERO_MARCO
+	return marco.NUMERO_MARCO, marco.SOLO_LECTURA, nil
 }
 
-func EstaEnTLB(numeroDePagina int) bool {
-	for _, entrada := range TLB {
-		if entrada.NUMERO_PAG == numeroDePagina {
+func EstaEnTLB(nucleo *Nucleo, numeroDePagina int) bool {
+	for _, entrada := range nucleo.TLB {
+		if entrada.ASID == nucleo.ejecutandoPID && entrada.NUMERO_PAG == numeroDePagina {
 			// hay que actualizar el tiempo de referencia??
 			return true // TLB Hit
 		}
@@ -479,48 +1436,50 @@ func EstaEnTLB(numeroDePagina int) bool {
 	return false // TLB Miss
 }
 
-func saveTLB(nroPagina int, nroMarco int) {
+func saveTLB(nucleo *Nucleo, nroPagina int, nroMarco int, soloLectura bool) {
 	nuevaEntradaTLB := EntradaTLB{
+		ASID:                    nucleo.ejecutandoPID,
 		NUMERO_PAG:              nroPagina,
 		NUMERO_MARCO:            nroMarco,
+		SOLO_LECTURA:            soloLectura,
 		TIEMPO_DESDE_REFERENCIA: time.Now(), //Agregar en READ tambien
 	}
 
-	if len(TLB) < ClientConfig.TLB_ENTRIES {
-		TLB = append(TLB, nuevaEntradaTLB) // Agregar nueva entrada si hay espacio
+	if len(nucleo.TLB) < ClientConfig.TLB_ENTRIES {
+		nucleo.TLB = append(nucleo.TLB, nuevaEntradaTLB) // Agregar nueva entrada si hay espacio
 
 		return
 	}
 	// Reemplazo de TLB
 	if algoritmoTLB == "FIFO" {
 		slog.Debug(fmt.Sprintf("Reemplazando entrada TLB por FIFO: Pagina %d, Marco %d", nuevaEntradaTLB.NUMERO_PAG, nuevaEntradaTLB.NUMERO_MARCO))
-		TLB = append(TLB[1:], nuevaEntradaTLB) // Reemplazamos siempre la primera entrada
+		nucleo.TLB = append(nucleo.TLB[1:], nuevaEntradaTLB) // Reemplazamos siempre la primera entrada
 	} else { // LRU: Ver si acomodamos la TLB antes de reemplazar y siempre sacar el primerop
 		indiceMenosUsado := 0
 		slog.Debug(fmt.Sprintf("Reemplazando entrada TLB por LRU: Pagina %d, Marco %d", nuevaEntradaTLB.NUMERO_PAG, nuevaEntradaTLB.NUMERO_MARCO))
 		// el que hace mas tiempo que no se referencia, es el que mas TIEMPO_DESDE_REFERENCIA tiene
-		for i, entrada := range TLB {
-			if entrada.TIEMPO_DESDE_REFERENCIA.Before(TLB[indiceMenosUsado].TIEMPO_DESDE_REFERENCIA) {
+		for i, entrada := range nucleo.TLB {
+			if entrada.TIEMPO_DESDE_REFERENCIA.Before(nucleo.TLB[indiceMenosUsado].TIEMPO_DESDE_REFERENCIA) {
 				indiceMenosUsado = i
 			}
 		}
-		slog.Debug(fmt.Sprintf("Reemplazando entrada TLB: %v", TLB[indiceMenosUsado]))
-		TLB[indiceMenosUsado] = nuevaEntradaTLB // Replazamos el indice de la posicionque hace mas tiempo no se referencia
+		slog.Debug(fmt.Sprintf("Reemplazando entrada TLB: %v", nucleo.TLB[indiceMenosUsado]))
+		nucleo.TLB[indiceMenosUsado] = nuevaEntradaTLB // Replazamos el indice de la posicionque hace mas tiempo no se referencia
 	}
 }
 
-func obtenerMarcoTLB(nroPagina int) int {
-	for _, entrada := range TLB {
-		if entrada.NUMERO_PAG == nroPagina {
-			return entrada.NUMERO_MARCO
+func obtenerMarcoTLB(nucleo *Nucleo, nroPagina int) (int, bool) {
+	for _, entrada := range nucleo.TLB {
+		if entrada.ASID == nucleo.ejecutandoPID && entrada.NUMERO_PAG == nroPagina {
+			return entrada.NUMERO_MARCO, entrada.SOLO_LECTURA
 		}
 	}
 	slog.Error(fmt.Sprintf("No se encontró el marco para la página %d en la TLB", nroPagina))
-	return -1 // Si no se encuentra, retornar un valor inválido
+	return -1, false // Si no se encuentra, retornar un valor inválido
 }
 
-func EliminarEntradasTLB() {
-	TLB = []EntradaTLB{} // Limpiar TLB
+func EliminarEntradasTLB(nucleo *Nucleo) {
+	nucleo.TLB = []EntradaTLB{} // Limpiar TLB
 	slog.Debug("Se han eliminado las entradas de la TLB del proceso")
 }
 
@@ -536,170 +1495,233 @@ func MMU(direccionLogica int) (entrada_nivel_X []int) {
 }
 
 // --------- MEMORIA CACHE --------- //
-func buscarEntradaCache(nroPagina int, direccionLogica int) (indiceEntradaCache int) {
-	for i := range MemoriaCache {
-		if MemoriaCache[i].nroPagina == nroPagina && MemoriaCache[i].entradaOcupada {
-			slog.Info(fmt.Sprintf("PID: %d - Cache Hit - Pagina: %d", ejecutandoPID, nroPagina)) // log obligatorio
-			MemoriaCache[i].bitDeUso = true                                                      // Actualizamos el bit de uso
-			return i
+func buscarEntradaCache(nucleo *Nucleo, nroPagina int, direccionLogica int) (indiceEntradaCache int, err error) {
+	for i := range nucleo.Cache.Entradas {
+		if nucleo.Cache.Entradas[i].nroPagina == nroPagina && nucleo.Cache.Entradas[i].asid == nucleo.ejecutandoPID && nucleo.Cache.Entradas[i].entradaOcupada {
+			slog.Info(fmt.Sprintf("PID: %d - Cache Hit - Pagina: %d", nucleo.ejecutandoPID, nroPagina), "pid", nucleo.ejecutandoPID, "page", nroPagina, "cpu_id", nucleo.ID) // log obligatorio
+			nucleo.Estadisticas.CACHE_HITS++
+			nucleo.acceso.Cache, nucleo.acceso.Marco = traza.Hit, nucleo.Cache.Entradas[i].nroMarco
+			nucleo.Cache.Entradas[i].tiempoUso = time.Now()
+			nucleo.Cache.Entradas[i].usos++
+			nucleo.Cache.Entradas[i].bitDeUso = true                                                      // Actualizamos el bit de uso
+			return i, nil
 		}
 	}
-	slog.Info(fmt.Sprintf("PID: %d - Cache Miss - Pagina: %d", ejecutandoPID, nroPagina)) // log obligatorio
+	slog.Info(fmt.Sprintf("PID: %d - Cache Miss - Pagina: %d", nucleo.ejecutandoPID, nroPagina), "pid", nucleo.ejecutandoPID, "page", nroPagina, "cpu_id", nucleo.ID) // log obligatorio
+	nucleo.Estadisticas.CACHE_MISSES++
+	nucleo.acceso.Cache = traza.Miss
 
-	nroMarco := traduccionDireccionLogica(nroPagina, direccionLogica)
+	nroMarco, soloLectura, err := traduccionDireccionLogica(nucleo, nroPagina, direccionLogica)
+	if err != nil {
+		return -1, err
+	}
 
 	direccionFisica := nroMarco * TamanioPagina // direccion fisica
 	peticion := globales.LeerMarcoMemoria{
 		DIRECCION: direccionFisica,
 	}
 
-	_, contenidoPagina := globales.GenerarYEnviarPaquete(&peticion, ClientConfig.IP_MEMORY, ClientConfig.PORT_MEMORY, "/cpu/leer_pagina")
+	contenidoPagina, err := globales.Llamar[[]byte](context.Background(), ClientConfig.IP_MEMORY, ClientConfig.PORT_MEMORY, "/cpu/leer_pagina", &peticion)
+	if err != nil {
+		return -1, fmt.Errorf("no se pudo leer la pagina %d: %w", nroPagina, err)
+	}
 
-	return cargarEntradaCache(nroPagina, nroMarco, contenidoPagina) //TODO: pasarle los datos que vienen de memoria
+	indiceEntradaCache = cargarEntradaCache(nucleo, nroPagina, nroMarco, contenidoPagina) //TODO: pasarle los datos que vienen de memoria
+	nucleo.Cache.Entradas[indiceEntradaCache].soloLectura = soloLectura
+	return indiceEntradaCache, nil
 }
 
-func cargarEntradaCache(nroPagina int, nroMarco int, contenidoPagina []byte) (indiceEntradaCache int) {
-	for i := range MemoriaCache {
-		if !MemoriaCache[i].entradaOcupada {
-			MemoriaCache[i].nroPagina = nroPagina
-			MemoriaCache[i].Datos = contenidoPagina // Inicializamos los datos como un slice vacio
-			MemoriaCache[i].bitDeUso = true
-			MemoriaCache[i].bitModificado = false
-			MemoriaCache[i].entradaOcupada = true
-
-			MemoriaCache[i].nroMarco = nroMarco                                                  // Guardamos el nro de marco para facilitar la traduccion de direccion logica a fisica
-			punteroMemoriaCache = i + 1                                                          // Actualizamos el puntero de la cache
-			slog.Info(fmt.Sprintf("PID: %d - Cache Add - Pagina: %d", ejecutandoPID, nroPagina)) // log obligatorio
+func cargarEntradaCache(nucleo *Nucleo, nroPagina int, nroMarco int, contenidoPagina []byte) (indiceEntradaCache int) {
+	for i := range nucleo.Cache.Entradas {
+		if !nucleo.Cache.Entradas[i].entradaOcupada {
+			nucleo.Cache.Entradas[i].nroPagina = nroPagina
+			nucleo.Cache.Entradas[i].asid = nucleo.ejecutandoPID
+			nucleo.Cache.Entradas[i].tiempoCarga = time.Now()
+			nucleo.Cache.Entradas[i].tiempoUso = time.Now()
+			nucleo.Cache.Entradas[i].usos = 1
+			nucleo.Cache.Entradas[i].Datos = contenidoPagina // Inicializamos los datos como un slice vacio
+			nucleo.Cache.Entradas[i].bitDeUso = true
+			nucleo.Cache.Entradas[i].bitModificado = false
+			nucleo.Cache.Entradas[i].entradaOcupada = true
+
+			nucleo.Cache.Entradas[i].nroMarco = nroMarco                                                  // Guardamos el nro de marco para facilitar la traduccion de direccion logica a fisica
+			nucleo.Cache.puntero = i + 1                                                          // Actualizamos el puntero de la cache
+			slog.Info(fmt.Sprintf("PID: %d - Cache Add - Pagina: %d", nucleo.ejecutandoPID, nroPagina), "pid", nucleo.ejecutandoPID, "page", nroPagina, "cpu_id", nucleo.ID) // log obligatorio
 			return i
 		}
 	}
-	return remplazarEntradaCache(nroPagina, nroMarco, contenidoPagina)
+	return remplazarEntradaCache(nucleo, nroPagina, nroMarco, contenidoPagina)
 
 }
 
-func remplazarEntradaCache(nroPagina int, nroMarco int, contenidoPagina []byte) (indiceEntradaCache int) {
+func remplazarEntradaCache(nucleo *Nucleo, nroPagina int, nroMarco int, contenidoPagina []byte) (indiceEntradaCache int) {
+	if algoritmoCache == "LRU" || algoritmoCache == "LFU" || algoritmoCache == "FIFO" {
+		i := elegirVictimaCache(nucleo)
+		slog.Debug(fmt.Sprintf("Reemplazando entrada de cache por %s: Pagina %d, Entrada %d", algoritmoCache, nucleo.Cache.Entradas[i].nroPagina, i))
+
+		if nucleo.Cache.Entradas[i].bitModificado {
+			desalojarPaginaModificada(nucleo, i)
+		}
+
+		nucleo.Cache.Entradas[i].nroPagina = nroPagina
+		nucleo.Cache.Entradas[i].asid = nucleo.ejecutandoPID
+		nucleo.Cache.Entradas[i].tiempoCarga = time.Now()
+		nucleo.Cache.Entradas[i].tiempoUso = time.Now()
+		nucleo.Cache.Entradas[i].usos = 1
+		nucleo.Cache.Entradas[i].Datos = contenidoPagina
+		nucleo.Cache.Entradas[i].bitDeUso = true
+		nucleo.Cache.Entradas[i].bitModificado = false
+
+		nucleo.Cache.Entradas[i].nroMarco = nroMarco
+		slog.Info(fmt.Sprintf("PID: %d - Cache Add - Pagina: %d", nucleo.ejecutandoPID, nroPagina), "pid", nucleo.ejecutandoPID, "page", nroPagina, "cpu_id", nucleo.ID) // log obligatorio
+		return i
+	}
 	if algoritmoCache == "CLOCK" {
 		slog.Debug(fmt.Sprintf("Reemplazando entrada de cache por CLOCK: Pagina %d", nroPagina))
 		for {
-			for i := punteroMemoriaCache; i < len(MemoriaCache); i++ {
-				if !MemoriaCache[i].bitDeUso && MemoriaCache[i].entradaOcupada {
-					slog.Debug(fmt.Sprintf("Reemplazando entrada de cache: Pagina %d, Entrada %d", MemoriaCache[i].nroPagina, i))
+			for i := nucleo.Cache.puntero; i < len(nucleo.Cache.Entradas); i++ {
+				if !nucleo.Cache.Entradas[i].bitDeUso && nucleo.Cache.Entradas[i].entradaOcupada {
+					slog.Debug(fmt.Sprintf("Reemplazando entrada de cache: Pagina %d, Entrada %d", nucleo.Cache.Entradas[i].nroPagina, i))
 
-					if MemoriaCache[i].bitModificado {
-						escribirPaginaCacheEnMemoria(i)
+					if nucleo.Cache.Entradas[i].bitModificado {
+						desalojarPaginaModificada(nucleo, i)
 					}
 
-					MemoriaCache[i].nroPagina = nroPagina
-					MemoriaCache[i].Datos = contenidoPagina // Inicializamos los datos como un slice vacio
-					MemoriaCache[i].bitDeUso = true
-					MemoriaCache[i].bitModificado = false
-
-					MemoriaCache[i].nroMarco = nroMarco                                                  // Guardamos el nro de marco para facilitar la traduccion de direccion logica a fisica
-					punteroMemoriaCache = i + 1                                                          // Actualizamos el puntero de la cache
-					slog.Info(fmt.Sprintf("PID: %d - Cache Add - Pagina: %d", ejecutandoPID, nroPagina)) // log obligatorio
+					nucleo.Cache.Entradas[i].nroPagina = nroPagina
+					nucleo.Cache.Entradas[i].asid = nucleo.ejecutandoPID
+					nucleo.Cache.Entradas[i].tiempoCarga = time.Now()
+					nucleo.Cache.Entradas[i].tiempoUso = time.Now()
+					nucleo.Cache.Entradas[i].usos = 1
+					nucleo.Cache.Entradas[i].Datos = contenidoPagina // Inicializamos los datos como un slice vacio
+					nucleo.Cache.Entradas[i].bitDeUso = true
+					nucleo.Cache.Entradas[i].bitModificado = false
+
+					nucleo.Cache.Entradas[i].nroMarco = nroMarco                                                  // Guardamos el nro de marco para facilitar la traduccion de direccion logica a fisica
+					nucleo.Cache.puntero = i + 1                                                          // Actualizamos el puntero de la cache
+					slog.Info(fmt.Sprintf("PID: %d - Cache Add - Pagina: %d", nucleo.ejecutandoPID, nroPagina), "pid", nucleo.ejecutandoPID, "page", nroPagina, "cpu_id", nucleo.ID) // log obligatorio
 					return i
 				} else {
 
-					slog.Debug(fmt.Sprintf("Entrada de cache: Pagina %d, Entrada %d, Bit de uso: %t", MemoriaCache[i].nroPagina, i, MemoriaCache[i].bitDeUso))
-					MemoriaCache[i].bitDeUso = false // Reiniciamos el bit de uso
+					slog.Debug(fmt.Sprintf("Entrada de cache: Pagina %d, Entrada %d, Bit de uso: %t", nucleo.Cache.Entradas[i].nroPagina, i, nucleo.Cache.Entradas[i].bitDeUso))
+					nucleo.Cache.Entradas[i].bitDeUso = false // Reiniciamos el bit de uso
 
 				}
 			}
-			for i := 0; i < punteroMemoriaCache; i++ {
-				if !MemoriaCache[i].bitDeUso && MemoriaCache[i].entradaOcupada {
-					slog.Debug(fmt.Sprintf("Reemplazando entrada de cache: Pagina %d, Entrada %d", MemoriaCache[i].nroPagina, i))
+			for i := 0; i < nucleo.Cache.puntero; i++ {
+				if !nucleo.Cache.Entradas[i].bitDeUso && nucleo.Cache.Entradas[i].entradaOcupada {
+					slog.Debug(fmt.Sprintf("Reemplazando entrada de cache: Pagina %d, Entrada %d", nucleo.Cache.Entradas[i].nroPagina, i))
 
-					if MemoriaCache[i].bitModificado {
-						escribirPaginaCacheEnMemoria(i)
+					if nucleo.Cache.Entradas[i].bitModificado {
+						desalojarPaginaModificada(nucleo, i)
 					}
 
-					MemoriaCache[i].nroPagina = nroPagina
-					MemoriaCache[i].Datos = contenidoPagina
-					MemoriaCache[i].bitDeUso = true
-					MemoriaCache[i].bitModificado = false
-
-					MemoriaCache[i].nroMarco = nroMarco // Guardamos el nro de marco para facilitar la traduccion de direccion logica a fisica
-					punteroMemoriaCache = i + 1
-					slog.Info(fmt.Sprintf("PID: %d - Cache Add - Pagina: %d", ejecutandoPID, nroPagina)) // log obligatorio
+					nucleo.Cache.Entradas[i].nroPagina = nroPagina
+					nucleo.Cache.Entradas[i].asid = nucleo.ejecutandoPID
+					nucleo.Cache.Entradas[i].tiempoCarga = time.Now()
+					nucleo.Cache.Entradas[i].tiempoUso = time.Now()
+					nucleo.Cache.Entradas[i].usos = 1
+					nucleo.Cache.Entradas[i].Datos = contenidoPagina
+					nucleo.Cache.Entradas[i].bitDeUso = true
+					nucleo.Cache.Entradas[i].bitModificado = false
+
+					nucleo.Cache.Entradas[i].nroMarco = nroMarco // Guardamos el nro de marco para facilitar la traduccion de direccion logica a fisica
+					nucleo.Cache.puntero = i + 1
+					slog.Info(fmt.Sprintf("PID: %d - Cache Add - Pagina: %d", nucleo.ejecutandoPID, nroPagina), "pid", nucleo.ejecutandoPID, "page", nroPagina, "cpu_id", nucleo.ID) // log obligatorio
 					return i
 				} else {
-					slog.Debug(fmt.Sprintf("Entrada de cache: Pagina %d, Entrada %d, Bit de uso: %t", MemoriaCache[i].nroPagina, i, MemoriaCache[i].bitDeUso))
-					MemoriaCache[i].bitDeUso = false // Reiniciamos el bit de uso
+					slog.Debug(fmt.Sprintf("Entrada de cache: Pagina %d, Entrada %d, Bit de uso: %t", nucleo.Cache.Entradas[i].nroPagina, i, nucleo.Cache.Entradas[i].bitDeUso))
+					nucleo.Cache.Entradas[i].bitDeUso = false // Reiniciamos el bit de uso
 				}
 			}
 		}
 	}
 	if algoritmoCache == "CLOCK-M" {
 		for {
-			for i := punteroMemoriaCache; i < len(MemoriaCache); i++ {
-				if MemoriaCache[i].entradaOcupada && !MemoriaCache[i].bitDeUso && !MemoriaCache[i].bitModificado {
-					slog.Debug(fmt.Sprintf("Reemplazando entrada de cache: Pagina %d, Entrada %d, Bit de uso: %t, Bit modificado: %t", MemoriaCache[i].nroPagina, i, MemoriaCache[i].bitDeUso, MemoriaCache[i].bitModificado))
-
-					MemoriaCache[i].nroPagina = nroPagina
-					MemoriaCache[i].Datos = contenidoPagina
-					MemoriaCache[i].bitDeUso = true
-					MemoriaCache[i].bitModificado = false
-
-					MemoriaCache[i].nroMarco = nroMarco                                                  // Guardamos el nro de marco para facilitar la traduccion de direccion logica a fisica
-					punteroMemoriaCache = i + 1                                                          // Actualizamos el puntero de la cache
-					slog.Info(fmt.Sprintf("PID: %d - Cache Add - Pagina: %d", ejecutandoPID, nroPagina)) // log obligatorio
+			for i := nucleo.Cache.puntero; i < len(nucleo.Cache.Entradas); i++ {
+				if nucleo.Cache.Entradas[i].entradaOcupada && !nucleo.Cache.Entradas[i].bitDeUso && !nucleo.Cache.Entradas[i].bitModificado {
+					slog.Debug(fmt.Sprintf("Reemplazando entrada de cache: Pagina %d, Entrada %d, Bit de uso: %t, Bit modificado: %t", nucleo.Cache.Entradas[i].nroPagina, i, nucleo.Cache.Entradas[i].bitDeUso, nucleo.Cache.Entradas[i].bitModificado))
+
+					nucleo.Cache.Entradas[i].nroPagina = nroPagina
+					nucleo.Cache.Entradas[i].asid = nucleo.ejecutandoPID
+					nucleo.Cache.Entradas[i].tiempoCarga = time.Now()
+					nucleo.Cache.Entradas[i].tiempoUso = time.Now()
+					nucleo.Cache.Entradas[i].usos = 1
+					nucleo.Cache.Entradas[i].Datos = contenidoPagina
+					nucleo.Cache.Entradas[i].bitDeUso = true
+					nucleo.Cache.Entradas[i].bitModificado = false
+
+					nucleo.Cache.Entradas[i].nroMarco = nroMarco                                                  // Guardamos el nro de marco para facilitar la traduccion de direccion logica a fisica
+					nucleo.Cache.puntero = i + 1                                                          // Actualizamos el puntero de la cache
+					slog.Info(fmt.Sprintf("PID: %d - Cache Add - Pagina: %d", nucleo.ejecutandoPID, nroPagina), "pid", nucleo.ejecutandoPID, "page", nroPagina, "cpu_id", nucleo.ID) // log obligatorio
 					return i
 				}
 			}
-			for i := 0; i < punteroMemoriaCache; i++ {
-				if MemoriaCache[i].entradaOcupada && !MemoriaCache[i].bitDeUso && !MemoriaCache[i].bitModificado {
-					slog.Debug(fmt.Sprintf("Reemplazando entrada de cache: Pagina %d, Entrada %d, Bit de uso: %t, Bit modificado: %t", MemoriaCache[i].nroPagina, i, MemoriaCache[i].bitDeUso, MemoriaCache[i].bitModificado))
-
-					MemoriaCache[i].nroPagina = nroPagina
-					MemoriaCache[i].Datos = contenidoPagina
-					MemoriaCache[i].bitDeUso = true
-					MemoriaCache[i].bitModificado = false
-
-					MemoriaCache[i].nroMarco = nroMarco                                                  // Guardamos el nro de marco para facilitar la traduccion de direccion logica a fisica
-					punteroMemoriaCache = i + 1                                                          // Actualizamos el puntero de la cache
-					slog.Info(fmt.Sprintf("PID: %d - Cache Add - Pagina: %d", ejecutandoPID, nroPagina)) // log obligatorio
+			for i := 0; i < nucleo.Cache.puntero; i++ {
+				if nucleo.Cache.Entradas[i].entradaOcupada && !nucleo.Cache.Entradas[i].bitDeUso && !nucleo.Cache.Entradas[i].bitModificado {
+					slog.Debug(fmt.Sprintf("Reemplazando entrada de cache: Pagina %d, Entrada %d, Bit de uso: %t, Bit modificado: %t", nucleo.Cache.Entradas[i].nroPagina, i, nucleo.Cache.Entradas[i].bitDeUso, nucleo.Cache.Entradas[i].bitModificado))
+
+					nucleo.Cache.Entradas[i].nroPagina = nroPagina
+					nucleo.Cache.Entradas[i].asid = nucleo.ejecutandoPID
+					nucleo.Cache.Entradas[i].tiempoCarga = time.Now()
+					nucleo.Cache.Entradas[i].tiempoUso = time.Now()
+					nucleo.Cache.Entradas[i].usos = 1
+					nucleo.Cache.Entradas[i].Datos = contenidoPagina
+					nucleo.Cache.Entradas[i].bitDeUso = true
+					nucleo.Cache.Entradas[i].bitModificado = false
+
+					nucleo.Cache.Entradas[i].nroMarco = nroMarco                                                  // Guardamos el nro de marco para facilitar la traduccion de direccion logica a fisica
+					nucleo.Cache.puntero = i + 1                                                          // Actualizamos el puntero de la cache
+					slog.Info(fmt.Sprintf("PID: %d - Cache Add - Pagina: %d", nucleo.ejecutandoPID, nroPagina), "pid", nucleo.ejecutandoPID, "page", nroPagina, "cpu_id", nucleo.ID) // log obligatorio
 					return i
 				}
 			}
 
-			for i := punteroMemoriaCache; i < len(MemoriaCache); i++ {
-				if MemoriaCache[i].entradaOcupada && !MemoriaCache[i].bitDeUso && MemoriaCache[i].bitModificado {
-					slog.Debug(fmt.Sprintf("Reemplazando entrada de cache: Pagina %d, Entrada %d, Bit de uso: %t, Bit modificado: %t", MemoriaCache[i].nroPagina, i, MemoriaCache[i].bitDeUso, MemoriaCache[i].bitModificado))
+			for i := nucleo.Cache.puntero; i < len(nucleo.Cache.Entradas); i++ {
+				if nucleo.Cache.Entradas[i].entradaOcupada && !nucleo.Cache.Entradas[i].bitDeUso && nucleo.Cache.Entradas[i].bitModificado {
+					slog.Debug(fmt.Sprintf("Reemplazando entrada de cache: Pagina %d, Entrada %d, Bit de uso: %t, Bit modificado: %t", nucleo.Cache.Entradas[i].nroPagina, i, nucleo.Cache.Entradas[i].bitDeUso, nucleo.Cache.Entradas[i].bitModificado))
 
-					escribirPaginaCacheEnMemoria(i)
+					desalojarPaginaModificada(nucleo, i)
 
-					MemoriaCache[i].nroPagina = nroPagina
-					MemoriaCache[i].Datos = contenidoPagina
-					MemoriaCache[i].bitDeUso = true
-					MemoriaCache[i].bitModificado = false
+					nucleo.Cache.Entradas[i].nroPagina = nroPagina
+					nucleo.Cache.Entradas[i].asid = nucleo.ejecutandoPID
+					nucleo.Cache.Entradas[i].tiempoCarga = time.Now()
+					nucleo.Cache.Entradas[i].tiempoUso = time.Now()
+					nucleo.Cache.Entradas[i].usos = 1
+					nucleo.Cache.Entradas[i].Datos = contenidoPagina
+					nucleo.Cache.Entradas[i].bitDeUso = true
+					nucleo.Cache.Entradas[i].bitModificado = false
 
-					MemoriaCache[i].nroMarco = nroMarco                                                  // Guardamos el nro de marco para facilitar la traduccion de direccion logica a fisica
-					punteroMemoriaCache = i + 1                                                          // Actualizamos el puntero de la cache
-					slog.Info(fmt.Sprintf("PID: %d - Cache Add - Pagina: %d", ejecutandoPID, nroPagina)) // log obligatorio
+					nucleo.Cache.Entradas[i].nroMarco = nroMarco                                                  // Guardamos el nro de marco para facilitar la traduccion de direccion logica a fisica
+					nucleo.Cache.puntero = i + 1                                                          // Actualizamos el puntero de la cache
+					slog.Info(fmt.Sprintf("PID: %d - Cache Add - Pagina: %d", nucleo.ejecutandoPID, nroPagina), "pid", nucleo.ejecutandoPID, "page", nroPagina, "cpu_id", nucleo.ID) // log obligatorio
 					return i
 				} else {
-					slog.Debug(fmt.Sprintf("Entrada de cache: Pagina %d, Entrada %d, Bit de uso: %t, Bit modificado: %t", MemoriaCache[i].nroPagina, i, MemoriaCache[i].bitDeUso, MemoriaCache[i].bitModificado))
-					MemoriaCache[i].bitDeUso = false // Reiniciamos el bit de uso
+					slog.Debug(fmt.Sprintf("Entrada de cache: Pagina %d, Entrada %d, Bit de uso: %t, Bit modificado: %t", nucleo.Cache.Entradas[i].nroPagina, i, nucleo.Cache.Entradas[i].bitDeUso, nucleo.Cache.Entradas[i].bitModificado))
+					nucleo.Cache.Entradas[i].bitDeUso = false // Reiniciamos el bit de uso
 				}
 			}
-			for i := 0; i < punteroMemoriaCache; i++ {
-				if MemoriaCache[i].entradaOcupada && !MemoriaCache[i].bitDeUso && MemoriaCache[i].bitModificado {
-					slog.Debug(fmt.Sprintf("Reemplazando entrada de cache: Pagina %d, Entrada %d, Bit de uso: %t, Bit modificado: %t", MemoriaCache[i].nroPagina, i, MemoriaCache[i].bitDeUso, MemoriaCache[i].bitModificado))
-
-					escribirPaginaCacheEnMemoria(i)
-
-					MemoriaCache[i].nroPagina = nroPagina
-					MemoriaCache[i].Datos = contenidoPagina
-					MemoriaCache[i].bitDeUso = true
-					MemoriaCache[i].bitModificado = false
-
-					MemoriaCache[i].nroMarco = nroMarco                                                  // Guardamos el nro de marco para facilitar la traduccion de direccion logica a fisica
-					punteroMemoriaCache = i + 1                                                          // Actualizamos el puntero de la cache
-					slog.Info(fmt.Sprintf("PID: %d - Cache Add - Pagina: %d", ejecutandoPID, nroPagina)) // log obligatorio
+			for i := 0; i < nucleo.Cache.puntero; i++ {
+				if nucleo.Cache.Entradas[i].entradaOcupada && !nucleo.Cache.Entradas[i].bitDeUso && nucleo.Cache.Entradas[i].bitModificado {
+					slog.Debug(fmt.Sprintf("Reemplazando entrada de cache: Pagina %d, Entrada %d, Bit de uso: %t, Bit modificado: %t", nucleo.Cache.Entradas[i].nroPagina, i, nucleo.Cache.Entradas[i].bitDeUso, nucleo.Cache.Entradas[i].bitModificado))
+
+					desalojarPaginaModificada(nucleo, i)
+
+					nucleo.Cache.Entradas[i].nroPagina = nroPagina
+					nucleo.Cache.Entradas[i].asid = nucleo.ejecutandoPID
+					nucleo.Cache.Entradas[i].tiempoCarga = time.Now()
+					nucleo.Cache.Entradas[i].tiempoUso = time.Now()
+					nucleo.Cache.Entradas[i].usos = 1
+					nucleo.Cache.Entradas[i].Datos = contenidoPagina
+					nucleo.Cache.Entradas[i].bitDeUso = true
+					nucleo.Cache.Entradas[i].bitModificado = false
+
+					nucleo.Cache.Entradas[i].nroMarco = nroMarco                                                  // Guardamos el nro de marco para facilitar la traduccion de direccion logica a fisica
+					nucleo.Cache.puntero = i + 1                                                          // Actualizamos el puntero de la cache
+					slog.Info(fmt.Sprintf("PID: %d - Cache Add - Pagina: %d", nucleo.ejecutandoPID, nroPagina), "pid", nucleo.ejecutandoPID, "page", nroPagina, "cpu_id", nucleo.ID) // log obligatorio
 					return i
 				} else {
-					slog.Debug(fmt.Sprintf("Entrada de cache: Pagina %d, Entrada %d, Bit de uso: %t, Bit modificado: %t", MemoriaCache[i].nroPagina, i, MemoriaCache[i].bitDeUso, MemoriaCache[i].bitModificado))
-					MemoriaCache[i].bitDeUso = false // Reiniciamos el bit de uso
+					slog.Debug(fmt.Sprintf("Entrada de cache: Pagina %d, Entrada %d, Bit de uso: %t, Bit modificado: %t", nucleo.Cache.Entradas[i].nroPagina, i, nucleo.Cache.Entradas[i].bitDeUso, nucleo.Cache.Entradas[i].bitModificado))
+					nucleo.Cache.Entradas[i].bitDeUso = false // Reiniciamos el bit de uso
 				}
 			}
 		}
@@ -708,41 +1730,289 @@ func remplazarEntradaCache(nroPagina int, nroMarco int, contenidoPagina []byte)
 	return -1 // Me pide un return pero nunca deberia llegar a este punto
 }
 
-func escribirPaginaCacheEnMemoria(indiceEntradaCache int) {
-	if MemoriaCache[indiceEntradaCache].bitModificado {
-		direccionFisica := MemoriaCache[indiceEntradaCache].nroMarco * TamanioPagina
+func estaEnCache(nucleo *Nucleo, nroPagina int) bool {
+	for _, entrada := range nucleo.Cache.Entradas {
+		if entrada.entradaOcupada && entrada.asid == nucleo.ejecutandoPID && entrada.nroPagina == nroPagina {
+			return true
+		}
+	}
+	return false
+}
+
+// Elige la entrada a reemplazar para FIFO, LRU y LFU (la cache esta llena)
+func elegirVictimaCache(nucleo *Nucleo) int {
+	victima := 0
+	for i, entrada := range nucleo.Cache.Entradas {
+		candidata := nucleo.Cache.Entradas[victima]
+		switch algoritmoCache {
+		case "FIFO": // la que se cargo hace mas tiempo
+			if entrada.tiempoCarga.Before(candidata.tiempoCarga) {
+				victima = i
+			}
+		case "LRU": // la que se referencio hace mas tiempo
+			if entrada.tiempoUso.Before(candidata.tiempoUso) {
+				victima = i
+			}
+		case "LFU": // la menos referenciada, en caso de empate la de uso mas viejo
+			if entrada.usos < candidata.usos || (entrada.usos == candidata.usos && entrada.tiempoUso.Before(candidata.tiempoUso)) {
+				victima = i
+			}
+		}
+	}
+	return victima
+}
+
+func desalojarPaginaModificada(nucleo *Nucleo, indiceEntradaCache int) {
+	nucleo.Estadisticas.DESALOJOS_SUCIOS++
+	escribirPaginaCacheEnMemoria(nucleo, indiceEntradaCache)
+}
+
+// Si memoria no acepta la pagina queda el error en el log; la devuelve para el que pueda frenar al proceso
+func escribirPaginaCacheEnMemoria(nucleo *Nucleo, indiceEntradaCache int) error {
+	if nucleo.Cache.Entradas[indiceEntradaCache].bitModificado {
+		direccionFisica := nucleo.Cache.Entradas[indiceEntradaCache].nroMarco * TamanioPagina
 
 		peticion := globales.EscribirMarcoMemoria{
 			DIRECCION: direccionFisica,
-			PID:       ejecutandoPID,
-			DATOS:     MemoriaCache[indiceEntradaCache].Datos,
+			PID:       nucleo.Cache.Entradas[indiceEntradaCache].asid,
+			DATOS:     nucleo.Cache.Entradas[indiceEntradaCache].Datos,
 		}
 
-		resp, _ := globales.GenerarYEnviarPaquete(&peticion, ClientConfig.IP_MEMORY, ClientConfig.PORT_MEMORY, "/cpu/escribir_pagina")
-		if resp.StatusCode != http.StatusOK {
-			slog.Error(fmt.Sprintf("Error al escribir en memoria: %s", resp.Status))
-			return
+		nucleo.Estadisticas.ESCRITURAS_PAGINA++
+		nucleo.Estadisticas.BYTES_ESCRITOS_MEMORIA += len(peticion.DATOS)
+		if err := globales.Enviar(context.Background(), ClientConfig.IP_MEMORY, ClientConfig.PORT_MEMORY, "/cpu/escribir_pagina", &peticion); err != nil {
+			slog.Error(fmt.Sprintf("PID: %d - Error al escribir la pagina %d en memoria: %v", peticion.PID, nucleo.Cache.Entradas[indiceEntradaCache].nroPagina, err))
+			return err
 		} else {
-			slog.Info(fmt.Sprintf("PID: %d - Memory Update - Página: %d - Frame: %d", ejecutandoPID, MemoriaCache[indiceEntradaCache].nroPagina, MemoriaCache[indiceEntradaCache].nroMarco)) // log obligatorio
+			slog.Info(fmt.Sprintf("PID: %d - Memory Update - Página: %d - Frame: %d", nucleo.Cache.Entradas[indiceEntradaCache].asid, nucleo.Cache.Entradas[indiceEntradaCache].nroPagina, nucleo.Cache.Entradas[indiceEntradaCache].nroMarco), "pid", nucleo.Cache.Entradas[indiceEntradaCache].asid, "page", nucleo.Cache.Entradas[indiceEntradaCache].nroPagina, "frame", nucleo.Cache.Entradas[indiceEntradaCache].nroMarco, "cpu_id", nucleo.ID) // log obligatorio
+		}
+
+	}
+	return nil
+}
+
+// Baja a memoria las paginas modificadas del proceso que estaba ejecutando y libera sus entradas.
+// Con cache compartida las paginas de los procesos de otros nucleos quedan como estan
+func limpiarCache(nucleo *Nucleo) {
+	nucleo.Cache.mutex.Lock()
+	defer nucleo.Cache.mutex.Unlock()
+
+	for i := range nucleo.Cache.Entradas {
+		if !nucleo.Cache.Entradas[i].entradaOcupada || nucleo.Cache.Entradas[i].asid != nucleo.ejecutandoPID {
+			continue
+		}
+		if nucleo.Cache.Entradas[i].bitModificado {
+			escribirPaginaCacheEnMemoria(nucleo, i)
+		}
+		nucleo.Cache.Entradas[i] = entradaCacheVacia()
+	}
+	if !ClientConfig.CACHE_SHARED {
+		nucleo.Cache.puntero = 0 // Reiniciamos el puntero de la cache
+	}
+	slog.Debug(fmt.Sprintf("Se ha limpiado la cache del CPU %s", nucleo.ID))
+}
+
+// Baja a memoria las paginas modificadas del proceso que estaba ejecutando y las deja limpias en la cache
+func escribirPaginasModificadas(nucleo *Nucleo) {
+	nucleo.Cache.mutex.Lock()
+	defer nucleo.Cache.mutex.Unlock()
+
+	for i := range nucleo.Cache.Entradas {
+		entrada := &nucleo.Cache.Entradas[i]
+		if !entrada.entradaOcupada || entrada.asid != nucleo.ejecutandoPID || !entrada.bitModificado {
+			continue
+		}
+		if escribirPaginaCacheEnMemoria(nucleo, i) == nil {
+			entrada.bitModificado = false
+		}
+	}
+}
+
+func entradaCacheVacia() EntradaCache {
+	return EntradaCache{
+		nroPagina:      -1,                          // Inicializamos con -1 para indicar que no hay pagina cargada
+		Datos:          make([]byte, TamanioPagina), // Inicializamos los datos como un slice vacio
+		nroMarco:       -1,                          // Inicializamos con -1 para indicar que no hay marco cargado
+		bitDeUso:       false,
+		bitModificado:  false,
+		entradaOcupada: false,
+	}
+}
+
+// --------- COHERENCIA ENTRE NUCLEOS (MSI) --------- //
+// Con caches privadas cada entrada esta en M (ocupada y modificada), S (ocupada) o I (libre).
+// Antes de leer, una copia M de otro nucleo se baja a memoria y pasa a S; antes de escribir,
+// ademas se invalidan las copias de los otros nucleos. Nunca se toman dos mutex de cache a la vez.
+// El snoop y el acceso a la cache propia se hacen con mutexCoherencia tomado, asi ningun nucleo
+// carga la pagina entre la invalidacion y la escritura. Orden: mutexCoherencia -> Cache.mutex.
+var mutexCoherencia sync.Mutex
+
+func coherenciaMSI() bool {
+	return len(Nucleos) >= 2 && !ClientConfig.CACHE_SHARED
+}
+
+func snoopOtrosNucleos(nucleo *Nucleo, nroPagina int, invalidar bool) {
+	if !coherenciaMSI() {
+		return
+	}
+
+	for _, otro := range Nucleos {
+		if otro == nucleo {
+			continue
 		}
+		otro.Cache.mutex.Lock()
+		for i := range otro.Cache.Entradas {
+			entrada := &otro.Cache.Entradas[i]
+			if !entrada.entradaOcupada || entrada.asid != nucleo.ejecutandoPID || entrada.nroPagina != nroPagina {
+				continue
+			}
+			if entrada.bitModificado {
+				slog.Debug(fmt.Sprintf("MSI - PID: %d - Pagina: %d - CPU %s: M -> S", entrada.asid, nroPagina, otro.ID))
+				escribirPaginaCacheEnMemoria(otro, i)
+				entrada.bitModificado = false
+			}
+			if invalidar {
+				slog.Debug(fmt.Sprintf("MSI - PID: %d - Pagina: %d - CPU %s: S -> I", entrada.asid, nroPagina, otro.ID))
+				*entrada = entradaCacheVacia()
+			}
+		}
+		otro.Cache.mutex.Unlock()
+	}
+}
+
+// --------- INVALIDACION POR ASID --------- //
+// Memoria avisa cuando un PID se finaliza, se manda a swap o se dumpea.
+// Al finalizar se descartan sus entradas sin escribir nada; con SWAP primero se bajan las
+// paginas modificadas y despues se invalidan; con DUMP solo se bajan.
+func InvalidarASID(w http.ResponseWriter, r *http.Request) {
+	var peticion globales.InvalidacionASID
+	peticion = globales.DecodificarPaquete(w, r, &peticion)
+
+	invalidar := peticion.MOTIVO != "DUMP"
+	escribirModificadas := peticion.MOTIVO != "FINALIZAR"
 
+	var cachesRecorridas []*CacheDatos
+	for _, nucleo := range Nucleos {
+		if invalidar {
+			nucleo.mutexInvalidaciones.Lock()
+			nucleo.invalidacionesPendientes = append(nucleo.invalidacionesPendientes, peticion)
+			nucleo.mutexInvalidaciones.Unlock()
+		}
+		nucleo.traza.Registrar(traza.Evento{Tipo: traza.Invalidacion, PID: peticion.PID, PC: -1, Detalle: peticion.MOTIVO})
+
+		if slices.Contains(cachesRecorridas, nucleo.Cache) {
+			continue // cache compartida, ya se recorrio
+		}
+		cachesRecorridas = append(cachesRecorridas, nucleo.Cache)
+
+		nucleo.Cache.mutex.Lock()
+		for i := range nucleo.Cache.Entradas {
+			entrada := &nucleo.Cache.Entradas[i]
+			if !entrada.entradaOcupada || entrada.asid != peticion.PID {
+				continue
+			}
+			if escribirModificadas && entrada.bitModificado {
+				escribirPaginaCacheEnMemoria(nucleo, i)
+				entrada.bitModificado = false
+			}
+			if invalidar {
+				*entrada = entradaCacheVacia()
+			}
+		}
+		nucleo.Cache.mutex.Unlock()
 	}
+
+	slog.Debug(fmt.Sprintf("PID: %d - Entradas invalidadas por %s", peticion.PID, peticion.MOTIVO))
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
 }
 
-func limpiarCache() {
-	for i := range MemoriaCache {
-		if MemoriaCache[i].bitModificado {
-			escribirPaginaCacheEnMemoria(i)
+// Saca de la TLB (y de la cache de instrucciones si el proceso termino) las entradas invalidadas por memoria
+func aplicarInvalidaciones(nucleo *Nucleo) {
+	nucleo.mutexInvalidaciones.Lock()
+	pendientes := nucleo.invalidacionesPendientes
+	nucleo.invalidacionesPendientes = nil
+	nucleo.mutexInvalidaciones.Unlock()
+
+	for _, invalidacion := range pendientes {
+		tlbFiltrada := nucleo.TLB[:0]
+		for _, entrada := range nucleo.TLB {
+			if entrada.ASID != invalidacion.PID {
+				tlbFiltrada = append(tlbFiltrada, entrada)
+			}
 		}
-		MemoriaCache[i] = EntradaCache{
-			nroPagina:      -1,                          // Inicializamos con -1 para indicar que no hay pagina cargada
-			Datos:          make([]byte, TamanioPagina), // Inicializamos los datos como un slice vacio
-			nroMarco:       -1,                          // Inicializamos con -1 para indicar que no hay marco cargado
-			bitDeUso:       false,
-			bitModificado:  false,
-			entradaOcupada: false,
+		nucleo.TLB = tlbFiltrada
+
+		if invalidacion.MOTIVO == "FINALIZAR" {
+			icacheFiltrada := nucleo.ICache[:0]
+			for _, entrada := range nucleo.ICache {
+				if entrada.PID != invalidacion.PID {
+					icacheFiltrada = append(icacheFiltrada, entrada)
+				}
+			}
+			nucleo.ICache = icacheFiltrada
 		}
 	}
-	punteroMemoriaCache = 0 // Reiniciamos el puntero de la cache
-	slog.Debug("Se ha limpiado la cache del CPU")
+}
+
+// Loguea los aciertos de TLB y cache de cada nucleo
+func ReportarEstadisticas() {
+	for _, nucleo := range Nucleos {
+		e := nucleo.Estadisticas
+		slog.Info(fmt.Sprintf("CPU %s - ASIDs: %t - TLB: %d hits, %d misses (%.1f%%) - Cache: %d hits, %d misses (%.1f%%)",
+			nucleo.ID, ClientConfig.ASIDS, e.TLB_HITS, e.TLB_MISSES, tasaDeAcierto(e.TLB_HITS, e.TLB_MISSES),
+			e.CACHE_HITS, e.CACHE_MISSES, tasaDeAcierto(e.CACHE_HITS, e.CACHE_MISSES)))
+		slog.Info(fmt.Sprintf("CPU %s - Cache %s %s, %s - Desalojos sucios: %d - Escrituras a memoria: %d paginas, %d direcciones, %d bytes",
+			nucleo.ID, algoritmoCache, ClientConfig.CACHE_WRITE_POLICY, textoWriteAllocate(), e.DESALOJOS_SUCIOS,
+			e.ESCRITURAS_PAGINA, e.ESCRITURAS_DIRECCION, e.BYTES_ESCRITOS_MEMORIA))
+	}
+}
+
+// Elige por donde viajan los pedidos a memoria. Se llama antes del handshake para que tambien vaya por ahi
+func RegistrarTransporteMemoria() {
+	var transporte globales.Transporte
+	switch ClientConfig.MEMORY_TRANSPORT {
+	case "tcp":
+		transporte = globales.NuevoTransporteTCP(ClientConfig.IP_MEMORY, ClientConfig.MEMORY_TCP_PORT)
+	case "", "http":
+		transporte = globales.NuevoTransporteHTTP(ClientConfig.IP_MEMORY, ClientConfig.PORT_MEMORY)
+	default:
+		slog.Warn(fmt.Sprintf("Transporte a memoria desconocido '%s', se usa http", ClientConfig.MEMORY_TRANSPORT))
+		transporte = globales.NuevoTransporteHTTP(ClientConfig.IP_MEMORY, ClientConfig.PORT_MEMORY)
+	}
+	globales.RegistrarTransporte(ClientConfig.IP_MEMORY, ClientConfig.PORT_MEMORY, transporte)
+	slog.Info(fmt.Sprintf("Transporte a memoria: %s", transporte.Nombre()))
+}
+
+// Volumen y latencia del trafico con memoria, para comparar transportes sobre la misma prueba
+func ReportarTransporteMemoria() {
+	nombre, e, ok := globales.EstadisticasDeTransporte(ClientConfig.IP_MEMORY, ClientConfig.PORT_MEMORY)
+	if !ok || e.PEDIDOS == 0 {
+		return
+	}
+	slog.Info(fmt.Sprintf("CPU %s - Transporte a memoria %s: %d pedidos, %d bytes enviados, %d bytes recibidos, latencia promedio %s",
+		IdCpu, nombre, e.PEDIDOS, e.BYTES_ENVIADOS, e.BYTES_RECIBIDOS, e.TIEMPO/time.Duration(e.PEDIDOS)))
+}
+
+func CerrarTrazas() {
+	for _, nucleo := range Nucleos {
+		if err := nucleo.traza.Cerrar(); err != nil {
+			slog.Error(fmt.Sprintf("CPU %s - Error al cerrar la traza: %v", nucleo.ID, err))
+		}
+	}
+}
+
+func textoWriteAllocate() string {
+	if ClientConfig.CACHE_WRITE_ALLOCATE {
+		return "write-allocate"
+	}
+	return "no-write-allocate"
+}
+
+func tasaDeAcierto(hits int, misses int) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+	return 100 * float64(hits) / float64(hits+misses)
 }
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"globales"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSiguienteInterrupcion(t *testing.T) {
+	casos := []struct {
+		nombre         string
+		interrupciones []interrupcionPendiente
+		esperada       string // "" si no se atiende ninguna
+	}{
+		{"sin interrupciones", nil, ""},
+		{"el fallo le gana a todas", []interrupcionPendiente{
+			{tipo: globales.InterrupcionFinIO, pid: 1},
+			{tipo: globales.InterrupcionDesalojo, pid: 1},
+			{tipo: globales.InterrupcionQuantum, pid: 1},
+			{tipo: globales.InterrupcionFallo, pid: 1},
+		}, globales.InterrupcionFallo},
+		{"el quantum le gana al desalojo", []interrupcionPendiente{
+			{tipo: globales.InterrupcionDesalojo, pid: 1},
+			{tipo: globales.InterrupcionQuantum, pid: 1},
+		}, globales.InterrupcionQuantum},
+		{"el desalojo le gana al fin de IO", []interrupcionPendiente{
+			{tipo: globales.InterrupcionFinIO, pid: 1},
+			{tipo: globales.InterrupcionDesalojo, pid: 1},
+		}, globales.InterrupcionDesalojo},
+		{"a igual prioridad la primera", []interrupcionPendiente{
+			{tipo: globales.InterrupcionFinIO, pid: 1},
+			{tipo: globales.InterrupcionFinIO, pid: 1},
+		}, globales.InterrupcionFinIO},
+		{"las de otro PID se descartan", []interrupcionPendiente{
+			{tipo: globales.InterrupcionQuantum, pid: 2},
+			{tipo: globales.InterrupcionFinIO, pid: 1},
+		}, globales.InterrupcionFinIO},
+		{"solo de otro PID", []interrupcionPendiente{
+			{tipo: globales.InterrupcionQuantum, pid: 2},
+		}, ""},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			nucleo := &Nucleo{ejecutandoPID: 1}
+			for _, interrupcion := range caso.interrupciones {
+				levantarInterrupcion(nucleo, interrupcion)
+			}
+			atendida := siguienteInterrupcion(nucleo)
+			tipo := ""
+			if atendida != nil {
+				tipo = atendida.tipo
+				if atendida.pid != 1 {
+					t.Errorf("se atendio una interrupcion del PID %d", atendida.pid)
+				}
+			}
+			if tipo != caso.esperada {
+				t.Errorf("se atendio %q, se esperaba %q", tipo, caso.esperada)
+			}
+			// el resto sacaria igual al proceso de la CPU, no quedan para la proxima rafaga
+			if len(nucleo.interrupciones) != 0 {
+				t.Errorf("quedaron %d interrupciones pendientes", len(nucleo.interrupciones))
+			}
+		})
+	}
+}
+
+// El kernel no puede levantar fallos: un motivo FALLO o desconocido se atiende como desalojo
+func TestInterrumpirPorDesalojo(t *testing.T) {
+	casos := []struct {
+		motivo   string
+		esperado string
+	}{
+		{globales.InterrupcionDesalojo, globales.InterrupcionDesalojo},
+		{globales.InterrupcionFinIO, globales.InterrupcionFinIO},
+		{globales.InterrupcionFallo, globales.InterrupcionDesalojo},
+		{"", globales.InterrupcionDesalojo},
+	}
+	for _, caso := range casos {
+		t.Run(caso.motivo, func(t *testing.T) {
+			nucleo := &Nucleo{ejecutandoPID: 1}
+			cuerpo, _ := json.Marshal(globales.Interrupcion{PID: 1, MOTIVO: caso.motivo})
+			w := httptest.NewRecorder()
+			InterrumpirPorDesalojo(nucleo, w, httptest.NewRequest(http.MethodPost, "/cpu/interrupt", bytes.NewReader(cuerpo)))
+			if w.Code != http.StatusOK {
+				t.Fatalf("estado %d", w.Code)
+			}
+			atendida := siguienteInterrupcion(nucleo)
+			if atendida == nil || atendida.tipo != caso.esperado {
+				t.Errorf("se atendio %+v, se esperaba %q", atendida, caso.esperado)
+			}
+		})
+	}
+}
@@ -1,9 +1,10 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"flag"
 	"fmt"
-	"io"
 	"io/fs"
 	"log"
 	"os"
@@ -11,57 +12,6 @@ import (
 	"runtime"
 )
 
-type ConfigMemoria struct {
-	PORT_MEMORY      int    `json:"port_memory"`
-	IP_MEMORY        string `json:"ip_memory"`
-	MEMORY_SIZE      int    `json:"memory_size"`
-	PAGE_SIZE        int    `json:"page_size"`
-	ENTRIES_PER_PAGE int    `json:"entries_per_page"`
-	NUMBER_OF_LEVELS int    `json:"number_of_levels"`
-	MEMORY_DELAY     int    `json:"memory_delay"`
-	SWAPFILE_PATH    string `json:"swapfile_path"`
-	SWAP_DELAY       int    `json:"swap_delay"`
-	LOG_LEVEL        string `json:"log_level"`
-	DUMP_PATH        string `json:"dump_path"`
-	SCRIPTS_PATH     string `json:"scripts_path"`
-}
-
-type ConfigKernel struct {
-	IP_MEMORY               string  `json:"ip_memory"`
-	PORT_MEMORY             int     `json:"port_memory"`
-	IP_KERNEL               string  `json:"ip_kernel"`
-	PORT_KERNEL             int     `json:"port_kernel"`
-	SCHEDULER_ALGORITHM     string  `json:"scheduler_algorithm"`
-	READY_INGRESS_ALGORITHM string  `json:"ready_ingress_algorithm"`
-	ALPHA                   float32 `json:"alpha"`
-	INITIAL_ESTIMATE        float32 `json:"initial_estimate"`
-	SUSPENSION_TIME         int     `json:"suspension_time"`
-	LOG_LEVEL               string  `json:"log_level"`
-}
-
-type ConfigCPU struct {
-	PORT_CPU          int    `json:"port_cpu"`
-	IP_CPU            string `json:"ip_cpu"`
-	PORT_MEMORY       int    `json:"port_memory"`
-	IP_MEMORY         string `json:"ip_memory"`
-	PORT_KERNEL       int    `json:"port_kernel"`
-	IP_KERNEL         string `json:"ip_kernel"`
-	TLB_ENTRIES       int    `json:"tlb_entries"`
-	TLB_REPLACEMENT   string `json:"tlb_replacement"`
-	CACHE_ENTRIES     int    `json:"cache_entries"`
-	CACHE_REPLACEMENT string `json:"cache_replacement"`
-	CACHE_DELAY       int    `json:"cache_delay"`
-	LOG_LEVEL         string `json:"log_level"`
-}
-
-type ConfigIO struct {
-	PORT_IO     int    `json:"port_io"`
-	IP_IO       string `json:"ip_io"`
-	IP_KERNEL   string `json:"ip_kernel"`
-	PORT_KERNEL int    `json:"port_kernel"`
-	LOG_LEVEL   string `json:"log_level"`
-}
-
 var rutaArchivo string
 var local bool
 
@@ -77,10 +27,34 @@ func main() {
 	rutaArchivo = filepath.Dir(currentFile)
 	var decision int = 0
 
-	DUMP_PATH = filepath.Join(rutaArchivo, "memoria", "dump")
-	SCRIPTS_PATH = filepath.Join(rutaArchivo, "globales", "archivos_prueba")
+	// Con flags se reescriben todos los configs de una vez, sin menu:
+	// go run deploy.go --kernel-ip X --memory-ip Y --cpu-ip Z --io-ip W [--dump-path ...] [--scripts-path ...]
+	// Los campos de los flags que no se pasan quedan como estan en cada config
+	flag.StringVar(&IP_KERNEL, "kernel-ip", "", "IP del kernel")
+	flag.StringVar(&IP_MEMORIA, "memory-ip", "", "IP de la memoria")
+	flag.StringVar(&IP_CPU, "cpu-ip", "", "IP de las CPUs")
+	flag.StringVar(&IP_IO, "io-ip", "", "IP de las IOs")
+	flag.StringVar(&DUMP_PATH, "dump-path", filepath.Join(rutaArchivo, "memoria", "dump"), "carpeta de los dumps de memoria")
+	flag.StringVar(&SCRIPTS_PATH, "scripts-path", filepath.Join(rutaArchivo, "globales", "archivos_prueba"), "carpeta de los pseudocodigos")
+	flag.Parse()
+
+	if flag.NFlag() > 0 {
+		pasados := map[string]bool{}
+		flag.Visit(func(f *flag.Flag) { pasados[f.Name] = true })
+		if !pasados["dump-path"] {
+			DUMP_PATH = ""
+		}
+		if !pasados["scripts-path"] {
+			SCRIPTS_PATH = ""
+		}
+		actualizarIPsCPU()
+		actualizarIPsMemoria()
+		actualizarIPsKernel()
+		actualizarIPsIO()
+		return
+	}
 
-	for decision != 7 {
+	for decision != 6 {
 		fmt.Println("Cambiar IPs de modulo (escribir el numero): \n - 1 CPU \n - 2 Memoria \n - 3 Kernel \n - 4 IO \n - 5 Setear IPs \n - 6 Salir")
 		fmt.Scan(&decision)
 		switch decision {
@@ -124,7 +98,7 @@ func actualizarIPsIO() {
 			return nil // Opcional: continuar a pesar del error
 		}
 		if !d.IsDir() {
-			go modificarConfigIO(path)
+			modificarConfigIO(path)
 			//fmt.Println("Archivo: %s modificado", path)
 		}
 		return nil
@@ -143,7 +117,7 @@ func actualizarIPsKernel() {
 			return nil // Opcional: continuar a pesar del error
 		}
 		if !d.IsDir() {
-			go modificarConfigKernel(path)
+			modificarConfigKernel(path)
 			//fmt.Println("Archivo: %s modificado", path)
 		}
 		return nil
@@ -162,7 +136,7 @@ func actualizarIPsCPU() {
 			return nil // Opcional: continuar a pesar del error
 		}
 		if !d.IsDir() {
-			go modificarConfigCPU(path)
+			modificarConfigCPU(path)
 			//fmt.Println("Archivo: %s modificado", path)
 		}
 		return nil
@@ -181,7 +155,7 @@ func actualizarIPsMemoria() {
 			return nil // Opcional: continuar a pesar del error
 		}
 		if !d.IsDir() {
-			go modificarConfigMemoria(path)
+			modificarConfigMemoria(path)
 			//fmt.Println("Archivo: %s modificado", path)
 		}
 		return nil
@@ -192,142 +166,44 @@ func actualizarIPsMemoria() {
 }
 
 func modificarConfigIO(path string) {
-	nuevaConfig := ConfigIO{}
-	configFile, err := os.OpenFile(path, os.O_RDONLY, 0644)
-	if err != nil {
-		fmt.Println("No se pudo abrir el archivo de configuración de CPU:", path)
-		return
-	}
-	bytes, err := io.ReadAll(configFile)
-	if err != nil {
-		fmt.Println("No se pudo leer el archivo de configuración de CPU:", path)
-		configFile.Close()
-		return
-	}
-	configFile.Close()
-
-	errDeco := json.Unmarshal(bytes, &nuevaConfig)
-	//log.Printf("Modificando archivo de configuración de CPU: %v", nuevaConfig)
-	if errDeco != nil {
-		fmt.Println("No se pudo decodificar el archivo de configuración de CPU:", path)
-		return
-	}
-	nuevaConfig.IP_KERNEL = IP_KERNEL
-	nuevaConfig.IP_IO = IP_IO
-
-	//log.Printf("Modificando archivo de configuración de CPU: %v", nuevaConfig)
-	dataJson, _ := json.MarshalIndent(nuevaConfig, " ", " ")
-	configFile, err = os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0644)
-	if err != nil {
-		fmt.Println("No se pudo abrir el archivo de configuración de CPU para escribir:", path)
-		return
-	}
-	defer configFile.Close()
-	configFile.Write(dataJson)
+	modificarConfig(path, map[string]string{"ip_kernel": IP_KERNEL, "ip_io": IP_IO})
 }
 
 func modificarConfigKernel(path string) {
-	nuevaConfig := ConfigKernel{}
-	configFile, err := os.OpenFile(path, os.O_RDONLY, 0644)
-	if err != nil {
-		fmt.Println("No se pudo abrir el archivo de configuración de CPU:", path)
-		return
-	}
-	bytes, err := io.ReadAll(configFile)
-	if err != nil {
-		fmt.Println("No se pudo leer el archivo de configuración de CPU:", path)
-		configFile.Close()
-		return
-	}
-	configFile.Close()
-
-	errDeco := json.Unmarshal(bytes, &nuevaConfig)
-	//log.Printf("Modificando archivo de configuración de CPU: %v", nuevaConfig)
-	if errDeco != nil {
-		fmt.Println("No se pudo decodificar el archivo de configuración de CPU:", path)
-		return
-	}
-	nuevaConfig.IP_MEMORY = IP_MEMORIA
-	nuevaConfig.IP_KERNEL = IP_KERNEL
-
-	//log.Printf("Modificando archivo de configuración de CPU: %v", nuevaConfig)
-	dataJson, _ := json.MarshalIndent(nuevaConfig, " ", " ")
-	configFile, err = os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0644)
-	if err != nil {
-		fmt.Println("No se pudo abrir el archivo de configuración de CPU para escribir:", path)
-		return
-	}
-	defer configFile.Close()
-	configFile.Write(dataJson)
+	modificarConfig(path, map[string]string{"ip_memory": IP_MEMORIA, "ip_kernel": IP_KERNEL})
 }
 
 func modificarConfigMemoria(path string) {
-	nuevaConfig := ConfigMemoria{}
-	configFile, err := os.OpenFile(path, os.O_RDONLY, 0644)
-	if err != nil {
-		fmt.Println("No se pudo abrir el archivo de configuración de CPU:", path)
-		return
-	}
-	bytes, err := io.ReadAll(configFile)
-	if err != nil {
-		fmt.Println("No se pudo leer el archivo de configuración de CPU:", path)
-		configFile.Close()
-		return
-	}
-	configFile.Close()
+	modificarConfig(path, map[string]string{"ip_memory": IP_MEMORIA, "dump_path": DUMP_PATH, "scripts_path": SCRIPTS_PATH})
+}
 
-	errDeco := json.Unmarshal(bytes, &nuevaConfig)
-	//log.Printf("Modificando archivo de configuración de CPU: %v", nuevaConfig)
-	if errDeco != nil {
-		fmt.Println("No se pudo decodificar el archivo de configuración de CPU:", path)
-		return
-	}
-	nuevaConfig.IP_MEMORY = IP_MEMORIA
-	nuevaConfig.DUMP_PATH = DUMP_PATH
-	nuevaConfig.SCRIPTS_PATH = SCRIPTS_PATH
+func modificarConfigCPU(path string) {
+	modificarConfig(path, map[string]string{"ip_memory": IP_MEMORIA, "ip_kernel": IP_KERNEL, "ip_cpu": IP_CPU})
+}
 
-	//log.Printf("Modificando archivo de configuración de CPU: %v", nuevaConfig)
-	dataJson, _ := json.MarshalIndent(nuevaConfig, " ", " ")
-	configFile, err = os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0644)
+// Reescribe los campos con valor y deja el resto del config como estaba, aunque el deploy no lo conozca
+func modificarConfig(path string, campos map[string]string) {
+	contenido, err := os.ReadFile(path)
 	if err != nil {
-		fmt.Println("No se pudo abrir el archivo de configuración de CPU para escribir:", path)
+		fmt.Println("No se pudo leer el archivo de configuración:", path)
 		return
 	}
-	defer configFile.Close()
-	configFile.Write(dataJson)
-}
 
-func modificarConfigCPU(path string) {
-	nuevaConfig := ConfigCPU{}
-	configFile, err := os.OpenFile(path, os.O_RDONLY, 0644)
-	if err != nil {
-		fmt.Println("No se pudo abrir el archivo de configuración de CPU:", path)
+	config := map[string]any{}
+	decoder := json.NewDecoder(bytes.NewReader(contenido))
+	decoder.UseNumber() // los numeros se escriben de vuelta tal cual estaban
+	if err := decoder.Decode(&config); err != nil {
+		fmt.Println("No se pudo decodificar el archivo de configuración:", path)
 		return
 	}
-	bytes, err := io.ReadAll(configFile)
-	if err != nil {
-		fmt.Println("No se pudo leer el archivo de configuración de CPU:", path)
-		configFile.Close()
-		return
+	for campo, valor := range campos {
+		if valor != "" {
+			config[campo] = valor
+		}
 	}
-	configFile.Close()
 
-	errDeco := json.Unmarshal(bytes, &nuevaConfig)
-	//log.Printf("Modificando archivo de configuración de CPU: %v", nuevaConfig)
-	if errDeco != nil {
-		fmt.Println("No se pudo decodificar el archivo de configuración de CPU:", path)
-		return
-	}
-	nuevaConfig.IP_MEMORY = IP_MEMORIA
-	nuevaConfig.IP_KERNEL = IP_KERNEL
-	nuevaConfig.IP_CPU = IP_CPU
-	//log.Printf("Modificando archivo de configuración de CPU: %v", nuevaConfig)
-	dataJson, _ := json.MarshalIndent(nuevaConfig, " ", " ")
-	configFile, err = os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0644)
-	if err != nil {
-		fmt.Println("No se pudo abrir el archivo de configuración de CPU para escribir:", path)
-		return
+	dataJson, _ := json.MarshalIndent(config, " ", " ")
+	if err := os.WriteFile(path, dataJson, 0644); err != nil {
+		fmt.Println("No se pudo escribir el archivo de configuración:", path)
 	}
-	defer configFile.Close()
-	configFile.Write(dataJson)
 }
@@ -0,0 +1,272 @@
+package globales
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ------ VARIABLES DE ENTORNO ------ //
+// Cualquier campo del config se puede pisar al arrancar sin editar el JSON, con el nombre del campo en
+// mayusculas: TP_<MODULO>_<CAMPO> para un modulo (TP_CPU_PORT_CPU) o TP_<CAMPO> para todos los que
+// lo tengan (TP_IP_MEMORY). La variable del modulo tiene prioridad.
+
+const prefijoEntorno = "TP_"
+
+// AplicarEntorno pisa los campos de config (un puntero a struct) que tengan variable de entorno.
+// Los campos que no se pudieron convertir quedan como estaban y se devuelven en el error.
+func AplicarEntorno(modulo string, config any) error {
+	valor := reflect.ValueOf(config)
+	if valor.Kind() != reflect.Pointer || valor.IsNil() || valor.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	var errores []string
+	aplicarEntorno(prefijoEntorno+strings.ToUpper(modulo)+"_", valor.Elem(), &errores)
+	if len(errores) > 0 {
+		return fmt.Errorf("variables de entorno invalidas: %s", strings.Join(errores, "; "))
+	}
+	return nil
+}
+
+func aplicarEntorno(prefijoModulo string, estructura reflect.Value, errores *[]string) {
+	recorrerCampos(estructura, func(campo string, valor reflect.Value) {
+		nombre := strings.ToUpper(campo)
+		variable := prefijoModulo + nombre
+		texto, ok := os.LookupEnv(variable)
+		if !ok {
+			variable = prefijoEntorno + nombre
+			texto, ok = os.LookupEnv(variable)
+		}
+		if !ok {
+			return
+		}
+		if err := asignarTexto(valor, texto); err != nil {
+			*errores = append(*errores, fmt.Sprintf("%s=%q: %s", variable, texto, err.Error()))
+			return
+		}
+		slog.Debug(fmt.Sprintf("Config: %s tomado de %s", nombre, variable))
+	})
+}
+
+// recorrerCampos llama a f con el nombre JSON de cada campo del config, incluidos los de los structs
+// embebidos (ConfigRPC, ConfigLog), en el orden en que estan declarados
+func recorrerCampos(estructura reflect.Value, f func(campo string, valor reflect.Value)) {
+	tipo := estructura.Type()
+	for i := 0; i < tipo.NumField(); i++ {
+		campo, valor := tipo.Field(i), estructura.Field(i)
+		if campo.Anonymous && valor.Kind() == reflect.Struct {
+			recorrerCampos(valor, f)
+			continue
+		}
+		nombre := strings.Split(campo.Tag.Get("json"), ",")[0]
+		if !campo.IsExported() || nombre == "" || nombre == "-" {
+			continue
+		}
+		f(nombre, valor)
+	}
+}
+
+func asignarTexto(valor reflect.Value, texto string) error {
+	switch valor.Kind() {
+	case reflect.String:
+		valor.SetString(texto)
+	case reflect.Int, reflect.Int64:
+		numero, err := strconv.Atoi(texto)
+		if err != nil {
+			return fmt.Errorf("se espera un entero")
+		}
+		valor.SetInt(int64(numero))
+	case reflect.Float32, reflect.Float64:
+		numero, err := strconv.ParseFloat(texto, 64)
+		if err != nil {
+			return fmt.Errorf("se espera un numero")
+		}
+		valor.SetFloat(numero)
+	case reflect.Bool:
+		booleano, err := strconv.ParseBool(texto)
+		if err != nil {
+			return fmt.Errorf("se espera true o false")
+		}
+		valor.SetBool(booleano)
+	default:
+		return fmt.Errorf("el campo no se puede configurar por entorno")
+	}
+	return nil
+}
+
+// ------ CARGA Y VALIDACION ------ //
+// Cada modulo describe su config con un Esquema: valores por defecto, campos obligatorios, valores
+// posibles y rangos. CargarConfiguracion lee el JSON, aplica el entorno y junta todos los problemas en un
+// solo error, asi el modulo no arranca con un config que despues falla en cualquier lado (un algoritmo
+// mal escrito dejaba al planificador girando sin hacer nada).
+
+type Regla struct {
+	Defecto       any       // valor del campo si no esta en el JSON
+	Obligatorio   bool      // no puede quedar vacio ni en 0
+	Valores       []string  // valores posibles de un string; vacio se acepta si no es obligatorio
+	SinMayusculas bool      // los Valores se comparan sin distinguir mayusculas
+	Rango         []float64 // {minimo} o {minimo, maximo} de un campo numerico
+}
+
+// Esquema de un config, por nombre de campo en el JSON
+type Esquema map[string]Regla
+
+// Reglas de los campos de ConfigRPC y ConfigLog, que son iguales en todos los modulos
+var reglasComunes = Esquema{
+	"log_level":     {Defecto: "INFO", Valores: []string{"DEBUG", "INFO", "WARN", "WARNING", "ERROR"}, SinMayusculas: true},
+	"log_format":    {Valores: []string{"text", "json"}, SinMayusculas: true},
+	"log_max_size":  {Rango: []float64{0}},
+	"log_max_age":   {Rango: []float64{0}},
+	"log_max_files": {Rango: []float64{0}},
+	"rpc_timeout":   {Rango: []float64{0}},
+	"rpc_retries":   {Rango: []float64{0}},
+	"rpc_backoff":   {Rango: []float64{0}},
+}
+
+// CargarConfiguracion llena config (un puntero a struct) con los defaults del esquema, el archivo en ruta
+// y las variables de entorno del modulo, en ese orden, y despues lo valida contra el esquema
+func CargarConfiguracion(modulo string, ruta string, config any, esquema Esquema) error {
+	valor := reflect.ValueOf(config)
+	if valor.Kind() != reflect.Pointer || valor.IsNil() || valor.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("el config de %s tiene que ser un puntero a struct", modulo)
+	}
+	campos := map[string]reflect.Value{}
+	recorrerCampos(valor.Elem(), func(campo string, valor reflect.Value) { campos[campo] = valor })
+
+	reglas := Esquema{}
+	for campo, regla := range reglasComunes {
+		if _, ok := campos[campo]; ok {
+			reglas[campo] = regla
+		}
+	}
+	for campo, regla := range esquema {
+		if _, ok := campos[campo]; !ok {
+			return fmt.Errorf("el esquema de %s tiene el campo %s que no esta en el config", modulo, campo)
+		}
+		reglas[campo] = regla
+	}
+
+	for campo, regla := range reglas {
+		if regla.Defecto == nil {
+			continue
+		}
+		defecto := reflect.ValueOf(regla.Defecto)
+		if !defecto.CanConvert(campos[campo].Type()) {
+			return fmt.Errorf("el default de %s (%v) no es del tipo del campo", campo, regla.Defecto)
+		}
+		campos[campo].Set(defecto.Convert(campos[campo].Type()))
+	}
+
+	contenido, err := os.ReadFile(ruta)
+	if err != nil {
+		return fmt.Errorf("no se pudo leer el config: %w", err)
+	}
+	if err := decodificarConfig(contenido, config); err != nil {
+		return fmt.Errorf("config %s invalido: %w", ruta, err)
+	}
+
+	var errores []string
+	if desconocidos := camposDesconocidos(contenido, campos); len(desconocidos) > 0 {
+		errores = append(errores, fmt.Sprintf("campos desconocidos: %s", strings.Join(desconocidos, ", ")))
+	}
+	if err := AplicarEntorno(modulo, config); err != nil {
+		errores = append(errores, err.Error())
+	}
+	recorrerCampos(valor.Elem(), func(campo string, valor reflect.Value) {
+		if regla, ok := reglas[campo]; ok {
+			if err := regla.validar(campo, valor); err != nil {
+				errores = append(errores, err.Error())
+			}
+		}
+	})
+	if len(errores) > 0 {
+		return fmt.Errorf("config %s invalido:\n  - %s", ruta, strings.Join(errores, "\n  - "))
+	}
+	return nil
+}
+
+// decodificarConfig traduce los errores de encoding/json a la linea y el campo del archivo
+func decodificarConfig(contenido []byte, config any) error {
+	var sintaxis *json.SyntaxError
+	var tipo *json.UnmarshalTypeError
+	err := json.Unmarshal(contenido, config)
+	switch {
+	case errors.As(err, &sintaxis):
+		return fmt.Errorf("JSON mal formado en la linea %d: %s", lineaDeOffset(contenido, sintaxis.Offset), sintaxis.Error())
+	case errors.As(err, &tipo):
+		return fmt.Errorf("%s en la linea %d tiene que ser %s, no %s", tipo.Field, lineaDeOffset(contenido, tipo.Offset), tipo.Type.Kind(), tipo.Value)
+	}
+	return err
+}
+
+// camposDesconocidos devuelve las claves del JSON que el modulo no tiene, que casi siempre son un nombre
+// mal escrito y si no se ignorarian en silencio
+func camposDesconocidos(contenido []byte, campos map[string]reflect.Value) []string {
+	var claves map[string]json.RawMessage
+	json.Unmarshal(contenido, &claves) // ya se decodifico bien una vez
+	var desconocidos []string
+	for clave := range claves {
+		if _, ok := campos[clave]; !ok {
+			desconocidos = append(desconocidos, clave)
+		}
+	}
+	sort.Strings(desconocidos)
+	return desconocidos
+}
+
+func lineaDeOffset(contenido []byte, offset int64) int {
+	return bytes.Count(contenido[:min(int(offset), len(contenido))], []byte("\n")) + 1
+}
+
+func (regla Regla) validar(campo string, valor reflect.Value) error {
+	if valor.IsZero() {
+		if regla.Obligatorio {
+			return fmt.Errorf("falta %s", campo)
+		}
+		return nil
+	}
+
+	if len(regla.Valores) > 0 && valor.Kind() == reflect.String {
+		texto := valor.String()
+		for _, posible := range regla.Valores {
+			if texto == posible || (regla.SinMayusculas && strings.EqualFold(texto, posible)) {
+				return nil
+			}
+		}
+		for _, posible := range regla.Valores {
+			if strings.EqualFold(texto, posible) {
+				return fmt.Errorf("%s=%q invalido, ¿quisiste decir %q?", campo, texto, posible)
+			}
+		}
+		return fmt.Errorf("%s=%q invalido, se espera uno de: %s", campo, texto, strings.Join(regla.Valores, ", "))
+	}
+
+	if len(regla.Rango) > 0 {
+		var numero float64
+		switch valor.Kind() {
+		case reflect.Int, reflect.Int64:
+			numero = float64(valor.Int())
+		case reflect.Float32, reflect.Float64:
+			numero = valor.Float()
+		default:
+			return nil
+		}
+		if numero < regla.Rango[0] {
+			if len(regla.Rango) == 1 {
+				return fmt.Errorf("%s=%v invalido, tiene que ser mayor o igual a %v", campo, numero, regla.Rango[0])
+			}
+			return fmt.Errorf("%s=%v fuera de rango, se espera entre %v y %v", campo, numero, regla.Rango[0], regla.Rango[1])
+		}
+		if len(regla.Rango) > 1 && numero > regla.Rango[1] {
+			return fmt.Errorf("%s=%v fuera de rango, se espera entre %v y %v", campo, numero, regla.Rango[0], regla.Rango[1])
+		}
+	}
+	return nil
+}
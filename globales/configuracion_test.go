@@ -0,0 +1,207 @@
+package globales
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Config de prueba con los campos comunes embebidos, como los de los modulos
+type configPrueba struct {
+	IP_MEMORY   string  `json:"ip_memory"`
+	PORT_MEMORY int     `json:"port_memory"`
+	ALGORITMO   string  `json:"algoritmo"`
+	ALPHA       float32 `json:"alpha"`
+	DELAY       int     `json:"delay"`
+	LOG_LEVEL   string  `json:"log_level"`
+	ConfigRPC
+	ConfigLog
+}
+
+var esquemaPrueba = Esquema{
+	"ip_memory":   {Obligatorio: true},
+	"port_memory": {Defecto: 8002, Rango: []float64{1, 65535}},
+	"algoritmo":   {Defecto: "FIFO", Valores: []string{"FIFO", "LRU"}},
+	"alpha":       {Defecto: 0.5, Rango: []float64{0, 1}},
+	"delay":       {Rango: []float64{0}},
+}
+
+func escribirConfig(t *testing.T, contenido string) string {
+	t.Helper()
+	ruta := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(ruta, []byte(contenido), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return ruta
+}
+
+func TestCargarConfiguracion(t *testing.T) {
+	casos := []struct {
+		nombre    string
+		contenido string
+		entorno   map[string]string
+		esperado  configPrueba
+	}{
+		{
+			nombre:    "defaults de los campos que faltan",
+			contenido: `{"ip_memory": "127.0.0.1"}`,
+			esperado:  configPrueba{IP_MEMORY: "127.0.0.1", PORT_MEMORY: 8002, ALGORITMO: "FIFO", ALPHA: 0.5, LOG_LEVEL: "INFO"},
+		},
+		{
+			nombre:    "el archivo pisa los defaults",
+			contenido: `{"ip_memory": "127.0.0.1", "port_memory": 9000, "algoritmo": "LRU", "alpha": 0.25, "log_level": "debug", "rpc_retries": 3}`,
+			esperado:  configPrueba{IP_MEMORY: "127.0.0.1", PORT_MEMORY: 9000, ALGORITMO: "LRU", ALPHA: 0.25, LOG_LEVEL: "debug", ConfigRPC: ConfigRPC{RPC_RETRIES: 3}},
+		},
+		{
+			nombre:    "el entorno pisa el archivo",
+			contenido: `{"ip_memory": "127.0.0.1", "port_memory": 9000}`,
+			entorno:   map[string]string{"TP_IP_MEMORY": "10.0.0.1", "TP_PORT_MEMORY": "9100", "TP_ALPHA": "0.75", "TP_LOG_STDOUT": "true"},
+			esperado:  configPrueba{IP_MEMORY: "10.0.0.1", PORT_MEMORY: 9100, ALGORITMO: "FIFO", ALPHA: 0.75, LOG_LEVEL: "INFO", ConfigLog: ConfigLog{LOG_STDOUT: true}},
+		},
+		{
+			nombre:    "la variable del modulo tiene prioridad sobre la global",
+			contenido: `{"ip_memory": "127.0.0.1"}`,
+			entorno:   map[string]string{"TP_IP_MEMORY": "10.0.0.1", "TP_PRUEBA_IP_MEMORY": "10.0.0.2", "TP_OTRO_PORT_MEMORY": "1"},
+			esperado:  configPrueba{IP_MEMORY: "10.0.0.2", PORT_MEMORY: 8002, ALGORITMO: "FIFO", ALPHA: 0.5, LOG_LEVEL: "INFO"},
+		},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			for variable, valor := range caso.entorno {
+				t.Setenv(variable, valor)
+			}
+			var config configPrueba
+			if err := CargarConfiguracion("prueba", escribirConfig(t, caso.contenido), &config, esquemaPrueba); err != nil {
+				t.Fatal(err)
+			}
+			if config != caso.esperado {
+				t.Errorf("config = %+v, se esperaba %+v", config, caso.esperado)
+			}
+		})
+	}
+}
+
+func TestCargarConfiguracionErrores(t *testing.T) {
+	casos := []struct {
+		nombre    string
+		contenido string
+		entorno   map[string]string
+		errores   []string // cada uno tiene que aparecer en el error
+	}{
+		{
+			nombre:    "obligatorio",
+			contenido: `{"port_memory": 8002}`,
+			errores:   []string{"falta ip_memory"},
+		},
+		{
+			nombre:    "campos desconocidos",
+			contenido: `{"ip_memory": "127.0.0.1", "port_memori": 8002, "algoritmos": "FIFO"}`,
+			errores:   []string{"campos desconocidos: algoritmos, port_memori"},
+		},
+		{
+			nombre:    "valor con otras mayusculas",
+			contenido: `{"ip_memory": "127.0.0.1", "algoritmo": "fifo"}`,
+			errores:   []string{`algoritmo="fifo" invalido, ¿quisiste decir "FIFO"?`},
+		},
+		{
+			nombre:    "valor que no existe",
+			contenido: `{"ip_memory": "127.0.0.1", "algoritmo": "CLOCK"}`,
+			errores:   []string{`algoritmo="CLOCK" invalido, se espera uno de: FIFO, LRU`},
+		},
+		{
+			nombre:    "regla comun sin mayusculas",
+			contenido: `{"ip_memory": "127.0.0.1", "log_format": "XML"}`,
+			errores:   []string{`log_format="XML" invalido, se espera uno de: text, json`},
+		},
+		{
+			nombre:    "fuera de rango",
+			contenido: `{"ip_memory": "127.0.0.1", "port_memory": 70000, "alpha": 1.5}`,
+			errores:   []string{"port_memory=70000 fuera de rango, se espera entre 1 y 65535", "alpha=1.5 fuera de rango, se espera entre 0 y 1"},
+		},
+		{
+			nombre:    "minimo",
+			contenido: `{"ip_memory": "127.0.0.1", "delay": -5, "rpc_retries": -1}`,
+			errores:   []string{"delay=-5 invalido, tiene que ser mayor o igual a 0", "rpc_retries=-1 invalido, tiene que ser mayor o igual a 0"},
+		},
+		{
+			nombre:    "variable de entorno invalida",
+			contenido: `{"ip_memory": "127.0.0.1"}`,
+			entorno:   map[string]string{"TP_PRUEBA_PORT_MEMORY": "ocho mil"},
+			errores:   []string{`variables de entorno invalidas: TP_PRUEBA_PORT_MEMORY="ocho mil": se espera un entero`},
+		},
+		{
+			nombre:    "el entorno tambien se valida",
+			contenido: `{"ip_memory": "127.0.0.1"}`,
+			entorno:   map[string]string{"TP_ALGORITMO": "lru"},
+			errores:   []string{`algoritmo="lru" invalido, ¿quisiste decir "LRU"?`},
+		},
+		{
+			nombre:    "JSON mal formado",
+			contenido: "{\n  \"ip_memory\": \"127.0.0.1\",\n  \"port_memory\": 8002,\n}",
+			errores:   []string{"JSON mal formado en la linea 4"},
+		},
+		{
+			nombre:    "tipo equivocado",
+			contenido: "{\n  \"ip_memory\": \"127.0.0.1\",\n  \"port_memory\": \"8002\"\n}",
+			errores:   []string{"port_memory en la linea 3 tiene que ser int, no string"},
+		},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			for variable, valor := range caso.entorno {
+				t.Setenv(variable, valor)
+			}
+			ruta := escribirConfig(t, caso.contenido)
+			var config configPrueba
+			err := CargarConfiguracion("prueba", ruta, &config, esquemaPrueba)
+			if err == nil {
+				t.Fatal("se esperaba un error")
+			}
+			if !strings.HasPrefix(err.Error(), "config "+ruta+" invalido:") {
+				t.Errorf("error = %q, se esperaba que empiece con la ruta del config", err)
+			}
+			for _, esperado := range caso.errores {
+				if !strings.Contains(err.Error(), esperado) {
+					t.Errorf("error = %q, no contiene %q", err, esperado)
+				}
+			}
+		})
+	}
+}
+
+func TestCargarConfiguracionJuntaLosErrores(t *testing.T) {
+	ruta := escribirConfig(t, `{"algoritmo": "fifo", "port_memory": 0, "delay": -1, "extra": 1}`)
+	var config configPrueba
+	err := CargarConfiguracion("prueba", ruta, &config, esquemaPrueba)
+	esperado := "config " + ruta + " invalido:\n" +
+		"  - campos desconocidos: extra\n" +
+		"  - falta ip_memory\n" +
+		`  - algoritmo="fifo" invalido, ¿quisiste decir "FIFO"?` + "\n" +
+		"  - delay=-1 invalido, tiene que ser mayor o igual a 0"
+	if err == nil || err.Error() != esperado {
+		t.Errorf("error =\n%v\nse esperaba\n%s", err, esperado)
+	}
+}
+
+func TestCargarConfiguracionEsquemaInvalido(t *testing.T) {
+	ruta := escribirConfig(t, `{"ip_memory": "127.0.0.1"}`)
+	casos := []struct {
+		nombre  string
+		config  any
+		esquema Esquema
+		error   string
+	}{
+		{"config que no es puntero", configPrueba{}, esquemaPrueba, "tiene que ser un puntero a struct"},
+		{"campo que no existe", &configPrueba{}, Esquema{"puerto": {}}, "tiene el campo puerto que no esta en el config"},
+		{"default de otro tipo", &configPrueba{}, Esquema{"port_memory": {Defecto: "8002"}}, "el default de port_memory (8002) no es del tipo del campo"},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			err := CargarConfiguracion("prueba", ruta, caso.config, caso.esquema)
+			if err == nil || !strings.Contains(err.Error(), caso.error) {
+				t.Errorf("error = %v, se esperaba %q", err, caso.error)
+			}
+		})
+	}
+}
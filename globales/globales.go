@@ -2,15 +2,14 @@ package globales
 
 import (
 	//"bufio"
-	"bytes"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"log/slog"
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 	//"time"
 )
 
@@ -32,33 +31,88 @@ type HandshakeCPU struct {
 }
 
 type SolicitudIO struct {
-	NOMBRE string `json:"nombre"`
-	TIEMPO int    `json:"tiempo"` // en milisegundos
-	PID    int    `json:"pid"`
-	PC     int    `json:"pc"`
+	NOMBRE    string    `json:"nombre"`
+	TIEMPO    int       `json:"tiempo"` // en milisegundos
+	PID       int       `json:"pid"`
+	PC        int       `json:"pc"`
+	HANDLE    int       `json:"handle"` // solo para IO_ASYNC
+	REGISTROS Registros `json:"registros"`
+}
+
+type SolicitudEsperaIO struct {
+	PID       int       `json:"pid"`
+	PC        int       `json:"pc"`
+	HANDLE    int       `json:"handle"`
+	REGISTROS Registros `json:"registros"`
 }
 
 type SolicitudDump struct {
-	NOMBRE string `json:"nombre"`
-	PID    int    `json:"pid"`
-	PC     int    `json:"pc"`
+	NOMBRE    string    `json:"nombre"`
+	PID       int       `json:"pid"`
+	PC        int       `json:"pc"`
+	REGISTROS Registros `json:"registros"`
 }
 
 type SolicitudProceso struct {
 	ARCHIVO_PSEUDOCODIGO string `json:"archivo_pseudocodigo"`
 	TAMAÑO_PROCESO       int    `json:"tamanio_proceso"`
 	PID                  int    `json:"pid"`
+	PRIORIDAD            int    `json:"prioridad"` // menor valor = mayor prioridad
 }
 
 type ProcesoAEjecutar struct {
-	PID int `json:"pid"`
-	PC  int `json:"pc"`
+	PID       int       `json:"pid"`
+	PC        int       `json:"pc"`
+	REGISTROS Registros `json:"registros"`
+	TAMANIO   int       `json:"tamanio"` // para que la CPU detecte accesos fuera del proceso
 }
 
-type Interrupcion struct {
+// La CPU avisa que el proceso provoco un fallo (por ejemplo un acceso fuera de su espacio) y el kernel lo finaliza
+type Excepcion struct {
 	PID    int    `json:"pid"`
 	PC     int    `json:"pc"`
-	MOTIVO string `json:"motivo"`
+	TIPO   string `json:"tipo"`   // uno de los Excepcion*, el kernel lo usa como motivo de salida
+	MOTIVO string `json:"motivo"` // detalle para el log
+}
+
+const (
+	ExcepcionAccesoInvalido      = "ACCESO_INVALIDO"
+	ExcepcionViolacionProteccion = "VIOLACION_PROTECCION"
+	ExcepcionInstruccionInvalida = "INSTRUCCION_INVALIDA"
+	ExcepcionErrorMemoria        = "ERROR_MEMORIA" // memoria no respondio o rechazo un pedido que el proceso necesitaba
+)
+
+type Interrupcion struct {
+	PID       int       `json:"pid"`
+	PC        int       `json:"pc"`
+	MOTIVO    string    `json:"motivo"` // uno de los Interrupcion*
+	REGISTROS Registros `json:"registros"`
+}
+
+// Comando del depurador de un nucleo de CPU (/cpu/{id}/debug)
+type ComandoDepuracion struct {
+	ACCION    string `json:"accion"`    // BREAKPOINT, QUITAR_BREAKPOINT, PASO, CONTINUAR, ESTADO o MEMORIA
+	PID       int    `json:"pid"`       // BREAKPOINT y QUITAR_BREAKPOINT
+	PC        int    `json:"pc"`        // BREAKPOINT y QUITAR_BREAKPOINT
+	DIRECCION int    `json:"direccion"` // MEMORIA: direccion logica del proceso detenido
+	TAMANIO   int    `json:"tamanio"`   // MEMORIA
+}
+
+// Tipos de interrupcion que atiende el controlador de la CPU, de mayor a menor prioridad
+const (
+	InterrupcionFallo    = "FALLO"    // el proceso provoco una excepcion
+	InterrupcionQuantum  = "QUANTUM"  // vencio el timer de la CPU
+	InterrupcionDesalojo = "DESALOJO" // el kernel desaloja por el algoritmo de planificacion
+	InterrupcionFinIO    = "FIN_IO"   // el kernel desaloja porque termino la IO de un proceso mas prioritario
+)
+
+// Registros de proposito general. Viajan con el proceso entre kernel y CPU
+// para que se conserven en los cambios de contexto.
+type Registros struct {
+	AX uint32 `json:"ax"`
+	BX uint32 `json:"bx"`
+	CX uint32 `json:"cx"`
+	DX uint32 `json:"dx"`
 }
 
 type PID struct {
@@ -80,7 +134,7 @@ type LeerMarcoMemoria struct {
 type EscribirMemoria struct {
 	DIRECCION int    `json:"direccion"`
 	PID       int    `json:"pid"`
-	DATOS     string `json:"datos"`
+	DATOS     []byte `json:"datos"` // bytes crudos, MOV_OUT escribe valores binarios
 }
 
 type EscribirMarcoMemoria struct {
@@ -100,37 +154,69 @@ type ObtenerMarco struct {
 	Entradas_Nivel_X []int `json:"entradas_nivel_x"` // Representa las entradas de la tabla de páginas
 }
 
-// Revisando la consigna nos dimos cuenta que no nos piden interactuar con los registros del CPU
+// Respuesta de memoria a /cpu/obtener_marco. La CPU guarda la proteccion en la TLB junto al marco
+type MarcoObtenido struct {
+	NUMERO_MARCO int  `json:"numero_marco"`
+	SOLO_LECTURA bool `json:"solo_lectura"`
+}
+
+// MPROTECT: cambia la proteccion de las paginas que cubren [DIRECCION, DIRECCION+TAMANIO)
+type SolicitudMProtect struct {
+	PID          int  `json:"pid"`
+	DIRECCION    int  `json:"direccion"` // logica
+	TAMANIO      int  `json:"tamanio"`
+	SOLO_LECTURA bool `json:"solo_lectura"`
+}
+
+// Memoria le avisa a las CPUs que las traducciones y paginas cacheadas de un PID dejan de valer.
+// Con DUMP solo se bajan las paginas modificadas, sin invalidar nada.
+type InvalidacionASID struct {
+	PID    int    `json:"pid"`
+	MOTIVO string `json:"motivo"` // FINALIZAR, SWAP, MPROTECT o DUMP
+}
+
 // PC va a ser una variable propia de cada instancia del modulo CPU.
+// Los registros de proposito general viajan en ProcesoAEjecutar y se guardan en el PCB.
 
 type PeticionInstruccion struct {
 	PC  int `json:"pc"`
 	PID int `json:"pid"`
 }
 
+// Pide CANTIDAD instrucciones seguidas desde PC. Memoria devuelve un []string
+// que puede ser mas corto si el programa termina antes.
+type PeticionInstrucciones struct {
+	PC       int `json:"pc"`
+	PID      int `json:"pid"`
+	CANTIDAD int `json:"cantidad"`
+}
+
 // ------ FUNCIONES GLOBALES ------ //
 // Logging
-func ConfigurarLogger(nombreArchivoLog string, log_level string) {
-	logFile, err := os.OpenFile(nombreArchivoLog, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0666)
+func ConfigurarLogger(nombreArchivoLog string, log_level string, config ConfigLog) {
+	logFile, err := abrirArchivoRotativo(nombreArchivoLog, config)
 	if err != nil {
 		log.Println("No se pudo crear el logger")
 		panic(err)
 	}
 
-	nivel := LogLevelFromString(log_level)
+	nivelLog.Set(LogLevelFromString(log_level))
 
-	// Handler de color que también escribe en archivo
-	//colorHandler := &ColorHandler{fileWriter: logFile}
-	//logger := slog.New(colorHandler)
-	//slog.SetDefault(logger)
-
-	//mw := io.MultiWriter(os.Stdout,logFile)
-	mw := io.MultiWriter(logFile)
-	log.SetOutput(mw)
-
-	slog.SetLogLoggerLevel(nivel)
+	handler, errFormato := nuevoManejadorArchivo(logFile, config.LOG_FORMAT)
+	if errFormato != nil {
+		handler, _ = nuevoManejadorArchivo(logFile, "text")
+	}
+	if config.LOG_STDOUT {
+		// Handler de color para la consola, el archivo queda sin colores
+		consola := &manejadorTexto{salida: os.Stdout, mutex: &sync.Mutex{}, color: true}
+		handler = manejadorMultiple{handler, consola}
+	}
+	slog.SetDefault(slog.New(handler))
 
 	slog.Info("Logger iniciado correctamente")
+	if errFormato != nil {
+		slog.Warn(fmt.Sprintf("%s, se usa text", errFormato.Error()))
+	}
 }
 
 func LogLevelFromString(nivel string) slog.Level {
@@ -148,104 +234,12 @@ func LogLevelFromString(nivel string) slog.Level {
 	}
 }
 
-func GenerarYEnviarPaquete[T any](estructura *T, ip string, puerto int, ruta string) (*http.Response, []byte) {
-	// URL del servidor
-	url := fmt.Sprintf("http://%s:%d%s", ip, puerto, ruta)
-
-	// Converir el paquete a formato JSON
-	body, err := json.Marshal(estructura)
-	if err != nil {
-		slog.Error(fmt.Sprintf("Error codificando el paquete: %s", err.Error()))
-		//panic(err)
-		return &http.Response{StatusCode: http.StatusInternalServerError, Status: "500 Error codificando JSON"}, nil
-	}
-
-	// Enviamos el POST al servidor usando un cliente con timeout fijo de 5 segundos
-/* 	client := &http.Client{
-		Timeout: 5 * time.Second,
-	} */
-	byteData := []byte(body)
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(byteData))
-	if err != nil {
-		slog.Error(fmt.Sprintf("Error enviando mensajes a ip:%s puerto:%d", ip, puerto))
-		return &http.Response{StatusCode: http.StatusServiceUnavailable, Status: "503 Servicio no disponible"}, nil
-	}
-	defer resp.Body.Close()
-
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		slog.Error("Error al leer el cuerpo de la respuesta")
-		//panic(err)
-		return &http.Response{StatusCode: http.StatusBadGateway, Status: "502 Error leyendo respuesta"}, nil
-	}
-
-	// Verificar respuesta del servidor
-	if resp.StatusCode != http.StatusOK {
-		slog.Error(fmt.Sprintf("Error en la respuesta del servidor: %s", resp.Status))
-		//panic("El servidor no proporciona una respuesta adecuada")
-	}
-	slog.Debug(fmt.Sprintf("Respuesta del servidor: %s", resp.Status))
-
-	slog.Debug("Paquete enviado!")
-
-	return resp, bodyBytes
-
-}
-
-/*
-
-func GenerarYEnviarPaquete[T any](estructura *T, ip string, puerto int, ruta string) (*http.Response, []byte) {
-    // URL del servidor
-    url := fmt.Sprintf("http://%s:%d%s", ip, puerto, ruta)
-
-    // Convertir el paquete a formato JSON
-    body, err := json.Marshal(estructura)
-    if err != nil {
-        slog.Error(fmt.Sprintf("Error codificando el paquete: %s", err.Error()))
-        return &http.Response{StatusCode: http.StatusInternalServerError, Status: "500 Error codificando JSON"}, nil
-    }
-
-    // Crear la request manualmente
-    req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
-    if err != nil {
-        slog.Error(fmt.Sprintf("Error creando la request: %s", err.Error()))
-        return &http.Response{StatusCode: http.StatusInternalServerError, Status: "500 Error creando request"}, nil
-    }
-    req.Header.Set("Content-Type", "application/json")
-
-    // Cliente con timeout fijo de 5 segundos
-    client := &http.Client{
-        Timeout: 5 * time.Second,
-    }
-
-    resp, err := client.Do(req)
-    if err != nil {
-        slog.Error(fmt.Sprintf("Error enviando mensajes a ip:%s puerto:%d", ip, puerto))
-        return &http.Response{StatusCode: http.StatusServiceUnavailable, Status: "503 Servicio no disponible"}, nil
-    }
-    defer resp.Body.Close()
-
-    bodyBytes, err := io.ReadAll(resp.Body)
-    if err != nil {
-        slog.Error("Error al leer el cuerpo de la respuesta")
-        return &http.Response{StatusCode: http.StatusBadGateway, Status: "502 Error leyendo respuesta"}, nil
-    }
-
-    // Verificar respuesta del servidor
-    if resp.StatusCode != http.StatusOK {
-        slog.Error(fmt.Sprintf("Error en la respuesta del servidor: %s", resp.Status))
-    }
-    slog.Debug(fmt.Sprintf("Respuesta del servidor: %s", resp.Status))
-    slog.Debug("Paquete enviado!")
-
-    return resp, bodyBytes
-}
-
-*/
-
 func DecodificarPaquete[T any](w http.ResponseWriter, r *http.Request, estructura *T) T {
-	decoder := json.NewDecoder(r.Body)
-	err := decoder.Decode(&estructura) //decodifica cualquier estructura que le pases por referencia sin importar su forma
+	binario, err := decodificarBinario(r, estructura) // paquetes que llegan en binario por el transporte TCP
+	if !binario {
+		decoder := json.NewDecoder(r.Body)
+		err = decoder.Decode(&estructura) //decodifica cualquier estructura que le pases por referencia sin importar su forma
+	}
 	if err != nil {
 		var zero T
 		slog.Error(fmt.Sprintf("Error al decodificar mensaje: %s\n", err.Error()))
@@ -253,5 +247,6 @@ func DecodificarPaquete[T any](w http.ResponseWriter, r *http.Request, estructur
 		w.Write([]byte("Error al decodificar mensaje"))
 		return zero // sujeto a modificaciones
 	}
+	propagarTraza(r, estructura) // el trace del pedido queda asociado al PID del paquete
 	return *estructura
 }
@@ -0,0 +1,258 @@
+package globales
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ------ LOGGING ------ //
+// Los logs van al archivo del modulo en texto (el formato de siempre) o en JSON, con rotacion opcional
+// por tamanio y por antiguedad. Con LOG_STDOUT tambien se muestran por consola, con colores por nivel.
+// Los logs obligatorios llevan atributos tipados (pid, cpu_id, page, frame, state...) ademas del mensaje,
+// pero solo en JSON: en texto esas lineas quedan exactamente como las pide el enunciado.
+
+// Campos opcionales que cada modulo embebe en su config
+type ConfigLog struct {
+	LOG_FORMAT    string `json:"log_format"`    // "text" (default) o "json"
+	LOG_STDOUT    bool   `json:"log_stdout"`    // ademas del archivo, loguear por consola con colores
+	LOG_MAX_SIZE  int    `json:"log_max_size"`  // KB antes de rotar el archivo, 0 = sin limite
+	LOG_MAX_AGE   int    `json:"log_max_age"`   // minutos antes de rotar el archivo, 0 = sin limite
+	LOG_MAX_FILES int    `json:"log_max_files"` // archivos rotados que se conservan, 0 = 5
+}
+
+var nivelLog slog.LevelVar
+
+// ------ ROTACION ------ //
+// archivoRotativo renombra el log a <nombre>.1 (y los anteriores a .2, .3...) cuando supera el tamanio
+// o la antiguedad configurados, y sigue escribiendo en un archivo nuevo con el nombre original
+type archivoRotativo struct {
+	nombre    string
+	tamanio   int64         // 0 = sin limite
+	edad      time.Duration // 0 = sin limite
+	guardados int
+	archivo   *os.File
+	escrito   int64
+	apertura  time.Time
+	mutex     sync.Mutex
+}
+
+func abrirArchivoRotativo(nombre string, config ConfigLog) (*archivoRotativo, error) {
+	a := &archivoRotativo{
+		nombre:    nombre,
+		tamanio:   int64(max(config.LOG_MAX_SIZE, 0)) * 1024,
+		edad:      time.Duration(max(config.LOG_MAX_AGE, 0)) * time.Minute,
+		guardados: config.LOG_MAX_FILES,
+	}
+	if a.guardados <= 0 {
+		a.guardados = 5
+	}
+	return a, a.abrir()
+}
+
+func (a *archivoRotativo) abrir() error {
+	archivo, err := os.OpenFile(a.nombre, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0666)
+	if err != nil {
+		return err
+	}
+	info, err := archivo.Stat()
+	if err != nil {
+		archivo.Close()
+		return err
+	}
+	a.archivo, a.escrito, a.apertura = archivo, info.Size(), time.Now()
+	return nil
+}
+
+func (a *archivoRotativo) Write(p []byte) (int, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.debeRotar(len(p)) {
+		if err := a.rotar(); err != nil {
+			// si no se pudo rotar se sigue escribiendo en el archivo actual, es mejor que perder logs
+			fmt.Fprintf(os.Stderr, "No se pudo rotar el log %s: %s\n", a.nombre, err.Error())
+		}
+	}
+	n, err := a.archivo.Write(p)
+	a.escrito += int64(n)
+	return n, err
+}
+
+func (a *archivoRotativo) debeRotar(largo int) bool {
+	if a.escrito == 0 {
+		return false
+	}
+	if a.tamanio > 0 && a.escrito+int64(largo) > a.tamanio {
+		return true
+	}
+	return a.edad > 0 && time.Since(a.apertura) >= a.edad
+}
+
+func (a *archivoRotativo) rotar() error {
+	if err := a.archivo.Close(); err != nil {
+		return err
+	}
+	os.Remove(fmt.Sprintf("%s.%d", a.nombre, a.guardados))
+	for i := a.guardados - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", a.nombre, i), fmt.Sprintf("%s.%d", a.nombre, i+1))
+	}
+	if err := os.Rename(a.nombre, a.nombre+".1"); err != nil {
+		return err
+	}
+	return a.abrir()
+}
+
+// ------ HANDLERS ------ //
+// Claves de los atributos tipados de los logs obligatorios, que el formato text no escribe
+var atributosTipados = map[string]bool{
+	"pid": true, "cpu_id": true, "pc": true, "instruccion": true, "page": true, "frame": true, "address": true,
+	"size": true, "state": true, "state_from": true, "syscall": true, "io": true, "motivo": true, "duration": true,
+}
+
+// manejadorTexto escribe "2006/01/02 15:04:05 NIVEL mensaje clave=valor ...", igual que el logger por
+// defecto de slog, y opcionalmente con colores por nivel para la consola
+type manejadorTexto struct {
+	salida    io.Writer
+	mutex     *sync.Mutex
+	color     bool
+	atributos []slog.Attr
+	grupo     string
+}
+
+const (
+	colorReset    = "\033[0m"
+	colorGris     = "\033[90m"
+	colorCian     = "\033[36m"
+	colorAmarillo = "\033[33m"
+	colorRojo     = "\033[31m"
+)
+
+func colorDeNivel(nivel slog.Level) string {
+	switch {
+	case nivel >= slog.LevelError:
+		return colorRojo
+	case nivel >= slog.LevelWarn:
+		return colorAmarillo
+	case nivel >= slog.LevelInfo:
+		return colorCian
+	default:
+		return colorGris
+	}
+}
+
+func (m *manejadorTexto) Enabled(_ context.Context, nivel slog.Level) bool {
+	return nivel >= nivelLog.Level()
+}
+
+func (m *manejadorTexto) Handle(_ context.Context, registro slog.Record) error {
+	var linea strings.Builder
+	linea.WriteString(registro.Time.Format("2006/01/02 15:04:05 "))
+	if m.color {
+		linea.WriteString(colorDeNivel(registro.Level) + registro.Level.String() + colorReset)
+	} else {
+		linea.WriteString(registro.Level.String())
+	}
+	linea.WriteString(" " + registro.Message)
+
+	escribirAtributo := func(atributo slog.Attr) bool {
+		if atributosTipados[atributo.Key] {
+			return true
+		}
+		clave := atributo.Key
+		if m.grupo != "" {
+			clave = m.grupo + "." + clave
+		}
+		if m.color {
+			clave = colorGris + clave + colorReset
+		}
+		valor := atributo.Value.Resolve().String()
+		if valor == "" || strings.ContainsAny(valor, " =\"\n") {
+			valor = strconv.Quote(valor) // asi cada atributo se puede separar por espacios
+		}
+		fmt.Fprintf(&linea, " %s=%s", clave, valor)
+		return true
+	}
+	for _, atributo := range m.atributos {
+		escribirAtributo(atributo)
+	}
+	registro.Attrs(escribirAtributo)
+	linea.WriteString("\n")
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	_, err := io.WriteString(m.salida, linea.String())
+	return err
+}
+
+func (m *manejadorTexto) WithAttrs(atributos []slog.Attr) slog.Handler {
+	copia := *m
+	copia.atributos = append(append([]slog.Attr{}, m.atributos...), atributos...)
+	return &copia
+}
+
+func (m *manejadorTexto) WithGroup(nombre string) slog.Handler {
+	copia := *m
+	if copia.grupo != "" {
+		nombre = copia.grupo + "." + nombre
+	}
+	copia.grupo = nombre
+	return &copia
+}
+
+// manejadorMultiple reparte cada registro entre el archivo y la consola
+type manejadorMultiple []slog.Handler
+
+func (m manejadorMultiple) Enabled(ctx context.Context, nivel slog.Level) bool {
+	for _, handler := range m {
+		if handler.Enabled(ctx, nivel) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m manejadorMultiple) Handle(ctx context.Context, registro slog.Record) error {
+	var primerError error
+	for _, handler := range m {
+		if !handler.Enabled(ctx, registro.Level) {
+			continue
+		}
+		if err := handler.Handle(ctx, registro.Clone()); err != nil && primerError == nil {
+			primerError = err
+		}
+	}
+	return primerError
+}
+
+func (m manejadorMultiple) WithAttrs(atributos []slog.Attr) slog.Handler {
+	handlers := make(manejadorMultiple, len(m))
+	for i, handler := range m {
+		handlers[i] = handler.WithAttrs(atributos)
+	}
+	return handlers
+}
+
+func (m manejadorMultiple) WithGroup(nombre string) slog.Handler {
+	handlers := make(manejadorMultiple, len(m))
+	for i, handler := range m {
+		handlers[i] = handler.WithGroup(nombre)
+	}
+	return handlers
+}
+
+func nuevoManejadorArchivo(salida io.Writer, formato string) (slog.Handler, error) {
+	switch strings.ToLower(formato) {
+	case "", "text":
+		return &manejadorTexto{salida: salida, mutex: &sync.Mutex{}}, nil
+	case "json":
+		return slog.NewJSONHandler(salida, &slog.HandlerOptions{Level: &nivelLog}), nil
+	default:
+		return nil, fmt.Errorf("formato de log invalido: %s (se espera text o json)", formato)
+	}
+}
@@ -0,0 +1,331 @@
+// Package pseudocodigo valida los archivos de instrucciones antes de cargarlos en memoria.
+// Chequea opcodes y cantidad de parametros, resuelve las etiquetas usadas en GOTO/JNZ
+// y verifica que las direcciones literales entren en el tamaño del proceso.
+package pseudocodigo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Error de una linea del archivo, con el formato archivo:linea: mensaje
+type Error struct {
+	Archivo string
+	Linea   int // empieza en 1
+	Mensaje string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.Archivo, e.Linea, e.Mensaje)
+}
+
+// Todos los errores encontrados en un archivo, uno por linea
+type Errores []*Error
+
+func (e Errores) Error() string {
+	mensajes := make([]string, len(e))
+	for i, err := range e {
+		mensajes[i] = err.Error()
+	}
+	return strings.Join(mensajes, "\n")
+}
+
+// Opciones de validacion
+type Opciones struct {
+	TamanioProceso int // si es mayor a 0 se validan las direcciones literales contra este tamaño
+}
+
+type tipoOperando int
+
+const (
+	texto      tipoOperando = iota // cualquier token (nombre de dispositivo, archivo, datos a escribir)
+	entero                         // entero no negativo
+	direccion                      // direccion logica literal
+	registro                       // AX, BX, CX o DX
+	operando                       // registro o inmediato de 32 bits
+	destino                        // numero de instruccion o etiqueta
+	proteccion                     // RO o RW
+)
+
+const tamanioRegistro = 4 // bytes que leen/escriben MOV_IN y MOV_OUT
+
+type formato struct {
+	operandos  []tipoOperando
+	opcionales int // cantidad de operandos finales que se pueden omitir
+}
+
+var formatos = map[string]formato{
+	"NOOP":        {},
+	"WRITE":       {operandos: []tipoOperando{direccion, texto}},
+	"READ":        {operandos: []tipoOperando{direccion, entero}},
+	"GOTO":        {operandos: []tipoOperando{destino}},
+	"IO":          {operandos: []tipoOperando{texto, entero}},
+	"IO_ASYNC":    {operandos: []tipoOperando{texto, entero, entero}},
+	"IO_WAIT":     {operandos: []tipoOperando{entero}},
+	"INIT_PROC":   {operandos: []tipoOperando{texto, entero, entero}, opcionales: 1},
+	"DUMP_MEMORY": {},
+	"EXIT":        {},
+	"SET":         {operandos: []tipoOperando{registro, operando}},
+	"SUM":         {operandos: []tipoOperando{registro, operando}},
+	"SUB":         {operandos: []tipoOperando{registro, operando}},
+	"MOV_IN":      {operandos: []tipoOperando{registro, operando}},
+	"MOV_OUT":     {operandos: []tipoOperando{operando, registro}},
+	"JNZ":         {operandos: []tipoOperando{registro, destino}},
+	"MPROTECT":    {operandos: []tipoOperando{operando, entero, proteccion}},
+}
+
+var registros = map[string]bool{"AX": true, "BX": true, "CX": true, "DX": true}
+
+var nombreEtiqueta = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+type lineaFuente struct {
+	numero int
+	campos []string
+}
+
+// Lo necesario para resolver el destino de un GOTO/JNZ
+type destinos struct {
+	etiquetas map[string]int
+	pcDeLinea map[int]int // linea del archivo contando desde 0 -> PC de la instruccion que tiene
+	lineas    int         // cantidad de lineas del archivo
+}
+
+// EnsamblarArchivo lee y valida un archivo de pseudocodigo
+func EnsamblarArchivo(ruta string, opciones Opciones) ([]string, error) {
+	archivo, err := os.Open(ruta)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo abrir el archivo '%s': %w", ruta, err)
+	}
+	defer archivo.Close()
+
+	return Ensamblar(filepath.Base(ruta), archivo, opciones)
+}
+
+// Ensamblar valida las instrucciones y devuelve una por PC, con las etiquetas ya reemplazadas
+// por el numero de instruccion. Las lineas vacias, los comentarios (# al principio de un token) y las
+// etiquetas no ocupan PC. Un destino numerico sigue siendo el numero de linea del archivo contando
+// desde 0, como numeraba memoria antes, y se traduce al PC de la instruccion de esa linea.
+// Si hay errores se devuelven todos juntos en un Errores.
+func Ensamblar(nombreArchivo string, r io.Reader, opciones Opciones) ([]string, error) {
+	var errores Errores
+	agregarError := func(linea int, formatoMensaje string, args ...any) {
+		errores = append(errores, &Error{Archivo: nombreArchivo, Linea: linea, Mensaje: fmt.Sprintf(formatoMensaje, args...)})
+	}
+
+	// primera pasada: separo etiquetas de instrucciones
+	etiquetas := make(map[string]int)
+	lineaEtiqueta := make(map[string]int)
+	var lineas []lineaFuente
+
+	scanner := bufio.NewScanner(r)
+	numeroLinea := 0
+	for scanner.Scan() {
+		numeroLinea++
+		campos := strings.Fields(scanner.Text())
+		// el # solo abre un comentario al principio de un token, asi los datos de un WRITE pueden tenerlo
+		for i, campo := range campos {
+			if strings.HasPrefix(campo, "#") {
+				campos = campos[:i]
+				break
+			}
+		}
+
+		if len(campos) > 0 && strings.HasSuffix(campos[0], ":") {
+			etiqueta := strings.TrimSuffix(campos[0], ":")
+			if !nombreEtiqueta.MatchString(etiqueta) {
+				agregarError(numeroLinea, "etiqueta invalida '%s'", etiqueta)
+			} else if _, existe := etiquetas[etiqueta]; existe {
+				agregarError(numeroLinea, "etiqueta '%s' duplicada", etiqueta)
+			} else {
+				etiquetas[etiqueta] = len(lineas)
+				lineaEtiqueta[etiqueta] = numeroLinea
+			}
+			campos = campos[1:]
+		}
+
+		if len(campos) == 0 {
+			continue
+		}
+		lineas = append(lineas, lineaFuente{numero: numeroLinea, campos: campos})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error al leer el archivo '%s': %w", nombreArchivo, err)
+	}
+
+	for etiqueta, pc := range etiquetas {
+		if pc == len(lineas) { // la etiqueta esta despues de la ultima instruccion
+			agregarError(lineaEtiqueta[etiqueta], "etiqueta '%s' sin instruccion despues", etiqueta)
+			delete(etiquetas, etiqueta)
+		}
+	}
+	resolucion := &destinos{etiquetas: etiquetas, pcDeLinea: make(map[int]int, len(lineas)), lineas: numeroLinea}
+	for pc, linea := range lineas {
+		resolucion.pcDeLinea[linea.numero-1] = pc
+	}
+
+	// segunda pasada: valido cada instruccion y resuelvo destinos
+	instrucciones := make([]string, 0, len(lineas))
+	for _, linea := range lineas {
+		opcode := linea.campos[0]
+		parametros := linea.campos[1:]
+
+		formatoInstruccion, ok := formatos[opcode]
+		if !ok {
+			agregarError(linea.numero, "instruccion desconocida '%s'", opcode)
+			continue
+		}
+
+		minimo := len(formatoInstruccion.operandos) - formatoInstruccion.opcionales
+		maximo := len(formatoInstruccion.operandos)
+		if len(parametros) < minimo || len(parametros) > maximo {
+			if minimo == maximo {
+				agregarError(linea.numero, "%s espera %d parametros, tiene %d", opcode, maximo, len(parametros))
+			} else {
+				agregarError(linea.numero, "%s espera entre %d y %d parametros, tiene %d", opcode, minimo, maximo, len(parametros))
+			}
+			continue
+		}
+
+		resueltos := make([]string, len(parametros))
+		valida := true
+		for i, parametro := range parametros {
+			resuelto, err := validarOperando(formatoInstruccion.operandos[i], parametro, resolucion)
+			if err != nil {
+				agregarError(linea.numero, "%s: parametro %d: %s", opcode, i+1, err.Error())
+				valida = false
+				continue
+			}
+			resueltos[i] = resuelto
+		}
+		if valida {
+			if err := validarRango(opcode, resueltos, opciones); err != nil {
+				agregarError(linea.numero, "%s: %s", opcode, err.Error())
+				valida = false
+			}
+		}
+		if !valida {
+			continue
+		}
+
+		instrucciones = append(instrucciones, strings.Join(append([]string{opcode}, resueltos...), " "))
+	}
+
+	if len(errores) > 0 {
+		sort.SliceStable(errores, func(i, j int) bool { return errores[i].Linea < errores[j].Linea })
+		return nil, errores
+	}
+	return instrucciones, nil
+}
+
+// ValidarInstruccion chequea una instruccion ya ensamblada (sin etiquetas), como la que ejecuta la CPU
+func ValidarInstruccion(instruccion string) error {
+	campos := strings.Fields(instruccion)
+	if len(campos) == 0 {
+		return fmt.Errorf("instruccion vacia")
+	}
+
+	formatoInstruccion, ok := formatos[campos[0]]
+	if !ok {
+		return fmt.Errorf("instruccion desconocida '%s'", campos[0])
+	}
+	parametros := campos[1:]
+	if len(parametros) < len(formatoInstruccion.operandos)-formatoInstruccion.opcionales || len(parametros) > len(formatoInstruccion.operandos) {
+		return fmt.Errorf("%s: cantidad de parametros invalida (%d)", campos[0], len(parametros))
+	}
+	for i, parametro := range parametros {
+		if _, err := validarOperando(formatoInstruccion.operandos[i], parametro, nil); err != nil {
+			return fmt.Errorf("%s: parametro %d: %s", campos[0], i+1, err.Error())
+		}
+	}
+	return nil
+}
+
+// Con resolucion nil (instruccion ya ensamblada) un destino solo tiene que ser un PC valido
+func validarOperando(tipo tipoOperando, parametro string, resolucion *destinos) (string, error) {
+	switch tipo {
+	case entero, direccion:
+		if _, err := strconv.ParseUint(parametro, 10, 31); err != nil {
+			return "", fmt.Errorf("'%s' no es un entero valido", parametro)
+		}
+	case registro:
+		if !registros[parametro] {
+			return "", fmt.Errorf("'%s' no es un registro (AX, BX, CX, DX)", parametro)
+		}
+	case operando:
+		if registros[parametro] {
+			return parametro, nil
+		}
+		if _, err := strconv.ParseUint(parametro, 10, 32); err != nil {
+			return "", fmt.Errorf("'%s' no es un registro ni un valor de 32 bits", parametro)
+		}
+	case proteccion:
+		if parametro != "RO" && parametro != "RW" {
+			return "", fmt.Errorf("'%s' no es una proteccion (RO, RW)", parametro)
+		}
+	case destino:
+		numero, err := strconv.Atoi(parametro)
+		if resolucion == nil {
+			if err != nil || numero < 0 {
+				return "", fmt.Errorf("'%s' no es un numero de instruccion", parametro)
+			}
+			return parametro, nil
+		}
+		if pc, ok := resolucion.etiquetas[parametro]; ok {
+			return strconv.Itoa(pc), nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("etiqueta '%s' no definida", parametro)
+		}
+		if numero < 0 || numero >= resolucion.lineas {
+			return "", fmt.Errorf("destino %d fuera del programa (0-%d)", numero, resolucion.lineas-1)
+		}
+		pc, ok := resolucion.pcDeLinea[numero]
+		if !ok {
+			return "", fmt.Errorf("destino %d es la linea %d, que no tiene instruccion", numero, numero+1)
+		}
+		return strconv.Itoa(pc), nil
+	}
+	return parametro, nil
+}
+
+// Verifica que los accesos con direccion literal no se salgan del proceso
+func validarRango(opcode string, parametros []string, opciones Opciones) error {
+	if opciones.TamanioProceso <= 0 {
+		return nil
+	}
+
+	var direccionLiteral string
+	var tamanioAcceso int
+	switch opcode {
+	case "WRITE":
+		direccionLiteral, tamanioAcceso = parametros[0], len(parametros[1])
+	case "READ":
+		direccionLiteral = parametros[0]
+		tamanioAcceso, _ = strconv.Atoi(parametros[1])
+	case "MOV_IN":
+		direccionLiteral, tamanioAcceso = parametros[1], tamanioRegistro
+	case "MOV_OUT":
+		direccionLiteral, tamanioAcceso = parametros[0], tamanioRegistro
+	case "MPROTECT":
+		direccionLiteral = parametros[0]
+		tamanioAcceso, _ = strconv.Atoi(parametros[1])
+	default:
+		return nil
+	}
+	if registros[direccionLiteral] {
+		return nil // la direccion se conoce recien en ejecucion
+	}
+
+	direccionInicial, _ := strconv.Atoi(direccionLiteral)
+	if direccionInicial+tamanioAcceso > opciones.TamanioProceso {
+		return fmt.Errorf("acceso a %d-%d fuera del proceso (tamaño %d)", direccionInicial, direccionInicial+tamanioAcceso-1, opciones.TamanioProceso)
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package pseudocodigo
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestEnsamblar(t *testing.T) {
+	casos := []struct {
+		nombre   string
+		fuente   string
+		opciones Opciones
+		esperado []string
+	}{
+		{
+			nombre:   "sin etiquetas queda igual",
+			fuente:   "SET AX 1\nSUM AX 2\nEXIT",
+			esperado: []string{"SET AX 1", "SUM AX 2", "EXIT"},
+		},
+		{
+			nombre:   "etiqueta sola en su linea apunta a la instruccion siguiente",
+			fuente:   "SET AX 3\nLOOP:\nSUB AX 1\nJNZ AX LOOP\nEXIT",
+			esperado: []string{"SET AX 3", "SUB AX 1", "JNZ AX 1", "EXIT"},
+		},
+		{
+			nombre:   "etiqueta y instruccion en la misma linea",
+			fuente:   "INICIO: NOOP\nGOTO INICIO",
+			esperado: []string{"NOOP", "GOTO 0"},
+		},
+		{
+			nombre:   "comentarios y lineas vacias no ocupan PC",
+			fuente:   "# programa\n\nNOOP # nada\n   \nEXIT",
+			esperado: []string{"NOOP", "EXIT"},
+		},
+		{
+			nombre:   "# en medio de un token no es comentario",
+			fuente:   "WRITE 0 a#b\nEXIT",
+			esperado: []string{"WRITE 0 a#b", "EXIT"},
+		},
+		{
+			nombre:   "destino numerico sin cambios de numeracion",
+			fuente:   "NOOP\nNOOP\nGOTO 1",
+			esperado: []string{"NOOP", "NOOP", "GOTO 1"},
+		},
+		{
+			nombre:   "destino numerico es la linea del archivo aunque haya lineas sin instruccion",
+			fuente:   "# inicio\nNOOP\n\nSET AX 1\nGOTO 3",
+			esperado: []string{"NOOP", "SET AX 1", "GOTO 1"},
+		},
+		{
+			nombre:   "parametro opcional de INIT_PROC",
+			fuente:   "INIT_PROC hijo 64\nINIT_PROC hijo 64 2",
+			esperado: []string{"INIT_PROC hijo 64", "INIT_PROC hijo 64 2"},
+		},
+		{
+			nombre:   "accesos dentro del proceso",
+			fuente:   "WRITE 60 hola\nREAD 0 64\nMOV_IN AX 60\nMOV_OUT BX AX",
+			opciones: Opciones{TamanioProceso: 64},
+			esperado: []string{"WRITE 60 hola", "READ 0 64", "MOV_IN AX 60", "MOV_OUT BX AX"},
+		},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			instrucciones, err := Ensamblar("prueba", strings.NewReader(caso.fuente), caso.opciones)
+			if err != nil {
+				t.Fatalf("error inesperado:\n%v", err)
+			}
+			if !slices.Equal(instrucciones, caso.esperado) {
+				t.Errorf("instrucciones = %q, se esperaba %q", instrucciones, caso.esperado)
+			}
+		})
+	}
+}
+
+func TestEnsamblarErrores(t *testing.T) {
+	casos := []struct {
+		nombre   string
+		fuente   string
+		opciones Opciones
+		errores  []string // "linea: parte del mensaje", en orden
+	}{
+		{
+			nombre:  "etiqueta duplicada",
+			fuente:  "A: NOOP\nA: EXIT",
+			errores: []string{"2: etiqueta 'A' duplicada"},
+		},
+		{
+			nombre:  "etiqueta no definida",
+			fuente:  "GOTO FIN",
+			errores: []string{"1: GOTO: parametro 1: etiqueta 'FIN' no definida"},
+		},
+		{
+			nombre:  "etiqueta invalida",
+			fuente:  "1X: NOOP",
+			errores: []string{"1: etiqueta invalida '1X'"},
+		},
+		{
+			nombre:  "etiqueta al final sin instruccion",
+			fuente:  "NOOP\nFIN:",
+			errores: []string{"2: etiqueta 'FIN' sin instruccion despues"},
+		},
+		{
+			nombre:  "destino numerico a una linea sin instruccion",
+			fuente:  "NOOP\n# comentario\nGOTO 1",
+			errores: []string{"3: GOTO: parametro 1: destino 1 es la linea 2, que no tiene instruccion"},
+		},
+		{
+			nombre:  "destino numerico fuera del programa",
+			fuente:  "NOOP\nGOTO 5",
+			errores: []string{"2: GOTO: parametro 1: destino 5 fuera del programa (0-1)"},
+		},
+		{
+			nombre:  "instruccion desconocida y cantidad de parametros",
+			fuente:  "MOVE AX 1\nSET AX\nINIT_PROC a",
+			errores: []string{"1: instruccion desconocida 'MOVE'", "2: SET espera 2 parametros, tiene 1", "3: INIT_PROC espera entre 2 y 3 parametros, tiene 1"},
+		},
+		{
+			nombre:  "operandos invalidos",
+			fuente:  "SET EX 1\nSUM AX -1\nMPROTECT 0 4 RX",
+			errores: []string{"1: SET: parametro 1: 'EX' no es un registro", "2: SUM: parametro 2: '-1' no es un registro ni un valor de 32 bits", "3: MPROTECT: parametro 3: 'RX' no es una proteccion"},
+		},
+		{
+			nombre:   "accesos fuera del proceso",
+			fuente:   "WRITE 62 hola\nREAD 60 5\nMOV_IN AX 61\nMOV_OUT 64 AX",
+			opciones: Opciones{TamanioProceso: 64},
+			errores: []string{
+				"1: WRITE: acceso a 62-65 fuera del proceso (tamaño 64)",
+				"2: READ: acceso a 60-64 fuera del proceso (tamaño 64)",
+				"3: MOV_IN: acceso a 61-64 fuera del proceso (tamaño 64)",
+				"4: MOV_OUT: acceso a 64-67 fuera del proceso (tamaño 64)",
+			},
+		},
+		{
+			nombre:  "se juntan todos los errores ordenados por linea",
+			fuente:  "GOTO FIN\nNOOP\nX: NOOP\nX:\nFIN:",
+			errores: []string{"1: GOTO: parametro 1: etiqueta 'FIN' no definida", "4: etiqueta 'X' duplicada", "5: etiqueta 'FIN' sin instruccion despues"},
+		},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			instrucciones, err := Ensamblar("prueba", strings.NewReader(caso.fuente), caso.opciones)
+			if err == nil {
+				t.Fatalf("se esperaban errores, se ensamblo %q", instrucciones)
+			}
+			var errores Errores
+			if !errors.As(err, &errores) {
+				t.Fatalf("el error es %T, se esperaba Errores", err)
+			}
+			if len(errores) != len(caso.errores) {
+				t.Fatalf("%d errores, se esperaban %d:\n%v", len(errores), len(caso.errores), err)
+			}
+			for i, esperado := range caso.errores {
+				if !strings.Contains(errores[i].Error(), "prueba:"+esperado) {
+					t.Errorf("error %d = %q, se esperaba que contenga %q", i, errores[i].Error(), "prueba:"+esperado)
+				}
+			}
+		})
+	}
+}
+
+func TestValidarInstruccion(t *testing.T) {
+	casos := []struct {
+		instruccion string
+		valida      bool
+	}{
+		{"NOOP", true},
+		{"GOTO 12", true},
+		{"JNZ AX 0", true},
+		{"WRITE 0 a#b", true},
+		{"GOTO FIN", false}, // ya ensamblada no puede tener etiquetas
+		{"GOTO -1", false},
+		{"", false},
+		{"SET AX", false},
+	}
+	for _, caso := range casos {
+		err := ValidarInstruccion(caso.instruccion)
+		if (err == nil) != caso.valida {
+			t.Errorf("ValidarInstruccion(%q) = %v, se esperaba valida=%t", caso.instruccion, err, caso.valida)
+		}
+	}
+}
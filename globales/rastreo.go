@@ -0,0 +1,340 @@
+package globales
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ------ RASTREO ENTRE MODULOS ------ //
+// Cada pedido entre modulos lleva un header traceparent (W3C) con el trace y el span que lo origino.
+// Los pedidos de un mismo proceso comparten el trace: el kernel lo abre al crear el proceso, cada modulo
+// lo asocia al PID cuando decodifica un paquete y Llamar lo continua en los pedidos que llevan ese PID.
+// Con SPANS_FILE configurado, los spans terminados se escriben en formato OTLP JSON, uno por linea.
+
+const HeaderTraceparent = "traceparent"
+
+// SpanKind de OTLP
+const (
+	spanInterno  = 1
+	spanServidor = 2
+	spanCliente  = 3
+)
+
+type Span struct {
+	TraceID   [16]byte
+	SpanID    [8]byte
+	Padre     [8]byte // cero si es la raiz del trace
+	Nombre    string
+	tipo      int
+	inicio    time.Time
+	atributos map[string]any
+	err       string
+}
+
+func nuevoSpan(padre *Span, nombre string, tipo int) *Span {
+	span := &Span{Nombre: nombre, tipo: tipo, inicio: time.Now(), atributos: make(map[string]any)}
+	if padre != nil {
+		span.TraceID, span.Padre = padre.TraceID, padre.SpanID
+	} else {
+		binary.BigEndian.PutUint64(span.TraceID[:8], rand.Uint64())
+		binary.BigEndian.PutUint64(span.TraceID[8:], rand.Uint64()|1) // un trace ID en cero es invalido
+	}
+	binary.BigEndian.PutUint64(span.SpanID[:], rand.Uint64()|1)
+	return span
+}
+
+func (s *Span) Atributo(clave string, valor any) {
+	s.atributos[clave] = valor
+}
+
+func (s *Span) Fallo(err error) {
+	s.err = err.Error()
+}
+
+func (s *Span) Traza() string {
+	return hex.EncodeToString(s.TraceID[:])
+}
+
+// Traceparent en formato W3C: version-traceid-spanid-flags
+func (s *Span) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-01", hex.EncodeToString(s.TraceID[:]), hex.EncodeToString(s.SpanID[:]))
+}
+
+// Devuelve el span remoto que mando el pedido, o nil si el header falta o es invalido
+func parsearTraceparent(valor string) *Span {
+	partes := strings.Split(valor, "-")
+	if len(partes) != 4 || len(partes[1]) != 32 || len(partes[2]) != 16 {
+		return nil
+	}
+	span := &Span{atributos: make(map[string]any)}
+	if _, err := hex.Decode(span.TraceID[:], []byte(partes[1])); err != nil {
+		return nil
+	}
+	if _, err := hex.Decode(span.SpanID[:], []byte(partes[2])); err != nil {
+		return nil
+	}
+	return span
+}
+
+type claveSpan struct{}
+
+func ContextoConSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, claveSpan{}, span)
+}
+
+func SpanDeContexto(ctx context.Context) *Span {
+	span, _ := ctx.Value(claveSpan{}).(*Span)
+	return span
+}
+
+// ------ TRACES POR PROCESO ------ //
+var raicesProceso = make(map[int]*Span) // spans de vida de los procesos que abrio este modulo (el kernel)
+var spansProceso = make(map[int]*Span)  // ultimo span recibido con el PID, para continuar su trace
+var mutexSpansProceso sync.Mutex
+
+// IniciarSpanProceso abre el trace de un proceso. Dura hasta TerminarSpanProceso
+func IniciarSpanProceso(pid int) {
+	span := nuevoSpan(nil, "proceso", spanInterno)
+	span.Atributo("pid", pid)
+	mutexSpansProceso.Lock()
+	raicesProceso[pid] = span
+	mutexSpansProceso.Unlock()
+	slog.Debug(fmt.Sprintf("## (%d) - Trace %s", pid, span.Traza()))
+}
+
+func TerminarSpanProceso(pid int, motivo string) {
+	mutexSpansProceso.Lock()
+	span, ok := raicesProceso[pid]
+	delete(raicesProceso, pid)
+	delete(spansProceso, pid)
+	mutexSpansProceso.Unlock()
+	if ok {
+		span.Atributo("motivo_salida", motivo)
+		span.Terminar()
+	}
+}
+
+func asociarProceso(pid int, span *Span) {
+	mutexSpansProceso.Lock()
+	anterior, ok := spansProceso[pid]
+	spansProceso[pid] = span
+	mutexSpansProceso.Unlock()
+	// se loguea una vez por trace, para poder buscar el proceso por su trace en los logs de cada modulo
+	if !ok || anterior.TraceID != span.TraceID {
+		slog.Debug(fmt.Sprintf("## (%d) - Trace %s", pid, span.Traza()))
+	}
+}
+
+func spanDeProceso(pid int) *Span {
+	mutexSpansProceso.Lock()
+	defer mutexSpansProceso.Unlock()
+	if span, ok := raicesProceso[pid]; ok {
+		return span
+	}
+	return spansProceso[pid]
+}
+
+// ContextoDeProceso devuelve un contexto que continua el trace del proceso, si este modulo lo conoce
+func ContextoDeProceso(pid int) context.Context {
+	if span := spanDeProceso(pid); span != nil {
+		return ContextoConSpan(context.Background(), span)
+	}
+	return context.Background()
+}
+
+// Busca un campo PID (o NUMERO_PID, como en globales.PID) en el paquete
+func pidDelPaquete(paquete any) (int, bool) {
+	valor := reflect.ValueOf(paquete)
+	for valor.Kind() == reflect.Pointer {
+		if valor.IsNil() {
+			return 0, false
+		}
+		valor = valor.Elem()
+	}
+	if valor.Kind() != reflect.Struct {
+		return 0, false
+	}
+	for _, nombre := range []string{"PID", "NUMERO_PID"} {
+		if campo := valor.FieldByName(nombre); campo.IsValid() && campo.CanInt() && campo.Int() >= 0 {
+			return int(campo.Int()), true
+		}
+	}
+	return 0, false
+}
+
+// Al decodificar un paquete con PID, su trace queda asociado al proceso en este modulo
+func propagarTraza(r *http.Request, paquete any) {
+	span := SpanDeContexto(r.Context())
+	if span == nil {
+		span = parsearTraceparent(r.Header.Get(HeaderTraceparent)) // handler sin Rastrear
+	}
+	if span == nil {
+		return
+	}
+	if pid, ok := pidDelPaquete(paquete); ok {
+		span.Atributo("pid", pid)
+		asociarProceso(pid, span)
+	}
+}
+
+// Rastrear envuelve el handler del modulo: cada pedido recibido abre un span hijo del que lo mando
+func Rastrear(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span := nuevoSpan(parsearTraceparent(r.Header.Get(HeaderTraceparent)), r.URL.Path, spanServidor)
+		respuesta := &respuestaRastreada{ResponseWriter: w, estado: http.StatusOK}
+		handler.ServeHTTP(respuesta, r.WithContext(ContextoConSpan(r.Context(), span)))
+		span.Atributo("http.response.status_code", respuesta.estado)
+		span.Terminar()
+	})
+}
+
+type respuestaRastreada struct {
+	http.ResponseWriter
+	estado int
+}
+
+func (r *respuestaRastreada) WriteHeader(estado int) {
+	r.estado = estado
+	r.ResponseWriter.WriteHeader(estado)
+}
+
+// ------ EXPORTACION ------ //
+var archivoSpans *os.File
+var moduloSpans string
+var mutexSpans sync.Mutex
+
+// IniciarSpans abre el archivo de spans del modulo. Sin ruta no se exporta nada (los headers se propagan igual)
+func IniciarSpans(modulo string, ruta string) error {
+	if ruta == "" {
+		return nil
+	}
+	archivo, err := os.OpenFile(ruta, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("no se pudo abrir el archivo de spans '%s': %w", ruta, err)
+	}
+	mutexSpans.Lock()
+	archivoSpans, moduloSpans = archivo, modulo
+	mutexSpans.Unlock()
+	return nil
+}
+
+func CerrarSpans() {
+	mutexSpans.Lock()
+	defer mutexSpans.Unlock()
+	if archivoSpans != nil {
+		archivoSpans.Close()
+		archivoSpans = nil
+	}
+}
+
+// Estructura de ExportTraceServiceRequest de OTLP en JSON (IDs en hex y tiempos en string)
+type otlpValor struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+type otlpAtributo struct {
+	Key   string    `json:"key"`
+	Value otlpValor `json:"value"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpAtributo `json:"attributes,omitempty"`
+	Status            struct {
+		Code    int    `json:"code,omitempty"` // 2 = error
+		Message string `json:"message,omitempty"`
+	} `json:"status"`
+}
+
+type otlpScopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource struct {
+		Attributes []otlpAtributo `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpExportacion struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+func atributoOTLP(clave string, valor any) otlpAtributo {
+	atributo := otlpAtributo{Key: clave}
+	switch v := valor.(type) {
+	case int:
+		texto := strconv.Itoa(v)
+		atributo.Value.IntValue = &texto
+	case bool:
+		atributo.Value.BoolValue = &v
+	default:
+		texto := fmt.Sprint(v)
+		atributo.Value.StringValue = &texto
+	}
+	return atributo
+}
+
+// Terminar cierra el span y lo escribe si el modulo exporta spans
+func (s *Span) Terminar() {
+	fin := time.Now()
+	mutexSpans.Lock()
+	defer mutexSpans.Unlock()
+	if archivoSpans == nil {
+		return
+	}
+
+	span := otlpSpan{
+		TraceID:           hex.EncodeToString(s.TraceID[:]),
+		SpanID:            hex.EncodeToString(s.SpanID[:]),
+		Name:              s.Nombre,
+		Kind:              s.tipo,
+		StartTimeUnixNano: strconv.FormatInt(s.inicio.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(fin.UnixNano(), 10),
+	}
+	if s.Padre != [8]byte{} {
+		span.ParentSpanID = hex.EncodeToString(s.Padre[:])
+	}
+	for clave, valor := range s.atributos {
+		span.Attributes = append(span.Attributes, atributoOTLP(clave, valor))
+	}
+	if s.err != "" {
+		span.Status.Code, span.Status.Message = 2, s.err
+	}
+
+	scope := otlpScopeSpans{Spans: []otlpSpan{span}}
+	scope.Scope.Name = "globales"
+	recurso := otlpResourceSpans{ScopeSpans: []otlpScopeSpans{scope}}
+	recurso.Resource.Attributes = []otlpAtributo{atributoOTLP("service.name", moduloSpans)}
+	exportacion := otlpExportacion{ResourceSpans: []otlpResourceSpans{recurso}}
+
+	linea, err := json.Marshal(exportacion)
+	if err != nil {
+		return
+	}
+	archivoSpans.Write(append(linea, '\n'))
+}
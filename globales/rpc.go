@@ -0,0 +1,181 @@
+package globales
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ------ CLIENTE RPC ------ //
+// Llamar manda un pedido a otro modulo y devuelve la respuesta decodificada o un error.
+// Un error significa que el pedido no se pudo entregar, que el destino no respondio 200
+// o que la respuesta no se pudo decodificar: el llamador decide que hacer en cada caso.
+
+// La respuesta no tiene cuerpo que interese (solo importa el 200)
+type SinRespuesta struct{}
+
+// El destino respondio con un estado distinto de 200
+type ErrorRespuesta struct {
+	Ruta   string
+	Estado int
+	Cuerpo string
+}
+
+func (e *ErrorRespuesta) Error() string {
+	return fmt.Sprintf("%s respondio %d %s: %s", e.Ruta, e.Estado, http.StatusText(e.Estado), e.Cuerpo)
+}
+
+// EstadoDeError devuelve el estado HTTP de un ErrorRespuesta, o 0 si el pedido no llego a responderse
+func EstadoDeError(err error) int {
+	var errorRespuesta *ErrorRespuesta
+	if errors.As(err, &errorRespuesta) {
+		return errorRespuesta.Estado
+	}
+	return 0
+}
+
+type opcionesRPC struct {
+	timeout    time.Duration // plazo de las llamadas cuyo contexto no trae uno, 0 = sin plazo
+	reintentos int           // reintentos cuando no se pudo conectar con el destino
+	espera     time.Duration // espera antes del primer reintento, se duplica en cada uno
+}
+
+var configRPC = opcionesRPC{espera: 100 * time.Millisecond}
+var mutexConfigRPC sync.RWMutex
+
+// Campos opcionales que cada modulo embebe en su config
+type ConfigRPC struct {
+	RPC_TIMEOUT int    `json:"rpc_timeout"` // ms por llamada a otro modulo, 0 = sin plazo
+	RPC_RETRIES int    `json:"rpc_retries"` // reintentos si no se pudo conectar
+	RPC_BACKOFF int    `json:"rpc_backoff"` // ms antes del primer reintento, se duplica en cada uno
+	SPANS_FILE  string `json:"spans_file"`  // archivo donde se exportan los spans en OTLP JSON, vacio = no se exportan
+}
+
+func ConfigurarRPC(config ConfigRPC) {
+	mutexConfigRPC.Lock()
+	defer mutexConfigRPC.Unlock()
+	configRPC.timeout = time.Duration(max(config.RPC_TIMEOUT, 0)) * time.Millisecond
+	configRPC.reintentos = max(config.RPC_RETRIES, 0)
+	if config.RPC_BACKOFF > 0 {
+		configRPC.espera = time.Duration(config.RPC_BACKOFF) * time.Millisecond
+	}
+}
+
+// Un solo cliente para todo el modulo, asi las conexiones keep-alive se reusan entre llamadas
+var clienteHTTP = &http.Client{
+	Transport: &http.Transport{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 16,
+		IdleConnTimeout:     90 * time.Second,
+	},
+}
+
+// Llamar manda pedido a ruta y decodifica la respuesta en R como JSON, salvo []byte que devuelve
+// el cuerpo tal cual (paginas, respuestas en texto) y SinRespuesta que lo ignora.
+func Llamar[R any](ctx context.Context, ip string, puerto int, ruta string, pedido any) (R, error) {
+	var resultado R
+
+	mutexConfigRPC.RLock()
+	config := configRPC
+	mutexConfigRPC.RUnlock()
+
+	if _, tienePlazo := ctx.Deadline(); !tienePlazo && config.timeout > 0 {
+		var cancelar context.CancelFunc
+		ctx, cancelar = context.WithTimeout(ctx, config.timeout)
+		defer cancelar()
+	}
+
+	// span del pedido: sigue el trace del contexto o, si no hay, el del proceso del paquete
+	padre := SpanDeContexto(ctx)
+	if pid, ok := pidDelPaquete(pedido); ok && padre == nil {
+		padre = spanDeProceso(pid)
+	}
+	span := nuevoSpan(padre, ruta, spanCliente)
+	span.Atributo("server.address", fmt.Sprintf("%s:%d", ip, puerto))
+	defer span.Terminar()
+	ctx = ContextoConSpan(ctx, span)
+
+	d := destinoRegistrado(ip, puerto)
+	if d == nil {
+		d = &destino{transporte: NuevoTransporteHTTP(ip, puerto)}
+	}
+
+	tipoContenido, cuerpo, err := codificarPedido(d.transporte, pedido)
+	if err != nil {
+		return resultado, fmt.Errorf("error codificando el pedido a %s: %w", ruta, err)
+	}
+
+	espera := config.espera
+	var estado int
+	var respuesta []byte
+	for intento := 0; ; intento++ {
+		inicio := time.Now()
+		estado, respuesta, err = d.transporte.Enviar(ctx, ruta, tipoContenido, cuerpo)
+		if err == nil {
+			d.registrar(len(cuerpo), len(respuesta), time.Since(inicio))
+			break
+		}
+		// solo se reintenta si el pedido no salio: reintentar uno entregado podria ejecutarlo dos veces
+		if !sinEntregar(err) || intento >= config.reintentos {
+			err = fmt.Errorf("error enviando %s a %s:%d: %w", ruta, ip, puerto, err)
+			span.Fallo(err)
+			return resultado, err
+		}
+		slog.Debug(fmt.Sprintf("No se pudo conectar con %s:%d para %s, reintento %d en %s", ip, puerto, ruta, intento+1, espera))
+		select {
+		case <-time.After(espera):
+		case <-ctx.Done():
+			err = fmt.Errorf("error enviando %s a %s:%d: %w", ruta, ip, puerto, ctx.Err())
+			span.Fallo(err)
+			return resultado, err
+		}
+		espera *= 2
+	}
+
+	span.Atributo("http.response.status_code", estado)
+	if estado != http.StatusOK {
+		err := &ErrorRespuesta{Ruta: ruta, Estado: estado, Cuerpo: string(respuesta)}
+		span.Fallo(err)
+		return resultado, err
+	}
+
+	switch destino := any(&resultado).(type) {
+	case *SinRespuesta:
+	case *[]byte:
+		*destino = respuesta
+	default:
+		if err := json.Unmarshal(respuesta, &resultado); err != nil {
+			return resultado, fmt.Errorf("respuesta invalida de %s: %w", ruta, err)
+		}
+	}
+	return resultado, nil
+}
+
+// Enviar es Llamar cuando no interesa el cuerpo de la respuesta
+func Enviar(ctx context.Context, ip string, puerto int, ruta string, pedido any) error {
+	_, err := Llamar[SinRespuesta](ctx, ip, puerto, ruta, pedido)
+	return err
+}
+
+// Los paquetes que implementan encoding.BinaryMarshaler viajan en binario si el transporte lo soporta
+func codificarPedido(transporte Transporte, pedido any) (string, []byte, error) {
+	if marshaler, ok := pedido.(encoding.BinaryMarshaler); ok && transporte.Binario() {
+		cuerpo, err := marshaler.MarshalBinary()
+		return ContenidoBinario, cuerpo, err
+	}
+	cuerpo, err := json.Marshal(pedido)
+	return "application/json", cuerpo, err
+}
+
+// Un error al conectar garantiza que el destino no recibio nada
+func sinEntregar(err error) bool {
+	var errorRed *net.OpError
+	return errors.As(err, &errorRed) && errorRed.Op == "dial"
+}
@@ -0,0 +1,217 @@
+package globales
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Transporte de prueba: cuenta los envios, guarda el contexto recibido y responde lo que se le indique
+type transporteFalso struct {
+	mutex    sync.Mutex
+	envios   int
+	contexto context.Context
+	estado   int
+	cuerpo   []byte
+	err      error
+}
+
+func (t *transporteFalso) Nombre() string { return "falso" }
+func (t *transporteFalso) Binario() bool  { return false }
+
+func (t *transporteFalso) Enviar(ctx context.Context, ruta string, tipoContenido string, cuerpo []byte) (int, []byte, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.envios++
+	t.contexto = ctx
+	return t.estado, t.cuerpo, t.err
+}
+
+var puertoFalso = 40000
+
+// Registra el transporte en un destino propio de la prueba
+func registrarFalso(t *testing.T, transporte *transporteFalso) int {
+	t.Helper()
+	puertoFalso++
+	RegistrarTransporte("falso", puertoFalso, transporte)
+	return puertoFalso
+}
+
+// Cambia la configuracion RPC durante la prueba y la restaura al terminar
+func usarConfigRPC(t *testing.T, config ConfigRPC) {
+	t.Helper()
+	mutexConfigRPC.RLock()
+	anterior := configRPC
+	mutexConfigRPC.RUnlock()
+	t.Cleanup(func() {
+		mutexConfigRPC.Lock()
+		configRPC = anterior
+		mutexConfigRPC.Unlock()
+	})
+	ConfigurarRPC(config)
+}
+
+func servidorPrueba(t *testing.T, handler http.HandlerFunc) (string, int) {
+	t.Helper()
+	servidor := httptest.NewServer(handler)
+	t.Cleanup(servidor.Close)
+	ip, puerto, _ := net.SplitHostPort(servidor.Listener.Addr().String())
+	numero, _ := strconv.Atoi(puerto)
+	return ip, numero
+}
+
+func TestLlamarReintentaSoloSiNoSePudoConectar(t *testing.T) {
+	usarConfigRPC(t, ConfigRPC{RPC_RETRIES: 2, RPC_BACKOFF: 1})
+	errorConexion := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+	casos := []struct {
+		nombre string
+		err    error
+		envios int
+	}{
+		{"error al conectar se reintenta", errorConexion, 3},
+		{"error de lectura no se reintenta", &net.OpError{Op: "read", Net: "tcp", Err: io.ErrUnexpectedEOF}, 1},
+		{"EOF no se reintenta", io.EOF, 1},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			transporte := &transporteFalso{err: caso.err}
+			puerto := registrarFalso(t, transporte)
+			if err := Enviar(context.Background(), "falso", puerto, "/prueba", SinRespuesta{}); !errors.Is(err, caso.err) {
+				t.Errorf("error = %v, se esperaba que envuelva %v", err, caso.err)
+			}
+			if transporte.envios != caso.envios {
+				t.Errorf("%d envios, se esperaban %d", transporte.envios, caso.envios)
+			}
+		})
+	}
+
+	t.Run("un pedido que llego y corto la conexion no se repite", func(t *testing.T) {
+		var pedidos int
+		var mutex sync.Mutex
+		ip, puerto := servidorPrueba(t, func(w http.ResponseWriter, r *http.Request) {
+			mutex.Lock()
+			pedidos++
+			mutex.Unlock()
+			conexion, _, _ := w.(http.Hijacker).Hijack()
+			conexion.Close()
+		})
+		if err := Enviar(context.Background(), ip, puerto, "/prueba", SinRespuesta{}); err == nil {
+			t.Fatal("se esperaba un error")
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		if pedidos != 1 {
+			t.Errorf("el servidor recibio %d pedidos, se esperaba 1", pedidos)
+		}
+	})
+}
+
+func TestLlamarAgregaPlazo(t *testing.T) {
+	t.Run("sin plazo en el contexto usa RPC_TIMEOUT", func(t *testing.T) {
+		usarConfigRPC(t, ConfigRPC{RPC_TIMEOUT: 500})
+		transporte := &transporteFalso{estado: http.StatusOK}
+		puerto := registrarFalso(t, transporte)
+		antes := time.Now()
+		if err := Enviar(context.Background(), "falso", puerto, "/prueba", SinRespuesta{}); err != nil {
+			t.Fatal(err)
+		}
+		plazo, ok := transporte.contexto.Deadline()
+		if !ok || plazo.Before(antes.Add(500*time.Millisecond)) || plazo.After(time.Now().Add(500*time.Millisecond)) {
+			t.Errorf("plazo = %v (%t), se esperaba 500ms desde la llamada", plazo, ok)
+		}
+	})
+
+	t.Run("el plazo del contexto no se pisa", func(t *testing.T) {
+		usarConfigRPC(t, ConfigRPC{RPC_TIMEOUT: 500})
+		transporte := &transporteFalso{estado: http.StatusOK}
+		puerto := registrarFalso(t, transporte)
+		esperado := time.Now().Add(time.Hour)
+		ctx, cancelar := context.WithDeadline(context.Background(), esperado)
+		defer cancelar()
+		if err := Enviar(ctx, "falso", puerto, "/prueba", SinRespuesta{}); err != nil {
+			t.Fatal(err)
+		}
+		if plazo, _ := transporte.contexto.Deadline(); !plazo.Equal(esperado) {
+			t.Errorf("plazo = %v, se esperaba %v", plazo, esperado)
+		}
+	})
+
+	t.Run("con RPC_TIMEOUT en 0 no hay plazo", func(t *testing.T) {
+		usarConfigRPC(t, ConfigRPC{})
+		transporte := &transporteFalso{estado: http.StatusOK}
+		puerto := registrarFalso(t, transporte)
+		if err := Enviar(context.Background(), "falso", puerto, "/prueba", SinRespuesta{}); err != nil {
+			t.Fatal(err)
+		}
+		if plazo, ok := transporte.contexto.Deadline(); ok {
+			t.Errorf("plazo = %v, no se esperaba ninguno", plazo)
+		}
+	})
+}
+
+func TestLlamarErrorRespuesta(t *testing.T) {
+	ip, puerto := servidorPrueba(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("no existe el proceso"))
+	})
+
+	_, err := Llamar[[]byte](context.Background(), ip, puerto, "/kernel/finalizar_proceso", PeticionInstruccion{PID: 7})
+	var errorRespuesta *ErrorRespuesta
+	if !errors.As(err, &errorRespuesta) {
+		t.Fatalf("error = %v, se esperaba ErrorRespuesta", err)
+	}
+	if errorRespuesta.Ruta != "/kernel/finalizar_proceso" || errorRespuesta.Estado != http.StatusNotFound || errorRespuesta.Cuerpo != "no existe el proceso" {
+		t.Errorf("ErrorRespuesta = %+v", errorRespuesta)
+	}
+	if estado := EstadoDeError(err); estado != http.StatusNotFound {
+		t.Errorf("EstadoDeError = %d, se esperaba 404", estado)
+	}
+	if estado := EstadoDeError(errors.New("sin conexion")); estado != 0 {
+		t.Errorf("EstadoDeError de un error de red = %d, se esperaba 0", estado)
+	}
+}
+
+func TestLlamarDecodificaRespuesta(t *testing.T) {
+	cuerpo := ""
+	ip, puerto := servidorPrueba(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(cuerpo))
+	})
+
+	t.Run("[]byte devuelve el cuerpo tal cual", func(t *testing.T) {
+		cuerpo = "\x00\x01pagina"
+		respuesta, err := Llamar[[]byte](context.Background(), ip, puerto, "/prueba", SinRespuesta{})
+		if err != nil || string(respuesta) != cuerpo {
+			t.Errorf("respuesta = %q, error %v", respuesta, err)
+		}
+	})
+
+	t.Run("SinRespuesta ignora el cuerpo", func(t *testing.T) {
+		cuerpo = "OK, no es JSON"
+		if _, err := Llamar[SinRespuesta](context.Background(), ip, puerto, "/prueba", SinRespuesta{}); err != nil {
+			t.Errorf("error = %v", err)
+		}
+	})
+
+	t.Run("el resto se decodifica como JSON", func(t *testing.T) {
+		cuerpo = `{"CantidadEntradas":4,"TamanioPagina":64,"CantidadNiveles":2}`
+		parametros, err := Llamar[ParametrosMemoria](context.Background(), ip, puerto, "/prueba", SinRespuesta{})
+		if err != nil || parametros.TamanioPagina != 64 || parametros.CantidadEntradas != 4 || parametros.CantidadNiveles != 2 {
+			t.Errorf("parametros = %+v, error %v", parametros, err)
+		}
+	})
+
+	t.Run("JSON invalido es un error", func(t *testing.T) {
+		cuerpo = "OK"
+		if _, err := Llamar[ParametrosMemoria](context.Background(), ip, puerto, "/prueba", SinRespuesta{}); err == nil {
+			t.Error("se esperaba un error de decodificacion")
+		}
+	})
+}
@@ -0,0 +1,510 @@
+package globales
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ------ TRANSPORTES ------ //
+// Por defecto los paquetes viajan como JSON en un POST HTTP. Un modulo puede registrar otro
+// transporte para un destino (por ejemplo la CPU hacia memoria) y Llamar lo usa solo.
+
+const ContenidoBinario = "application/octet-stream" // el cuerpo viene de MarshalBinary y no de JSON
+
+type Transporte interface {
+	Nombre() string
+	Binario() bool // si los paquetes que implementan encoding.BinaryMarshaler viajan en binario
+	Enviar(ctx context.Context, ruta string, tipoContenido string, cuerpo []byte) (estado int, respuesta []byte, err error)
+}
+
+// Tiempo y volumen de los pedidos enviados por un transporte registrado
+type EstadisticasTransporte struct {
+	PEDIDOS         int
+	BYTES_ENVIADOS  int
+	BYTES_RECIBIDOS int
+	TIEMPO          time.Duration
+}
+
+type destino struct {
+	transporte   Transporte
+	mutex        sync.Mutex
+	estadisticas EstadisticasTransporte
+}
+
+var destinos = make(map[string]*destino)
+var mutexDestinos sync.RWMutex
+
+func RegistrarTransporte(ip string, puerto int, transporte Transporte) {
+	mutexDestinos.Lock()
+	destinos[fmt.Sprintf("%s:%d", ip, puerto)] = &destino{transporte: transporte}
+	mutexDestinos.Unlock()
+}
+
+func EstadisticasDeTransporte(ip string, puerto int) (string, EstadisticasTransporte, bool) {
+	mutexDestinos.RLock()
+	d, ok := destinos[fmt.Sprintf("%s:%d", ip, puerto)]
+	mutexDestinos.RUnlock()
+	if !ok {
+		return "", EstadisticasTransporte{}, false
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.transporte.Nombre(), d.estadisticas, true
+}
+
+func destinoRegistrado(ip string, puerto int) *destino {
+	mutexDestinos.RLock()
+	defer mutexDestinos.RUnlock()
+	return destinos[fmt.Sprintf("%s:%d", ip, puerto)]
+}
+
+func (d *destino) registrar(enviados int, recibidos int, duracion time.Duration) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.estadisticas.PEDIDOS++
+	d.estadisticas.BYTES_ENVIADOS += enviados
+	d.estadisticas.BYTES_RECIBIDOS += recibidos
+	d.estadisticas.TIEMPO += duracion
+}
+
+// Decodifica un cuerpo binario si el paquete lo soporta. Devuelve false si hay que usar JSON
+func decodificarBinario(r *http.Request, estructura any) (bool, error) {
+	unmarshaler, ok := estructura.(encoding.BinaryUnmarshaler)
+	if !ok || r.Header.Get("Content-Type") != ContenidoBinario {
+		return false, nil
+	}
+	cuerpo, err := io.ReadAll(r.Body)
+	if err != nil {
+		return true, err
+	}
+	return true, unmarshaler.UnmarshalBinary(cuerpo)
+}
+
+// ------ HTTP ------ //
+type TransporteHTTP struct {
+	url string
+}
+
+func NuevoTransporteHTTP(ip string, puerto int) *TransporteHTTP {
+	return &TransporteHTTP{url: fmt.Sprintf("http://%s:%d", ip, puerto)}
+}
+
+func (t *TransporteHTTP) Nombre() string { return "http" }
+func (t *TransporteHTTP) Binario() bool  { return false }
+
+func (t *TransporteHTTP) Enviar(ctx context.Context, ruta string, tipoContenido string, cuerpo []byte) (int, []byte, error) {
+	pedido, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url+ruta, bytes.NewReader(cuerpo))
+	if err != nil {
+		return 0, nil, err
+	}
+	pedido.Header.Set("Content-Type", tipoContenido)
+	if span := SpanDeContexto(ctx); span != nil {
+		pedido.Header.Set(HeaderTraceparent, span.Traceparent())
+	}
+	resp, err := clienteHTTP.Do(pedido)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	respuesta, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, respuesta, err
+}
+
+// ------ TCP ------ //
+// Conexiones persistentes con tramas de largo fijo adelante:
+//
+//	pedido:    uint32 largo | uint8 largo ruta | ruta | uint8 binario (0/1) | uint8 largo traceparent | traceparent | cuerpo
+//	respuesta: uint32 largo | uint16 estado | cuerpo
+//
+// Cada conexion lleva un pedido a la vez; los nucleos de la CPU que piden en paralelo abren las suyas.
+
+const tamanioMaximoTrama = 64 << 20
+
+// La escritura fallo o el otro extremo cerro sin mandar ni un byte de respuesta
+var errConexionCerrada = errors.New("conexion cerrada por el otro extremo")
+
+type TransporteTCP struct {
+	direccion string
+	libres    chan net.Conn
+}
+
+func NuevoTransporteTCP(ip string, puerto int) *TransporteTCP {
+	return &TransporteTCP{
+		direccion: fmt.Sprintf("%s:%d", ip, puerto),
+		libres:    make(chan net.Conn, 16),
+	}
+}
+
+func (t *TransporteTCP) Nombre() string { return "tcp" }
+func (t *TransporteTCP) Binario() bool  { return true }
+
+// El plazo del contexto se aplica a la conexion; una cancelacion sin plazo no corta un pedido en curso
+func (t *TransporteTCP) Enviar(ctx context.Context, ruta string, tipoContenido string, cuerpo []byte) (int, []byte, error) {
+	reusada := true
+	var conexion net.Conn
+	select {
+	case conexion = <-t.libres:
+	default:
+		reusada = false
+		var err error
+		if conexion, err = t.conectar(ctx); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	plazo, _ := ctx.Deadline() // sin plazo queda en cero, que saca el de un pedido anterior
+	conexion.SetDeadline(plazo)
+	traceparent := ""
+	if span := SpanDeContexto(ctx); span != nil {
+		traceparent = span.Traceparent()
+	}
+	estado, respuesta, err := intercambiarTrama(conexion, ruta, tipoContenido, traceparent, cuerpo)
+	if errors.Is(err, errConexionCerrada) && reusada && ctx.Err() == nil {
+		// el otro extremo cerro la conexion mientras estaba libre: se reintenta con una nueva.
+		// Cualquier otro error puede venir de un pedido que ya se ejecuto, y ese no se repite
+		conexion.Close()
+		if conexion, err = t.conectar(ctx); err != nil {
+			return 0, nil, err
+		}
+		conexion.SetDeadline(plazo)
+		estado, respuesta, err = intercambiarTrama(conexion, ruta, tipoContenido, traceparent, cuerpo)
+	}
+	if err != nil {
+		conexion.Close()
+		return 0, nil, err
+	}
+
+	select {
+	case t.libres <- conexion:
+	default:
+		conexion.Close() // ya hay suficientes conexiones libres
+	}
+	return estado, respuesta, nil
+}
+
+func (t *TransporteTCP) conectar(ctx context.Context) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: 5 * time.Second}
+	return dialer.DialContext(ctx, "tcp", t.direccion)
+}
+
+func intercambiarTrama(conexion net.Conn, ruta string, tipoContenido string, traceparent string, cuerpo []byte) (int, []byte, error) {
+	if len(ruta) > 255 || len(traceparent) > 255 {
+		return 0, nil, fmt.Errorf("ruta demasiado larga: %s", ruta)
+	}
+	binario := byte(0)
+	if tipoContenido == ContenidoBinario {
+		binario = 1
+	}
+
+	largo := 1 + len(ruta) + 1 + 1 + len(traceparent) + len(cuerpo)
+	trama := make([]byte, 0, 4+largo)
+	trama = binary.BigEndian.AppendUint32(trama, uint32(largo))
+	trama = append(trama, byte(len(ruta)))
+	trama = append(trama, ruta...)
+	trama = append(trama, binario)
+	trama = append(trama, byte(len(traceparent)))
+	trama = append(trama, traceparent...)
+	trama = append(trama, cuerpo...)
+	if _, err := conexion.Write(trama); err != nil {
+		return 0, nil, fmt.Errorf("%w: %w", errConexionCerrada, err)
+	}
+
+	respuesta, err := leerTrama(conexion)
+	if err == io.EOF {
+		return 0, nil, fmt.Errorf("%w: %w", errConexionCerrada, err)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(respuesta) < 2 {
+		return 0, nil, errors.New("respuesta sin estado")
+	}
+	return int(binary.BigEndian.Uint16(respuesta)), respuesta[2:], nil
+}
+
+func leerTrama(r io.Reader) ([]byte, error) {
+	var largo [4]byte
+	if _, err := io.ReadFull(r, largo[:]); err != nil {
+		return nil, err
+	}
+	tamanio := binary.BigEndian.Uint32(largo[:])
+	if tamanio > tamanioMaximoTrama {
+		return nil, fmt.Errorf("trama de %d bytes supera el maximo", tamanio)
+	}
+	trama := make([]byte, tamanio)
+	if _, err := io.ReadFull(r, trama); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF // el largo ya llego, la trama quedo cortada
+		}
+		return nil, err
+	}
+	return trama, nil
+}
+
+// ServirTCP atiende pedidos por TCP con el mismo handler que el servidor HTTP del modulo
+func ServirTCP(puerto int, handler http.Handler) error {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", puerto))
+	if err != nil {
+		return err
+	}
+	return servirListenerTCP(listener, handler)
+}
+
+func servirListenerTCP(listener net.Listener, handler http.Handler) error {
+	for {
+		conexion, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go atenderConexionTCP(conexion, handler)
+	}
+}
+
+func atenderConexionTCP(conexion net.Conn, handler http.Handler) {
+	defer conexion.Close()
+	lector := bufio.NewReader(conexion)
+	for {
+		trama, err := leerTrama(lector)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				slog.Error(fmt.Sprintf("Error leyendo trama de %s: %s", conexion.RemoteAddr(), err.Error()))
+			}
+			return
+		}
+		if len(trama) == 0 {
+			slog.Error(fmt.Sprintf("Trama invalida de %s", conexion.RemoteAddr()))
+			return
+		}
+		largoRuta := int(trama[0])
+		if len(trama) < 3+largoRuta || len(trama) < 3+largoRuta+int(trama[2+largoRuta]) {
+			slog.Error(fmt.Sprintf("Trama invalida de %s", conexion.RemoteAddr()))
+			return
+		}
+		ruta := string(trama[1 : 1+largoRuta])
+		binario := trama[1+largoRuta] == 1
+		largoTraza := int(trama[2+largoRuta])
+		traceparent := string(trama[3+largoRuta : 3+largoRuta+largoTraza])
+		cuerpo := trama[3+largoRuta+largoTraza:]
+
+		pedido, err := http.NewRequest(http.MethodPost, ruta, bytes.NewReader(cuerpo))
+		if err != nil {
+			slog.Error(fmt.Sprintf("Ruta invalida por TCP: %s", ruta))
+			return
+		}
+		pedido.RemoteAddr = conexion.RemoteAddr().String()
+		if traceparent != "" {
+			pedido.Header.Set(HeaderTraceparent, traceparent)
+		}
+		pedido.Header.Set("Content-Type", "application/json")
+		if binario {
+			pedido.Header.Set("Content-Type", ContenidoBinario)
+		}
+
+		respuesta := &respuestaTCP{encabezados: http.Header{}, estado: http.StatusOK}
+		handler.ServeHTTP(respuesta, pedido)
+
+		salida := make([]byte, 0, 4+2+respuesta.cuerpo.Len())
+		salida = binary.BigEndian.AppendUint32(salida, uint32(2+respuesta.cuerpo.Len()))
+		salida = binary.BigEndian.AppendUint16(salida, uint16(respuesta.estado))
+		salida = append(salida, respuesta.cuerpo.Bytes()...)
+		if _, err := conexion.Write(salida); err != nil {
+			slog.Error(fmt.Sprintf("Error respondiendo a %s: %s", conexion.RemoteAddr(), err.Error()))
+			return
+		}
+	}
+}
+
+// http.ResponseWriter en memoria para pasar la respuesta del handler a la trama
+type respuestaTCP struct {
+	encabezados http.Header
+	estado      int
+	escribio    bool
+	cuerpo      bytes.Buffer
+}
+
+func (r *respuestaTCP) Header() http.Header { return r.encabezados }
+
+func (r *respuestaTCP) WriteHeader(estado int) {
+	if !r.escribio {
+		r.estado = estado
+		r.escribio = true
+	}
+}
+
+func (r *respuestaTCP) Write(datos []byte) (int, error) {
+	r.escribio = true
+	return r.cuerpo.Write(datos)
+}
+
+// ------ CODIFICACION BINARIA ------ //
+// Los paquetes del camino caliente CPU-memoria implementan encoding.BinaryMarshaler:
+// enteros como varint y los []byte con su largo adelante, sin el base64 de JSON.
+
+type codificadorBinario struct {
+	datos []byte
+}
+
+func (c *codificadorBinario) entero(valor int) {
+	c.datos = binary.AppendVarint(c.datos, int64(valor))
+}
+
+func (c *codificadorBinario) bytes(valor []byte) {
+	c.entero(len(valor))
+	c.datos = append(c.datos, valor...)
+}
+
+type decodificadorBinario struct {
+	datos []byte
+	err   error
+}
+
+func (d *decodificadorBinario) entero() int {
+	if d.err != nil {
+		return 0
+	}
+	valor, n := binary.Varint(d.datos)
+	if n <= 0 {
+		d.err = errors.New("entero invalido en el paquete binario")
+		return 0
+	}
+	d.datos = d.datos[n:]
+	return int(valor)
+}
+
+func (d *decodificadorBinario) bytes() []byte {
+	largo := d.entero()
+	if d.err != nil {
+		return nil
+	}
+	if largo < 0 || largo > len(d.datos) {
+		d.err = errors.New("bytes truncados en el paquete binario")
+		return nil
+	}
+	valor := bytes.Clone(d.datos[:largo])
+	d.datos = d.datos[largo:]
+	return valor
+}
+
+func (d *decodificadorBinario) fin() error {
+	if d.err == nil && len(d.datos) > 0 {
+		return fmt.Errorf("sobran %d bytes en el paquete binario", len(d.datos))
+	}
+	return d.err
+}
+
+func (p PeticionInstruccion) MarshalBinary() ([]byte, error) {
+	c := codificadorBinario{}
+	c.entero(p.PC)
+	c.entero(p.PID)
+	return c.datos, nil
+}
+
+func (p *PeticionInstruccion) UnmarshalBinary(datos []byte) error {
+	d := decodificadorBinario{datos: datos}
+	p.PC, p.PID = d.entero(), d.entero()
+	return d.fin()
+}
+
+func (p PeticionInstrucciones) MarshalBinary() ([]byte, error) {
+	c := codificadorBinario{}
+	c.entero(p.PC)
+	c.entero(p.PID)
+	c.entero(p.CANTIDAD)
+	return c.datos, nil
+}
+
+func (p *PeticionInstrucciones) UnmarshalBinary(datos []byte) error {
+	d := decodificadorBinario{datos: datos}
+	p.PC, p.PID, p.CANTIDAD = d.entero(), d.entero(), d.entero()
+	return d.fin()
+}
+
+func (p ObtenerMarco) MarshalBinary() ([]byte, error) {
+	c := codificadorBinario{}
+	c.entero(p.PID)
+	c.entero(len(p.Entradas_Nivel_X))
+	for _, entrada := range p.Entradas_Nivel_X {
+		c.entero(entrada)
+	}
+	return c.datos, nil
+}
+
+func (p *ObtenerMarco) UnmarshalBinary(datos []byte) error {
+	d := decodificadorBinario{datos: datos}
+	p.PID = d.entero()
+	cantidad := d.entero()
+	if cantidad < 0 || cantidad > len(datos) {
+		return errors.New("cantidad de niveles invalida en el paquete binario")
+	}
+	p.Entradas_Nivel_X = make([]int, cantidad)
+	for i := range p.Entradas_Nivel_X {
+		p.Entradas_Nivel_X[i] = d.entero()
+	}
+	return d.fin()
+}
+
+func (p LeerMemoria) MarshalBinary() ([]byte, error) {
+	c := codificadorBinario{}
+	c.entero(p.DIRECCION)
+	c.entero(p.PID)
+	c.entero(p.TAMANIO)
+	return c.datos, nil
+}
+
+func (p *LeerMemoria) UnmarshalBinary(datos []byte) error {
+	d := decodificadorBinario{datos: datos}
+	p.DIRECCION, p.PID, p.TAMANIO = d.entero(), d.entero(), d.entero()
+	return d.fin()
+}
+
+func (p LeerMarcoMemoria) MarshalBinary() ([]byte, error) {
+	c := codificadorBinario{}
+	c.entero(p.DIRECCION)
+	c.entero(p.PID)
+	return c.datos, nil
+}
+
+func (p *LeerMarcoMemoria) UnmarshalBinary(datos []byte) error {
+	d := decodificadorBinario{datos: datos}
+	p.DIRECCION, p.PID = d.entero(), d.entero()
+	return d.fin()
+}
+
+func (p EscribirMemoria) MarshalBinary() ([]byte, error) {
+	c := codificadorBinario{}
+	c.entero(p.DIRECCION)
+	c.entero(p.PID)
+	c.bytes(p.DATOS)
+	return c.datos, nil
+}
+
+func (p *EscribirMemoria) UnmarshalBinary(datos []byte) error {
+	d := decodificadorBinario{datos: datos}
+	p.DIRECCION, p.PID, p.DATOS = d.entero(), d.entero(), d.bytes()
+	return d.fin()
+}
+
+func (p EscribirMarcoMemoria) MarshalBinary() ([]byte, error) {
+	c := codificadorBinario{}
+	c.entero(p.DIRECCION)
+	c.entero(p.PID)
+	c.bytes(p.DATOS)
+	return c.datos, nil
+}
+
+func (p *EscribirMarcoMemoria) UnmarshalBinary(datos []byte) error {
+	d := decodificadorBinario{datos: datos}
+	p.DIRECCION, p.PID, p.DATOS = d.entero(), d.entero(), d.bytes()
+	return d.fin()
+}
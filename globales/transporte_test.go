@@ -0,0 +1,178 @@
+package globales
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+// Escucha en un puerto libre y atiende cada conexion con atender en su propia goroutine
+func servidorTCPCrudo(t testing.TB, atender func(numero int, conexion net.Conn)) (string, int) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for numero := 0; ; numero++ {
+			conexion, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conexion.Close() })
+			go atender(numero, conexion)
+		}
+	}()
+	direccion := listener.Addr().(*net.TCPAddr)
+	return direccion.IP.String(), direccion.Port
+}
+
+func responderTrama(conexion net.Conn, estado int, cuerpo string) {
+	salida := binary.BigEndian.AppendUint32(nil, uint32(2+len(cuerpo)))
+	salida = binary.BigEndian.AppendUint16(salida, uint16(estado))
+	conexion.Write(append(salida, cuerpo...))
+}
+
+func TestTransporteTCPReintentaSiLaConexionLibreSeCerro(t *testing.T) {
+	var recibidas atomic.Int32
+	ip, puerto := servidorTCPCrudo(t, func(numero int, conexion net.Conn) {
+		for {
+			if _, err := leerTrama(conexion); err != nil {
+				return
+			}
+			recibidas.Add(1)
+			responderTrama(conexion, http.StatusOK, "ok")
+			if numero == 0 {
+				// cierra su lado sin leer nada mas: el proximo pedido por esta conexion no llega a ningun lado
+				conexion.(*net.TCPConn).CloseWrite()
+				return
+			}
+		}
+	})
+
+	transporte := NuevoTransporteTCP(ip, puerto)
+	for i := range 2 {
+		estado, respuesta, err := transporte.Enviar(context.Background(), "/prueba", "application/json", []byte("{}"))
+		if err != nil || estado != http.StatusOK || string(respuesta) != "ok" {
+			t.Fatalf("pedido %d: estado %d, respuesta %q, error %v", i, estado, respuesta, err)
+		}
+	}
+	if n := recibidas.Load(); n != 2 {
+		t.Errorf("el servidor recibio %d pedidos, se esperaban 2", n)
+	}
+}
+
+func TestTransporteTCPNoReintentaUnPedidoEntregado(t *testing.T) {
+	var recibidas atomic.Int32
+	ip, puerto := servidorTCPCrudo(t, func(numero int, conexion net.Conn) {
+		for {
+			if _, err := leerTrama(conexion); err != nil {
+				return
+			}
+			if recibidas.Add(1) == 2 {
+				// el pedido ya se ejecuto y la respuesta queda por la mitad
+				conexion.Write([]byte{0, 0, 0})
+				conexion.Close()
+				return
+			}
+			responderTrama(conexion, http.StatusOK, "ok")
+		}
+	})
+
+	transporte := NuevoTransporteTCP(ip, puerto)
+	if _, _, err := transporte.Enviar(context.Background(), "/prueba", "application/json", []byte("{}")); err != nil {
+		t.Fatalf("primer pedido: %v", err)
+	}
+	if _, _, err := transporte.Enviar(context.Background(), "/prueba", "application/json", []byte("{}")); err == nil {
+		t.Fatal("el pedido con la respuesta cortada se reintento")
+	}
+	if n := recibidas.Load(); n != 2 {
+		t.Errorf("el servidor recibio %d pedidos, se esperaban 2", n)
+	}
+}
+
+func TestCodificacionBinaria(t *testing.T) {
+	escritura := EscribirMarcoMemoria{DIRECCION: 128, PID: 3, DATOS: []byte("PRUEVA_DE_MEMORIA")}
+	datos, _ := escritura.MarshalBinary()
+	var leida EscribirMarcoMemoria
+	if err := leida.UnmarshalBinary(datos); err != nil {
+		t.Fatal(err)
+	}
+	if leida.DIRECCION != escritura.DIRECCION || leida.PID != escritura.PID || string(leida.DATOS) != string(escritura.DATOS) {
+		t.Errorf("paquete decodificado %+v, se esperaba %+v", leida, escritura)
+	}
+	if err := leida.UnmarshalBinary(append(datos, 0)); err == nil {
+		t.Error("se acepto un paquete con bytes de mas")
+	}
+	if err := leida.UnmarshalBinary(datos[:len(datos)-1]); err == nil {
+		t.Error("se acepto un paquete truncado")
+	}
+}
+
+// Compara http y tcp en los pedidos del camino caliente CPU-memoria de MEMORIA_BASE (paginas de 64 bytes)
+func BenchmarkTransporte(b *testing.B) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/cpu/buscar_instruccion", func(w http.ResponseWriter, r *http.Request) {
+		var peticion PeticionInstruccion
+		DecodificarPaquete(w, r, &peticion)
+		w.Write([]byte(`"WRITE 0 PRUEVA_DE_MEMORIA"`))
+	})
+	mux.HandleFunc("/cpu/escribir_pagina", func(w http.ResponseWriter, r *http.Request) {
+		var peticion EscribirMarcoMemoria
+		DecodificarPaquete(w, r, &peticion)
+		w.Write([]byte("OK"))
+	})
+
+	handler := Rastrear(mux) // como lo sirve memoria
+	servidorHTTP := httptest.NewServer(handler)
+	b.Cleanup(servidorHTTP.Close)
+	ipHTTP, textoPuerto, _ := net.SplitHostPort(servidorHTTP.Listener.Addr().String())
+	puertoHTTP, _ := strconv.Atoi(textoPuerto)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { listener.Close() })
+	go servirListenerTCP(listener, handler)
+	puertoTCP := listener.Addr().(*net.TCPAddr).Port
+	RegistrarTransporte("127.0.0.1", puertoTCP, NuevoTransporteTCP("127.0.0.1", puertoTCP))
+
+	pagina := make([]byte, 64)
+	copy(pagina, "PRUEVA_DE_MEMORIA")
+	destinos := []struct {
+		nombre string
+		ip     string
+		puerto int
+	}{
+		{"http", ipHTTP, puertoHTTP},
+		{"tcp", "127.0.0.1", puertoTCP},
+	}
+	for _, d := range destinos {
+		b.Run(fmt.Sprintf("%s/buscar_instruccion", d.nombre), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := range b.N {
+				peticion := PeticionInstruccion{PC: i % 26, PID: 1}
+				if _, err := Llamar[string](context.Background(), d.ip, d.puerto, "/cpu/buscar_instruccion", peticion); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("%s/escribir_pagina", d.nombre), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := range b.N {
+				peticion := EscribirMarcoMemoria{DIRECCION: (i % 4) * 64, PID: 1, DATOS: pagina}
+				if err := Enviar(context.Background(), d.ip, d.puerto, "/cpu/escribir_pagina", peticion); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
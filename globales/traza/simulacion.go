@@ -0,0 +1,317 @@
+package traza
+
+// Configuracion de TLB y cache a simular. Los campos se llaman igual que en el config de la CPU,
+// asi se puede decodificar directamente un cpuX_Y.json
+type Configuracion struct {
+	TLB_ENTRIES          int    `json:"tlb_entries"`
+	TLB_REPLACEMENT      string `json:"tlb_replacement"`   // FIFO o LRU
+	CACHE_ENTRIES        int    `json:"cache_entries"`     // 0 = sin cache de paginas
+	CACHE_REPLACEMENT    string `json:"cache_replacement"` // CLOCK, CLOCK-M, FIFO, LRU o LFU
+	CACHE_WRITE_POLICY   string `json:"cache_write_policy"`
+	CACHE_WRITE_ALLOCATE bool   `json:"cache_write_allocate"`
+	ASIDS                bool   `json:"asids"`
+}
+
+// ConfiguracionPorDefecto tiene los mismos valores por defecto que la CPU para los campos opcionales
+func ConfiguracionPorDefecto() Configuracion {
+	return Configuracion{
+		CACHE_WRITE_POLICY:   "write-back",
+		CACHE_WRITE_ALLOCATE: true,
+	}
+}
+
+// Mismos contadores que las estadisticas de un nucleo, mas los pedidos de marco a memoria
+type Estadisticas struct {
+	ACCESOS                int
+	TLB_HITS               int
+	TLB_MISSES             int
+	CACHE_HITS             int
+	CACHE_MISSES           int
+	PEDIDOS_MARCO          int // traducciones que fueron hasta la tabla de paginas de memoria
+	DESALOJOS_SUCIOS       int
+	ESCRITURAS_PAGINA      int
+	ESCRITURAS_DIRECCION   int
+	BYTES_ESCRITOS_MEMORIA int
+}
+
+// EstadisticasGrabadas cuenta los aciertos que tuvo la CPU cuando grabo la traza
+func EstadisticasGrabadas(eventos []Evento) Estadisticas {
+	var estadisticas Estadisticas
+	for _, evento := range eventos {
+		if !evento.EsAcceso() {
+			continue
+		}
+		estadisticas.ACCESOS++
+		switch evento.TLB {
+		case Hit:
+			estadisticas.TLB_HITS++
+		case Miss:
+			estadisticas.TLB_MISSES++
+		}
+		switch evento.Cache {
+		case Hit:
+			estadisticas.CACHE_HITS++
+		case Miss:
+			estadisticas.CACHE_MISSES++
+		}
+	}
+	return estadisticas
+}
+
+type entradaTLB struct {
+	asid   int
+	pagina int
+	uso    int // reloj de la ultima referencia (LRU)
+}
+
+type entradaCache struct {
+	asid       int
+	pagina     int
+	ocupada    bool
+	uso        bool
+	modificado bool
+	carga      int // FIFO
+	ultimoUso  int // LRU
+	usos       int // LFU
+}
+
+type simulador struct {
+	config        Configuracion
+	tamanioPagina int
+	reloj         int // reemplaza a time.Now(): la simulacion tiene que dar lo mismo en cada corrida
+	tlb           []entradaTLB
+	cache         []entradaCache
+	puntero       int
+	estadisticas  Estadisticas
+}
+
+// Simular vuelve a correr los accesos de la traza contra la configuracion, sin HTTP ni memoria real.
+// Reproduce lo que hace un nucleo con cache propia: las rafagas, el vaciado sin ASIDs y las invalidaciones de memoria.
+func Simular(tamanioPagina int, eventos []Evento, config Configuracion) Estadisticas {
+	s := &simulador{
+		config:        config,
+		tamanioPagina: tamanioPagina,
+		cache:         make([]entradaCache, max(config.CACHE_ENTRIES, 0)),
+	}
+
+	for _, evento := range eventos {
+		s.reloj++
+		switch evento.Tipo {
+		case FinRafaga:
+			if !config.ASIDS {
+				s.tlb = s.tlb[:0]
+				s.invalidarCache(evento.PID, true, true)
+				s.puntero = 0
+			} else { // con ASIDs las entradas quedan, pero las modificadas se bajan igual
+				s.invalidarCache(evento.PID, true, false)
+			}
+		case Invalidacion:
+			s.invalidarTLB(evento.PID)
+			switch evento.Detalle {
+			case "FINALIZAR":
+				s.invalidarCache(evento.PID, false, true)
+			case "DUMP":
+				s.invalidarCache(evento.PID, true, false)
+			default: // SWAP y MPROTECT
+				s.invalidarCache(evento.PID, true, true)
+			}
+		case Lectura:
+			s.estadisticas.ACCESOS++
+			s.leer(evento)
+		case Escritura:
+			s.estadisticas.ACCESOS++
+			s.escribir(evento)
+		}
+	}
+	return s.estadisticas
+}
+
+func (s *simulador) cacheHabilitada() bool {
+	return len(s.cache) > 0
+}
+
+func (s *simulador) leer(evento Evento) {
+	pagina := evento.Direccion / s.tamanioPagina
+	if s.cacheHabilitada() {
+		s.accederCache(evento.PID, pagina)
+		return
+	}
+	s.traducir(evento.PID, pagina)
+}
+
+func (s *simulador) escribir(evento Evento) {
+	pagina := evento.Direccion / s.tamanioPagina
+	if !s.cacheHabilitada() || (!s.config.CACHE_WRITE_ALLOCATE && s.buscarEnCache(evento.PID, pagina) < 0) {
+		if s.cacheHabilitada() {
+			s.estadisticas.CACHE_MISSES++
+		}
+		s.traducir(evento.PID, pagina)
+		s.estadisticas.ESCRITURAS_DIRECCION++
+		s.estadisticas.BYTES_ESCRITOS_MEMORIA += evento.Tamanio
+		return
+	}
+
+	i := s.accederCache(evento.PID, pagina)
+	s.cache[i].modificado = true
+	if s.config.CACHE_WRITE_POLICY == "write-through" {
+		s.escribirPagina(i)
+	}
+}
+
+func (s *simulador) traducir(pid int, pagina int) {
+	if s.config.TLB_ENTRIES <= 0 {
+		s.estadisticas.PEDIDOS_MARCO++
+		return
+	}
+
+	for i := range s.tlb {
+		if s.tlb[i].asid == pid && s.tlb[i].pagina == pagina {
+			s.estadisticas.TLB_HITS++
+			s.tlb[i].uso = s.reloj
+			return
+		}
+	}
+	s.estadisticas.TLB_MISSES++
+	s.estadisticas.PEDIDOS_MARCO++
+
+	nueva := entradaTLB{asid: pid, pagina: pagina, uso: s.reloj}
+	if len(s.tlb) < s.config.TLB_ENTRIES {
+		s.tlb = append(s.tlb, nueva)
+		return
+	}
+	if s.config.TLB_REPLACEMENT == "FIFO" {
+		s.tlb = append(s.tlb[1:], nueva)
+		return
+	}
+	victima := 0
+	for i := range s.tlb {
+		if s.tlb[i].uso < s.tlb[victima].uso {
+			victima = i
+		}
+	}
+	s.tlb[victima] = nueva
+}
+
+func (s *simulador) invalidarTLB(pid int) {
+	filtrada := s.tlb[:0]
+	for _, entrada := range s.tlb {
+		if entrada.asid != pid {
+			filtrada = append(filtrada, entrada)
+		}
+	}
+	s.tlb = filtrada
+}
+
+func (s *simulador) buscarEnCache(pid int, pagina int) int {
+	for i := range s.cache {
+		if s.cache[i].ocupada && s.cache[i].asid == pid && s.cache[i].pagina == pagina {
+			return i
+		}
+	}
+	return -1
+}
+
+// Devuelve la entrada de la pagina, cargandola si hace falta
+func (s *simulador) accederCache(pid int, pagina int) int {
+	if i := s.buscarEnCache(pid, pagina); i >= 0 {
+		s.estadisticas.CACHE_HITS++
+		s.cache[i].uso = true
+		s.cache[i].ultimoUso = s.reloj
+		s.cache[i].usos++
+		return i
+	}
+	s.estadisticas.CACHE_MISSES++
+	s.traducir(pid, pagina)
+
+	i := -1
+	for j := range s.cache {
+		if !s.cache[j].ocupada {
+			i = j
+			break
+		}
+	}
+	if i < 0 {
+		i = s.elegirVictima()
+		if s.cache[i].modificado {
+			s.estadisticas.DESALOJOS_SUCIOS++
+			s.escribirPagina(i)
+		}
+	}
+
+	s.cache[i] = entradaCache{asid: pid, pagina: pagina, ocupada: true, uso: true, carga: s.reloj, ultimoUso: s.reloj, usos: 1}
+	s.puntero = i + 1
+	return i
+}
+
+// La cache esta llena. CLOCK y CLOCK-M recorren desde el puntero igual que la CPU
+func (s *simulador) elegirVictima() int {
+	cantidad := len(s.cache)
+	switch s.config.CACHE_REPLACEMENT {
+	case "CLOCK":
+		for i := s.puntero; ; i++ {
+			entrada := &s.cache[i%cantidad]
+			if !entrada.uso {
+				return i % cantidad
+			}
+			entrada.uso = false
+		}
+	case "CLOCK-M":
+		for {
+			for i := s.puntero; i < s.puntero+cantidad; i++ { // (0,0) sin tocar los bits de uso
+				if !s.cache[i%cantidad].uso && !s.cache[i%cantidad].modificado {
+					return i % cantidad
+				}
+			}
+			for i := s.puntero; i < s.puntero+cantidad; i++ { // (0,1) apagando los bits de uso
+				entrada := &s.cache[i%cantidad]
+				if !entrada.uso && entrada.modificado {
+					return i % cantidad
+				}
+				entrada.uso = false
+			}
+		}
+	}
+
+	victima := 0
+	for i, entrada := range s.cache {
+		candidata := s.cache[victima]
+		switch s.config.CACHE_REPLACEMENT {
+		case "FIFO":
+			if entrada.carga < candidata.carga {
+				victima = i
+			}
+		case "LRU":
+			if entrada.ultimoUso < candidata.ultimoUso {
+				victima = i
+			}
+		case "LFU":
+			if entrada.usos < candidata.usos || (entrada.usos == candidata.usos && entrada.ultimoUso < candidata.ultimoUso) {
+				victima = i
+			}
+		}
+	}
+	return victima
+}
+
+func (s *simulador) escribirPagina(i int) {
+	if !s.cache[i].modificado {
+		return
+	}
+	s.estadisticas.ESCRITURAS_PAGINA++
+	s.estadisticas.BYTES_ESCRITOS_MEMORIA += s.tamanioPagina
+	s.cache[i].modificado = false
+}
+
+func (s *simulador) invalidarCache(pid int, escribirModificadas bool, invalidar bool) {
+	for i := range s.cache {
+		if !s.cache[i].ocupada || s.cache[i].asid != pid {
+			continue
+		}
+		if escribirModificadas {
+			s.escribirPagina(i)
+		}
+		if invalidar {
+			s.cache[i] = entradaCache{}
+		}
+	}
+}
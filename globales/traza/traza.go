@@ -0,0 +1,206 @@
+// Package traza graba lo que ejecuta cada nucleo de CPU (instrucciones, accesos a memoria,
+// syscalls e interrupciones) y permite volver a correr los accesos contra otra configuracion
+// de TLB y cache sin levantar los modulos.
+//
+// El archivo tiene un evento por linea, con los campos separados por espacios:
+//
+//	P <tamanio_pagina>
+//	B <pid> <pc>                                               inicio de rafaga
+//	F <pid> <pc> <instruccion>                                 fetch
+//	R|W <pid> <pc> <direccion> <tamanio> <marco> <tlb> <cache>  acceso dentro de una pagina
+//	S <pid> <pc> <syscall>
+//	I <pid> <pc> <tipo>                                        interrupcion atendida
+//	V <pid> <pc> <motivo>                                      invalidacion de memoria (FINALIZAR, SWAP, ...)
+//	E <pid> <pc>                                               fin de rafaga
+//
+// tlb y cache valen H (hit), M (miss) o - (deshabilitada o no se consulto).
+package traza
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type TipoEvento byte
+
+const (
+	TamanioPagina TipoEvento = 'P'
+	InicioRafaga  TipoEvento = 'B'
+	Fetch         TipoEvento = 'F'
+	Lectura       TipoEvento = 'R'
+	Escritura     TipoEvento = 'W'
+	Syscall       TipoEvento = 'S'
+	Interrupcion  TipoEvento = 'I'
+	Invalidacion  TipoEvento = 'V'
+	FinRafaga     TipoEvento = 'E'
+)
+
+type Resultado byte
+
+const (
+	Hit      Resultado = 'H'
+	Miss     Resultado = 'M'
+	NoAplica Resultado = '-'
+)
+
+type Evento struct {
+	Tipo      TipoEvento
+	PID       int
+	PC        int
+	Direccion int // accesos: direccion logica
+	Tamanio   int // accesos: bytes dentro de la pagina; P: tamaño de pagina
+	Marco     int // accesos: -1 si no se llego a traducir
+	TLB       Resultado
+	Cache     Resultado
+	Detalle   string // instruccion, syscall, tipo de interrupcion o motivo de invalidacion
+}
+
+func (e Evento) EsAcceso() bool {
+	return e.Tipo == Lectura || e.Tipo == Escritura
+}
+
+func (e Evento) String() string {
+	switch e.Tipo {
+	case TamanioPagina:
+		return fmt.Sprintf("%c %d", e.Tipo, e.Tamanio)
+	case Lectura, Escritura:
+		return fmt.Sprintf("%c %d %d %d %d %d %c %c", e.Tipo, e.PID, e.PC, e.Direccion, e.Tamanio, e.Marco, e.TLB, e.Cache)
+	case InicioRafaga, FinRafaga:
+		return fmt.Sprintf("%c %d %d", e.Tipo, e.PID, e.PC)
+	default:
+		return fmt.Sprintf("%c %d %d %s", e.Tipo, e.PID, e.PC, e.Detalle)
+	}
+}
+
+// Escritor de un archivo de traza, seguro para usar desde varias goroutines
+type Escritor struct {
+	mutex   sync.Mutex
+	archivo *os.File
+	buffer  *bufio.Writer
+}
+
+// Crear trunca el archivo y escribe el tamaño de pagina como primer evento
+func Crear(ruta string, tamanioPagina int) (*Escritor, error) {
+	archivo, err := os.Create(ruta)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo crear la traza '%s': %w", ruta, err)
+	}
+	escritor := &Escritor{archivo: archivo, buffer: bufio.NewWriter(archivo)}
+	escritor.Registrar(Evento{Tipo: TamanioPagina, Tamanio: tamanioPagina})
+	return escritor, nil
+}
+
+// Registrar agrega un evento. El buffer se baja al archivo al final de cada rafaga
+func (e *Escritor) Registrar(evento Evento) {
+	if e == nil {
+		return
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	fmt.Fprintln(e.buffer, evento.String())
+	if evento.Tipo == FinRafaga {
+		e.buffer.Flush()
+	}
+}
+
+func (e *Escritor) Cerrar() error {
+	if e == nil {
+		return nil
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if err := e.buffer.Flush(); err != nil {
+		e.archivo.Close()
+		return err
+	}
+	return e.archivo.Close()
+}
+
+// LeerArchivo devuelve el tamaño de pagina y los eventos de una traza
+func LeerArchivo(ruta string) (int, []Evento, error) {
+	archivo, err := os.Open(ruta)
+	if err != nil {
+		return 0, nil, fmt.Errorf("no se pudo abrir la traza '%s': %w", ruta, err)
+	}
+	defer archivo.Close()
+	return Leer(archivo)
+}
+
+func Leer(r io.Reader) (int, []Evento, error) {
+	tamanioPagina := 0
+	var eventos []Evento
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	numeroLinea := 0
+	for scanner.Scan() {
+		numeroLinea++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		evento, err := parsearEvento(scanner.Text())
+		if err != nil {
+			return 0, nil, fmt.Errorf("linea %d: %w", numeroLinea, err)
+		}
+		if evento.Tipo == TamanioPagina {
+			tamanioPagina = evento.Tamanio
+			continue
+		}
+		eventos = append(eventos, evento)
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, nil, err
+	}
+	if tamanioPagina <= 0 {
+		return 0, nil, fmt.Errorf("la traza no tiene el tamaño de pagina")
+	}
+	return tamanioPagina, eventos, nil
+}
+
+func parsearEvento(linea string) (Evento, error) {
+	campos := strings.SplitN(linea, " ", 4)
+	if len(campos[0]) != 1 {
+		return Evento{}, fmt.Errorf("tipo de evento invalido '%s'", campos[0])
+	}
+	evento := Evento{Tipo: TipoEvento(campos[0][0]), Marco: -1, TLB: NoAplica, Cache: NoAplica}
+
+	enteros := func(cantidad int, destinos ...*int) error {
+		if len(campos)-1 < cantidad {
+			return fmt.Errorf("evento %c incompleto", evento.Tipo)
+		}
+		for i, destino := range destinos {
+			valor, err := strconv.Atoi(campos[i+1])
+			if err != nil {
+				return fmt.Errorf("'%s' no es un entero", campos[i+1])
+			}
+			*destino = valor
+		}
+		return nil
+	}
+
+	switch evento.Tipo {
+	case TamanioPagina:
+		return evento, enteros(1, &evento.Tamanio)
+	case InicioRafaga, FinRafaga:
+		return evento, enteros(2, &evento.PID, &evento.PC)
+	case Fetch, Syscall, Interrupcion, Invalidacion:
+		if len(campos) < 4 {
+			return evento, fmt.Errorf("evento %c incompleto", evento.Tipo)
+		}
+		evento.Detalle = campos[3]
+		return evento, enteros(2, &evento.PID, &evento.PC)
+	case Lectura, Escritura:
+		campos = strings.Fields(linea)
+		if len(campos) != 8 || len(campos[6]) != 1 || len(campos[7]) != 1 {
+			return evento, fmt.Errorf("acceso invalido '%s'", linea)
+		}
+		evento.TLB, evento.Cache = Resultado(campos[6][0]), Resultado(campos[7][0])
+		return evento, enteros(5, &evento.PID, &evento.PC, &evento.Direccion, &evento.Tamanio, &evento.Marco)
+	}
+	return evento, fmt.Errorf("tipo de evento desconocido '%c'", evento.Tipo)
+}
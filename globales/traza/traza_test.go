@@ -0,0 +1,210 @@
+package traza
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLeerIdaYVuelta(t *testing.T) {
+	eventos := []Evento{
+		{Tipo: InicioRafaga, PID: 3, PC: 0, Marco: -1, TLB: NoAplica, Cache: NoAplica},
+		{Tipo: Fetch, PID: 3, PC: 0, Marco: -1, TLB: NoAplica, Cache: NoAplica, Detalle: "WRITE 0 hola mundo"},
+		{Tipo: Escritura, PID: 3, PC: 0, Direccion: 0, Tamanio: 10, Marco: 7, TLB: Miss, Cache: Miss},
+		{Tipo: Lectura, PID: 3, PC: 1, Direccion: 35, Tamanio: 4, Marco: -1, TLB: Hit, Cache: NoAplica},
+		{Tipo: Syscall, PID: 3, PC: 2, Marco: -1, TLB: NoAplica, Cache: NoAplica, Detalle: "IO"},
+		{Tipo: Interrupcion, PID: 3, PC: 2, Marco: -1, TLB: NoAplica, Cache: NoAplica, Detalle: "QUANTUM"},
+		{Tipo: Invalidacion, PID: 3, PC: 2, Marco: -1, TLB: NoAplica, Cache: NoAplica, Detalle: "SWAP"},
+		{Tipo: FinRafaga, PID: 3, PC: 3, Marco: -1, TLB: NoAplica, Cache: NoAplica},
+	}
+
+	var texto strings.Builder
+	texto.WriteString(Evento{Tipo: TamanioPagina, Tamanio: 32}.String() + "\n")
+	for _, evento := range eventos {
+		texto.WriteString(evento.String() + "\n")
+	}
+	texto.WriteString("\n") // las lineas vacias se ignoran
+
+	tamanioPagina, leidos, err := Leer(strings.NewReader(texto.String()))
+	if err != nil {
+		t.Fatalf("error al leer la traza:\n%s\n%v", texto.String(), err)
+	}
+	if tamanioPagina != 32 {
+		t.Errorf("tamaño de pagina = %d, se esperaba 32", tamanioPagina)
+	}
+	if !reflect.DeepEqual(leidos, eventos) {
+		t.Errorf("eventos leidos distintos a los escritos:\n%+v\n%+v", leidos, eventos)
+	}
+}
+
+func TestLeerErrores(t *testing.T) {
+	casos := []struct {
+		nombre string
+		traza  string
+		error  string
+	}{
+		{"sin tamaño de pagina", "B 1 0\n", "no tiene el tamaño de pagina"},
+		{"tipo desconocido", "P 16\nX 1 0\n", "linea 2: tipo de evento desconocido 'X'"},
+		{"tipo de mas de un caracter", "P 16\nBB 1 0\n", "linea 2: tipo de evento invalido 'BB'"},
+		{"evento incompleto", "P 16\nB 1\n", "linea 2: evento B incompleto"},
+		{"fetch sin instruccion", "P 16\nF 1 0\n", "linea 2: evento F incompleto"},
+		{"entero invalido", "P 16\nE 1 x\n", "linea 2: 'x' no es un entero"},
+		{"acceso con campos de menos", "P 16\nR 1 0 0 4 -1 H\n", "linea 2: acceso invalido"},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			_, _, err := Leer(strings.NewReader(caso.traza))
+			if err == nil || !strings.Contains(err.Error(), caso.error) {
+				t.Errorf("error = %v, se esperaba que contenga %q", err, caso.error)
+			}
+		})
+	}
+}
+
+// Las trazas de referencia usan paginas de 16 bytes y un solo proceso (PID 1)
+func simularTexto(t *testing.T, texto string, config Configuracion) Estadisticas {
+	t.Helper()
+	tamanioPagina, eventos, err := Leer(strings.NewReader("P 16\n" + texto))
+	if err != nil {
+		t.Fatalf("traza de referencia invalida: %v", err)
+	}
+	return Simular(tamanioPagina, eventos, config)
+}
+
+func TestSimular(t *testing.T) {
+	// paginas 0, 1, 0, 2, 0
+	accesosTLB := "R 1 0 0 4 0 - -\nR 1 1 16 4 0 - -\nR 1 2 0 4 0 - -\nR 1 3 32 4 0 - -\nR 1 4 0 4 0 - -\n"
+
+	casos := []struct {
+		nombre   string
+		traza    string
+		config   Configuracion
+		esperado Estadisticas
+	}{
+		{
+			// [0] [0 1] hit [1 2] [2 0]
+			nombre:   "TLB FIFO",
+			traza:    accesosTLB,
+			config:   Configuracion{TLB_ENTRIES: 2, TLB_REPLACEMENT: "FIFO"},
+			esperado: Estadisticas{ACCESOS: 5, TLB_HITS: 1, TLB_MISSES: 4, PEDIDOS_MARCO: 4},
+		},
+		{
+			// [0] [0 1] hit (0 es la mas reciente) [0 2] hit
+			nombre:   "TLB LRU",
+			traza:    accesosTLB,
+			config:   Configuracion{TLB_ENTRIES: 2, TLB_REPLACEMENT: "LRU"},
+			esperado: Estadisticas{ACCESOS: 5, TLB_HITS: 2, TLB_MISSES: 3, PEDIDOS_MARCO: 3},
+		},
+		{
+			nombre:   "sin TLB ni cache cada acceso pide el marco",
+			traza:    accesosTLB,
+			config:   ConfiguracionPorDefecto(),
+			esperado: Estadisticas{ACCESOS: 5, PEDIDOS_MARCO: 5},
+		},
+		{
+			// W p0 y R p1 llenan la cache; R p2 da la vuelta apagando los bits de uso y desaloja p0 modificada.
+			// El fin de rafaga sin ASIDs vacia la cache, asi que R p0 vuelve a fallar
+			nombre: "cache CLOCK write-back",
+			traza:  "W 1 0 0 4 0 - -\nR 1 1 16 4 0 - -\nR 1 2 32 4 0 - -\nE 1 3\nR 1 3 0 4 0 - -\n",
+			config: Configuracion{CACHE_ENTRIES: 2, CACHE_REPLACEMENT: "CLOCK", CACHE_WRITE_POLICY: "write-back", CACHE_WRITE_ALLOCATE: true},
+			esperado: Estadisticas{ACCESOS: 4, CACHE_MISSES: 4, PEDIDOS_MARCO: 4,
+				DESALOJOS_SUCIOS: 1, ESCRITURAS_PAGINA: 1, BYTES_ESCRITOS_MEMORIA: 16},
+		},
+		{
+			// CLOCK-M prefiere la pagina sin usar y sin modificar: desaloja p1 y no p0
+			nombre:   "cache CLOCK-M",
+			traza:    "W 1 0 0 4 0 - -\nR 1 1 16 4 0 - -\nR 1 2 32 4 0 - -\nR 1 3 0 4 0 - -\n",
+			config:   Configuracion{CACHE_ENTRIES: 2, CACHE_REPLACEMENT: "CLOCK-M", CACHE_WRITE_POLICY: "write-back", CACHE_WRITE_ALLOCATE: true},
+			esperado: Estadisticas{ACCESOS: 4, CACHE_HITS: 1, CACHE_MISSES: 3, PEDIDOS_MARCO: 3},
+		},
+		{
+			// con ASIDs la pagina modificada se baja al fin de la rafaga pero sigue en la cache
+			nombre: "fin de rafaga con ASIDs",
+			traza:  "W 1 0 0 4 0 - -\nE 1 1\nR 1 1 0 4 0 - -\n",
+			config: Configuracion{CACHE_ENTRIES: 2, CACHE_REPLACEMENT: "CLOCK", CACHE_WRITE_POLICY: "write-back", CACHE_WRITE_ALLOCATE: true, ASIDS: true},
+			esperado: Estadisticas{ACCESOS: 2, CACHE_HITS: 1, CACHE_MISSES: 1, PEDIDOS_MARCO: 1,
+				ESCRITURAS_PAGINA: 1, BYTES_ESCRITOS_MEMORIA: 16},
+		},
+		{
+			nombre: "fin de rafaga sin ASIDs",
+			traza:  "W 1 0 0 4 0 - -\nE 1 1\nR 1 1 0 4 0 - -\n",
+			config: Configuracion{CACHE_ENTRIES: 2, CACHE_REPLACEMENT: "CLOCK", CACHE_WRITE_POLICY: "write-back", CACHE_WRITE_ALLOCATE: true},
+			esperado: Estadisticas{ACCESOS: 2, CACHE_MISSES: 2, PEDIDOS_MARCO: 2,
+				ESCRITURAS_PAGINA: 1, BYTES_ESCRITOS_MEMORIA: 16},
+		},
+		{
+			nombre: "write-through baja la pagina en cada escritura",
+			traza:  "W 1 0 0 4 0 - -\nW 1 1 4 4 0 - -\n",
+			config: Configuracion{CACHE_ENTRIES: 1, CACHE_REPLACEMENT: "CLOCK", CACHE_WRITE_POLICY: "write-through", CACHE_WRITE_ALLOCATE: true},
+			esperado: Estadisticas{ACCESOS: 2, CACHE_HITS: 1, CACHE_MISSES: 1, PEDIDOS_MARCO: 1,
+				ESCRITURAS_PAGINA: 2, BYTES_ESCRITOS_MEMORIA: 32},
+		},
+		{
+			// sin write-allocate la escritura va directo a memoria y la lectura siguiente falla
+			nombre: "write no-allocate",
+			traza:  "W 1 0 0 4 0 - -\nR 1 1 0 4 0 - -\n",
+			config: Configuracion{CACHE_ENTRIES: 1, CACHE_REPLACEMENT: "CLOCK", CACHE_WRITE_POLICY: "write-back"},
+			esperado: Estadisticas{ACCESOS: 2, CACHE_MISSES: 2, PEDIDOS_MARCO: 2,
+				ESCRITURAS_DIRECCION: 1, BYTES_ESCRITOS_MEMORIA: 4},
+		},
+		{
+			// al finalizar el proceso sus paginas se descartan sin bajarlas
+			nombre:   "invalidacion FINALIZAR",
+			traza:    "W 1 0 0 4 0 - -\nV 1 1 FINALIZAR\nR 1 1 0 4 0 - -\n",
+			config:   Configuracion{CACHE_ENTRIES: 2, CACHE_REPLACEMENT: "CLOCK", CACHE_WRITE_POLICY: "write-back", CACHE_WRITE_ALLOCATE: true, ASIDS: true},
+			esperado: Estadisticas{ACCESOS: 2, CACHE_MISSES: 2, PEDIDOS_MARCO: 2},
+		},
+		{
+			// el dump baja las modificadas pero las deja en la cache
+			nombre: "invalidacion DUMP",
+			traza:  "W 1 0 0 4 0 - -\nV 1 1 DUMP\nR 1 1 0 4 0 - -\n",
+			config: Configuracion{CACHE_ENTRIES: 2, CACHE_REPLACEMENT: "CLOCK", CACHE_WRITE_POLICY: "write-back", CACHE_WRITE_ALLOCATE: true, ASIDS: true},
+			esperado: Estadisticas{ACCESOS: 2, CACHE_HITS: 1, CACHE_MISSES: 1, PEDIDOS_MARCO: 1,
+				ESCRITURAS_PAGINA: 1, BYTES_ESCRITOS_MEMORIA: 16},
+		},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			obtenido := simularTexto(t, caso.traza, caso.config)
+			if obtenido != caso.esperado {
+				t.Errorf("estadisticas = %+v\nse esperaba     %+v", obtenido, caso.esperado)
+			}
+		})
+	}
+}
+
+func TestSimularEsDeterminista(t *testing.T) {
+	var texto strings.Builder
+	for i := 0; i < 200; i++ {
+		tipo := "R"
+		if i%3 == 0 {
+			tipo = "W"
+		}
+		texto.WriteString(tipo + " 1 " + strconv.Itoa(i) + " " + strconv.Itoa((i*37)%160) + " 4 0 - -\n")
+		if i%50 == 49 {
+			texto.WriteString("E 1 " + strconv.Itoa(i) + "\n")
+		}
+	}
+	for _, reemplazo := range []string{"CLOCK", "CLOCK-M", "FIFO", "LRU", "LFU"} {
+		config := Configuracion{TLB_ENTRIES: 4, TLB_REPLACEMENT: "LRU", CACHE_ENTRIES: 3, CACHE_REPLACEMENT: reemplazo, CACHE_WRITE_POLICY: "write-back", CACHE_WRITE_ALLOCATE: true}
+		primera := simularTexto(t, texto.String(), config)
+		for i := 0; i < 5; i++ {
+			if otra := simularTexto(t, texto.String(), config); otra != primera {
+				t.Fatalf("%s: la simulacion cambio entre corridas:\n%+v\n%+v", reemplazo, primera, otra)
+			}
+		}
+	}
+}
+
+func TestEstadisticasGrabadas(t *testing.T) {
+	_, eventos, err := Leer(strings.NewReader("P 16\nB 1 0\nR 1 0 0 4 2 H M\nW 1 1 4 4 2 M H\nR 1 2 8 4 2 - -\nE 1 3\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	esperado := Estadisticas{ACCESOS: 3, TLB_HITS: 1, TLB_MISSES: 1, CACHE_HITS: 1, CACHE_MISSES: 1}
+	if obtenido := EstadisticasGrabadas(eventos); obtenido != esperado {
+		t.Errorf("estadisticas = %+v, se esperaba %+v", obtenido, esperado)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"globales"
 	"log/slog"
@@ -44,11 +45,10 @@ func main() {
 	utils.ClientConfig = utils.IniciarConfiguracion(rutaConfig)
 
 	// ------ LOGGING ------ //
-	globales.ConfigurarLogger(fmt.Sprintf("io_%s.log", utils.NombreDispositivo), utils.ClientConfig.LOG_LEVEL)
-
-	if utils.ClientConfig == nil {
-		slog.Error("No se pudo crear el config")
-		os.Exit(1)
+	globales.ConfigurarLogger(fmt.Sprintf("io_%s.log", utils.NombreDispositivo), utils.ClientConfig.LOG_LEVEL, utils.ClientConfig.ConfigLog)
+	globales.ConfigurarRPC(utils.ClientConfig.ConfigRPC)
+	if err := globales.IniciarSpans("io_"+utils.NombreDispositivo, utils.ClientConfig.SPANS_FILE); err != nil {
+		slog.Error(err.Error())
 	}
 
 	// ------ INICIALIZACION DE VARIABLES ------ //
@@ -63,6 +63,8 @@ func main() {
 	// ------ INICIALIZACION DEL SERVIDOR ------ //
 	mux := http.NewServeMux()
 	mux.HandleFunc("/io/peticion", utils.AtenderPeticionIO)
+	mux.HandleFunc("/io/cancelar", utils.CancelarPeticionIO)
+	mux.HandleFunc("/io/heartbeat", utils.AtenderHeartbeat)
 
 	go escucharPeticiones(puerto_io, mux)
 
@@ -83,14 +85,17 @@ func main() {
 	}
 
 	// contesto al kernel
-	globales.GenerarYEnviarPaquete(&respuesta, ip_kernel, puerto_kernel, "/io/finalizado")
+	if err := globales.Enviar(context.Background(), ip_kernel, puerto_kernel, "/io/finalizado", &respuesta); err != nil {
+		slog.Error(fmt.Sprintf("No se pudo avisar la desconexion al kernel: %v", err))
+	}
 
 	slog.Info(fmt.Sprintf("Cerrando dispositivo IO '%s'...", utils.NombreDispositivo))
+	globales.CerrarSpans()
 }
 
 func escucharPeticiones(puerto_io string, mux *http.ServeMux) {
 	slog.Info(fmt.Sprintf("Iniciando dispositivo IO '%s' en el puerto %s", utils.NombreDispositivo, puerto_io))
-	err := http.ListenAndServe(puerto_io, mux)
+	err := http.ListenAndServe(puerto_io, globales.Rastrear(mux))
 	if err != nil {
 		slog.Error(fmt.Sprintf("Error al iniciar el servidor: %s", err.Error()))
 		os.Exit(1)
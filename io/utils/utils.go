@@ -1,7 +1,7 @@
 package utils
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"globales"
 	"log"
@@ -24,6 +24,9 @@ var mutexProcesamientoIO sync.Mutex
 
 var procesandoIO chan int = make(chan int, 1)
 
+// se cierra para cortar antes de tiempo la IO en curso, nil si no hay nada para cancelar
+var cancelarPeticionActual chan struct{}
+
 // --------- ESTRUCTURAS DE IO --------- //
 type Config struct {
 	PORT_IO     int    `json:"port_io"`
@@ -31,6 +34,9 @@ type Config struct {
 	IP_KERNEL   string `json:"ip_kernel"`
 	PORT_KERNEL int    `json:"port_kernel"`
 	LOG_LEVEL   string `json:"log_level"`
+
+	globales.ConfigRPC // rpc_timeout, rpc_retries, rpc_backoff y spans_file
+	globales.ConfigLog // log_format, log_stdout, log_max_size, log_max_age y log_max_files
 }
 
 type PeticionIO struct {
@@ -53,16 +59,19 @@ type RespuestaIO struct {
 }
 
 // --------- FUNCIONES DE IO --------- //
+// Lo que tiene que cumplir el config para que la IO arranque
+var esquemaConfig = globales.Esquema{
+	"port_io":     {Obligatorio: true, Rango: []float64{1, 65535}},
+	"ip_io":       {Obligatorio: true},
+	"ip_kernel":   {Obligatorio: true},
+	"port_kernel": {Obligatorio: true, Rango: []float64{1, 65535}},
+}
+
 func IniciarConfiguracion(filePath string) *Config {
-	var config *Config
-	configFile, err := os.Open(filePath)
-	if err != nil {
+	config := &Config{}
+	if err := globales.CargarConfiguracion("io", filePath, config, esquemaConfig); err != nil {
 		log.Fatal(err.Error())
 	}
-	defer configFile.Close()
-
-	jsonParser := json.NewDecoder(configFile)
-	jsonParser.Decode(&config)
 
 	procesandoIO <- 1
 
@@ -78,7 +87,10 @@ func RealizarHandshake(ip_kernel string, puerto_kernel int) {
 	}
 
 	// armo el mensaje con el nombre del disp IO
-	globales.GenerarYEnviarPaquete(&handshake, ip_kernel, puerto_kernel, "/io/handshake")
+	if err := globales.Enviar(context.Background(), ip_kernel, puerto_kernel, "/io/handshake", &handshake); err != nil {
+		slog.Error(fmt.Sprintf("No se pudo hacer el handshake con el Kernel: %v", err))
+		os.Exit(1)
+	}
 	slog.Debug(fmt.Sprintf("Enviado handshake al Kernel como dispositivo IO: %s", NombreDispositivo))
 }
 
@@ -88,25 +100,86 @@ func AtenderPeticionIO(w http.ResponseWriter, r *http.Request) {
 
 	// marco q estoy trabajando
 	<-procesandoIO
+	cancelacion := make(chan struct{})
 	mutexPeticionIO.Lock()
 	PIDActual = peticion.PID
+	cancelarPeticionActual = cancelacion
 	mutexPeticionIO.Unlock()
 
-	slog.Info(fmt.Sprintf("## PID: %d - Inicio de IO - Tiempo: %d", peticion.PID, peticion.Tiempo)) // log obligatorio
+	slog.Info(fmt.Sprintf("## PID: %d - Inicio de IO - Tiempo: %d", peticion.PID, peticion.Tiempo), "pid", peticion.PID, "duration", peticion.Tiempo, "io", NombreDispositivo) // log obligatorio
 
 	// contestar ok al kernel
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("ok"))
 
 	// arranco la io en paralelo
-	go procesarIO(peticion.PID, peticion.Tiempo)
+	go procesarIO(peticion.PID, peticion.Tiempo, cancelacion)
+}
+
+// el kernel cancela la IO en curso de un proceso (por ejemplo porque lo finalizó)
+func CancelarPeticionIO(w http.ResponseWriter, r *http.Request) {
+	peticion := PeticionIO{}
+	peticion = globales.DecodificarPaquete(w, r, &peticion)
+
+	mutexPeticionIO.Lock()
+	cancelada := PIDActual == peticion.PID && cancelarPeticionActual != nil
+	if cancelada {
+		close(cancelarPeticionActual)
+		cancelarPeticionActual = nil
+	}
+	mutexPeticionIO.Unlock()
+
+	if !cancelada {
+		slog.Debug(fmt.Sprintf("## PID: %d - No hay IO en curso para cancelar", peticion.PID))
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("no hay IO en curso para el PID"))
+		return
+	}
+
+	slog.Debug(fmt.Sprintf("## PID: %d - Cancelando IO en curso", peticion.PID))
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
 }
 
-func procesarIO(pid int, tiempo int) {
-	// simular uso de io
-	time.Sleep(time.Duration(tiempo) * time.Millisecond)
+// el kernel consulta periodicamente si la instancia sigue viva
+func AtenderHeartbeat(w http.ResponseWriter, r *http.Request) {
+	mutexPeticionIO.Lock()
+	pid := PIDActual
+	mutexPeticionIO.Unlock()
 
-	slog.Info(fmt.Sprintf("## PID: %d - Fin de IO", pid)) // log obligatorio
+	slog.Debug(fmt.Sprintf("Heartbeat recibido del Kernel - PID en curso: %d", pid))
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
+func procesarIO(pid int, tiempo int, cancelacion chan struct{}) {
+	// simular uso de io, cortando antes si el kernel la cancela
+	select {
+	case <-time.After(time.Duration(tiempo) * time.Millisecond):
+	case <-cancelacion:
+	}
+
+	mutexPeticionIO.Lock()
+	cancelada := false
+	select {
+	case <-cancelacion:
+		cancelada = true
+	default:
+		cancelarPeticionActual = nil // a partir de aca ya no se puede cancelar
+	}
+	mutexPeticionIO.Unlock()
+
+	if cancelada {
+		// el kernel ya libero la instancia, no hace falta avisarle
+		slog.Info(fmt.Sprintf("## PID: %d - IO cancelada", pid))
+		mutexProcesamientoIO.Lock()
+		PIDActual = -1
+		mutexProcesamientoIO.Unlock()
+		procesandoIO <- 1
+		return
+	}
+
+	slog.Info(fmt.Sprintf("## PID: %d - Fin de IO", pid), "pid", pid, "io", NombreDispositivo) // log obligatorio
 
 	respuesta := RespuestaIO{
 		PID:                pid,
@@ -120,7 +193,9 @@ func procesarIO(pid int, tiempo int) {
 	ip_kernel := ClientConfig.IP_KERNEL
 	puerto_kernel := ClientConfig.PORT_KERNEL
 	slog.Debug(fmt.Sprintf("## PID: %d - Envio respuesta al kernel", pid))
-	globales.GenerarYEnviarPaquete(&respuesta, ip_kernel, puerto_kernel, "/io/finalizado")
+	if err := globales.Enviar(context.Background(), ip_kernel, puerto_kernel, "/io/finalizado", &respuesta); err != nil {
+		slog.Error(fmt.Sprintf("## PID: %d - No se pudo avisar el fin de IO al kernel: %v", pid, err))
+	}
 
 	mutexProcesamientoIO.Lock()
 	// libero todo para procesar el siguiente
@@ -25,10 +25,11 @@ func main() {
 	utils.ClientConfig = utils.IniciarConfiguracion(utils.RutaConfig)
 
 	// ------ LOGGING ------ //
-	globales.ConfigurarLogger("kernel.log", utils.ClientConfig.LOG_LEVEL)
+	globales.ConfigurarLogger("kernel.log", utils.ClientConfig.LOG_LEVEL, utils.ClientConfig.ConfigLog)
 
-	if utils.ClientConfig == nil {
-		slog.Error("No se pudo crear el config")
+	globales.ConfigurarRPC(utils.ClientConfig.ConfigRPC)
+	if err := globales.IniciarSpans("kernel", utils.ClientConfig.SPANS_FILE); err != nil {
+		slog.Error(err.Error())
 	}
 
 	// ------ INICIALIZACION DE VARIABLES LOCALES ------ //
@@ -41,12 +42,18 @@ func main() {
 	mux.HandleFunc("/cpu/handshake", utils.AtenderHandshakeCPU) // TODO: implementar con semaforo para que no haya CC
 	mux.HandleFunc("/cpu/interrupt", utils.RecibirProcesoInterrumpido)
 	mux.HandleFunc("/cpu/solicitarIO", utils.IO)                  // syscall IO
+	mux.HandleFunc("/cpu/solicitarIOAsincrona", utils.IOAsincrona) // syscall IO_ASYNC
+	mux.HandleFunc("/cpu/esperarIO", utils.EsperarIO)             // syscall IO_WAIT
 	mux.HandleFunc("/cpu/iniciarProceso", utils.IniciarProceso)   // syscall INIT_PROC
 	mux.HandleFunc("/cpu/terminarProceso", utils.TerminarProceso) // syscall EXIT
 	mux.HandleFunc("/cpu/dumpearMemoria", utils.DumpearMemoria)   // syscall DUMP_MEMORY
+	mux.HandleFunc("/cpu/excepcion", utils.AtenderExcepcion)      // fallo del proceso detectado por la CPU
 	mux.HandleFunc("/io/handshake", utils.AtenderHandshakeIO)
 	mux.HandleFunc("/io/finalizado", utils.AtenderFinIOPeticion)
 	mux.HandleFunc("/cpu/desconectar", utils.DesconectarCPU)
+	mux.HandleFunc("/admin/io", utils.AdminIO) // estado de salud de las instancias de IO
+	mux.HandleFunc("/admin/procesos", utils.AdminProcesos) // estado y motivo de salida de cada proceso
+	mux.HandleFunc("/admin/cpu", utils.AdminCPU)             // nucleos de CPU conectados
 
 	// Manejar señales para terminar el programa de forma ordenada
 	sigChan := make(chan os.Signal, 1)                      // canal para recibir señales
@@ -58,7 +65,7 @@ func main() {
 
 	// ------ INICIALIZACION DEL CLIENTE ------ //
 
-	utils.CrearProceso(rutaInicial, tamanio) // creo el proceso inicial
+	utils.CrearProceso(rutaInicial, tamanio, 0) // creo el proceso inicial
 	slog.Info("Presione ENTER para iniciar el planificador...")
 	bufio.NewReader(os.Stdin).ReadBytes('\n')
 
@@ -84,6 +91,7 @@ func main() {
 		slog.Debug(fmt.Sprintf("Valor channel cpu disponible de cpu %s : %d", cpu.ID_CPU, len(cpu.DISPONIBLE)))
 	}
 	slog.Debug(fmt.Sprintf("Valor channel InterrumpirCPU: %d", len(utils.InterrumpirCPU)))
+	globales.CerrarSpans()
 }
 
 func MapearPIDs(pcbList []*utils.PCB) []int {
@@ -98,7 +106,7 @@ func MapearPIDs(pcbList []*utils.PCB) []int {
 
 
 func escucharPeticiones(puerto string, mux *http.ServeMux) {
-	err := http.ListenAndServe(puerto, mux)
+	err := http.ListenAndServe(puerto, globales.Rastrear(mux))
 	if err != nil {
 		slog.Error(fmt.Sprintf("Error al iniciar el servidor: %s", err.Error()))
 		//panic(err)
@@ -1,15 +1,21 @@
 package utils
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"globales"
+	"log"
 	"log/slog"
+	"maps"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -27,8 +33,32 @@ type PCB struct {
 	EstimadoAnterior                   float32         `json:"estimado_anterior"` // Estimado de tiempo de CPU anterior
 	EsperandoFinalizacionDeOtroProceso bool            `json:"esperando_finalizacion_de_otro_proceso"`
 	EstaEnSwap                         chan int
-	RafagaAnterior                     float32 `json:"rafaga_anterior"` // Rafaga anterior del proceso
-}
+	RafagaAnterior                     float32            `json:"rafaga_anterior"`  // Rafaga anterior del proceso
+	Prioridad                          int                `json:"prioridad"`        // menor valor = mayor prioridad (se usa en las colas de IO)
+	HandlesIO                          map[int]bool       `json:"handles_io"`       // IO asincronas del proceso: false = en curso, true = completada
+	EsperandoHandle                    int                `json:"esperando_handle"` // handle por el que esta bloqueado en IO_WAIT, -1 si ninguno
+	Registros                          globales.Registros `json:"registros"`        // registros de proposito general, se guardan en cada cambio de contexto
+	MotivoSalida                       MotivoSalida       `json:"motivo_salida"`    // vacio mientras el proceso no llegue a EXIT
+}
+
+// Motivo por el que un proceso llego a EXIT
+type MotivoSalida string
+
+const (
+	SalidaExit                   MotivoSalida = "EXIT" // syscall EXIT
+	SalidaPseudocodigoInvalido   MotivoSalida = "PSEUDOCODIGO_INVALIDO"
+	SalidaErrorDump              MotivoSalida = "ERROR_DUMP"
+	SalidaErrorSwap              MotivoSalida = "ERROR_SWAP"
+	SalidaDispositivoInexistente MotivoSalida = "DISPOSITIVO_IO_INEXISTENTE"
+	SalidaColaIOLlena            MotivoSalida = "COLA_IO_LLENA"
+	SalidaHandleIOInvalido       MotivoSalida = "HANDLE_IO_INVALIDO"
+	SalidaDispositivoIOCaido     MotivoSalida = "DISPOSITIVO_IO_CAIDO" // se desconectaron todas las instancias del dispositivo
+	// fallos detectados por la CPU, con el mismo nombre que el tipo de globales.Excepcion
+	SalidaAccesoInvalido      MotivoSalida = globales.ExcepcionAccesoInvalido
+	SalidaViolacionProteccion MotivoSalida = globales.ExcepcionViolacionProteccion
+	SalidaInstruccionInvalida MotivoSalida = globales.ExcepcionInstruccionInvalida
+	SalidaErrorMemoria        MotivoSalida = globales.ExcepcionErrorMemoria
+)
 
 // Esta estructura las podriamos cambiar por un array de contadores/acumuladores
 // Lo cambiamos a metricas kernel para no confundir con las metricas de proceso del modulo de Memoria
@@ -53,6 +83,39 @@ type Config struct {
 	INITIAL_ESTIMATE        float32 `json:"initial_estimate"`
 	SUSPENSION_TIME         int     `json:"suspension_time"`
 	LOG_LEVEL               string  `json:"log_level"`
+
+	IO_DEVICES map[string]ConfigDispositivoIO `json:"io_devices"` // configuracion opcional por nombre de dispositivo
+
+	IO_HEARTBEAT_INTERVAL     int `json:"io_heartbeat_interval"`     // en milisegundos, 0 = sin heartbeats
+	IO_HEARTBEAT_MAX_FAILURES int `json:"io_heartbeat_max_failures"` // heartbeats fallidos seguidos para dar la instancia por caida
+
+	CPU_HEARTBEAT_INTERVAL     int `json:"cpu_heartbeat_interval"`     // en milisegundos, 0 = sin heartbeats
+	CPU_HEARTBEAT_MAX_FAILURES int `json:"cpu_heartbeat_max_failures"` // heartbeats fallidos seguidos para dar la CPU por caida
+
+	globales.ConfigRPC // rpc_timeout, rpc_retries, rpc_backoff y spans_file
+	globales.ConfigLog // log_format, log_stdout, log_max_size, log_max_age y log_max_files
+}
+
+// Lo que tiene que cumplir el config para que el kernel arranque
+var esquemaConfig = globales.Esquema{
+	"ip_memory":                  {Obligatorio: true},
+	"port_memory":                {Obligatorio: true, Rango: []float64{1, 65535}},
+	"ip_kernel":                  {Obligatorio: true},
+	"port_kernel":                {Obligatorio: true, Rango: []float64{1, 65535}},
+	"scheduler_algorithm":        {Obligatorio: true, Valores: []string{"FIFO", "SJF", "SRT"}},
+	"ready_ingress_algorithm":    {Obligatorio: true, Valores: []string{"FIFO", "PMCP"}},
+	"alpha":                      {Rango: []float64{0, 1}},
+	"initial_estimate":           {Rango: []float64{0}},
+	"suspension_time":            {Rango: []float64{0}},
+	"io_heartbeat_interval":      {Rango: []float64{0}},
+	"io_heartbeat_max_failures":  {Rango: []float64{0}},
+	"cpu_heartbeat_interval":     {Rango: []float64{0}},
+	"cpu_heartbeat_max_failures": {Rango: []float64{0}},
+}
+
+type ConfigDispositivoIO struct {
+	QUEUE_POLICY     string `json:"queue_policy"`     // FIFO, SRF (menor tiempo de IO primero) o PRIORIDAD
+	MAX_QUEUE_LENGTH int    `json:"max_queue_length"` // 0 = sin limite
 }
 
 type PeticionSwap struct {
@@ -73,12 +136,44 @@ type DispositivoIO struct {
 	TengoInstancias     bool
 	Instancias          []*InstanciaIO // lista de instancias del dispositivo IO
 	procesosEsperandoIO chan int
+	Politica            string // politica de la cola: FIFO, SRF o PRIORIDAD
+	MaxCola             int    // cantidad maxima de peticiones esperando, 0 = sin limite
+	reservas            int    // lugares de la cola reservados por syscalls que todavia no encolaron
 }
 type InstanciaIO struct {
 	IP             string
 	Puerto         int
 	EstaDisponible chan int
 	EstaConectada  bool
+	EnCurso        *ProcesoEsperandoIO // peticion que la instancia acepto y esta atendiendo, nil si esta libre
+	Enviada        *ProcesoEsperandoIO // peticion mandada a /io/peticion que la instancia todavia no acepto
+	Estado         string              // salud segun los heartbeats: OK, SOSPECHOSA o CAIDA
+	Fallos         int                 // heartbeats fallidos seguidos
+	UltimoContacto time.Time           // ultimo heartbeat respondido
+}
+
+// Estado de una instancia de IO que se muestra en /admin/io
+type EstadoInstanciaIO struct {
+	IP             string `json:"ip"`
+	Puerto         int    `json:"puerto"`
+	Estado         string `json:"estado"`
+	Fallos         int    `json:"fallos"`
+	UltimoContacto string `json:"ultimo_contacto"`
+	PIDEnCurso     int    `json:"pid_en_curso"` // -1 si esta libre
+}
+
+type EstadoDispositivoIO struct {
+	Nombre     string              `json:"nombre"`
+	Politica   string              `json:"politica"`
+	Encolados  int                 `json:"encolados"`
+	Instancias []EstadoInstanciaIO `json:"instancias"`
+}
+
+type EstadoProceso struct {
+	PID          int          `json:"pid"`
+	Estado       string       `json:"estado"`
+	PC           int          `json:"pc"`
+	MotivoSalida MotivoSalida `json:"motivo_salida,omitempty"`
 }
 
 type RespuestaIO struct {
@@ -95,8 +190,10 @@ type PeticionIO struct {
 }
 
 type ProcesoEsperandoIO struct {
-	PCB    *PCB
-	Tiempo int
+	PCB       *PCB
+	Tiempo    int
+	Asincrona bool // pedida con IO_ASYNC, el proceso sigue ejecutando
+	Handle    int
 }
 
 // --------- VARIABLES DEL KERNEL --------- //
@@ -162,24 +259,26 @@ var estimadoInicial float32
 // lista de ios q se conectaron
 var DispositivosIO []*DispositivoIO
 var mutexDispositivosIO sync.Mutex // mutex para proteger el acceso a DispositivosIO
+var mutexInstanciasIO sync.Mutex   // mutex para proteger la peticion en curso de cada instancia
+var mutexHandlesIO sync.Mutex      // mutex para proteger los handles de IO asincronas de los PCBs
 
 var CPUporProceso = make(map[string]int) // clave: ID de CPU, valor: PID del proceso que está ejecutando
 var mutexCPUporProceso sync.Mutex        // mutex para proteger el acceso a CPUporProceso
 
+var cpusCaidas = make(map[string]bool) // CPUs dadas de baja por heartbeats, protegido por mutexConexionesCPU
+
 var cpupendienteInterrupcion = make(map[string]bool)
 var mutexInterrupcionesCPU sync.Mutex
 
 // --------- FUNCIONES DEL KERNEL --------- //
 func IniciarConfiguracion(filePath string) *Config {
-	var config *Config
-	configFile, err := os.Open(filePath)
-	if err != nil {
-		slog.Error(err.Error())
+	config := &Config{}
+	if err := globales.CargarConfiguracion("kernel", filePath, config, esquemaConfig); err != nil {
+		log.Fatal(err.Error())
+	}
+	if err := validarDispositivosIO(config.IO_DEVICES); err != nil {
+		log.Fatalf("config %s invalido: %s", filePath, err.Error())
 	}
-	defer configFile.Close()
-
-	jsonParser := json.NewDecoder(configFile)
-	jsonParser.Decode(&config)
 
 	slog.Debug(fmt.Sprintf("Configuración cargada: %+v", *config))
 
@@ -527,13 +626,11 @@ func RecibirProcesoInterrumpido(w http.ResponseWriter, r *http.Request) {
 		slog.Debug("Antes del mutexInterrupcionesCPU")
 		mutexInterrupcionesCPU.Lock()
 		slog.Debug("Despues del mutexInterrupcionesCPU")
+		pedidaPorKernel := cpupendienteInterrupcion[id_cpu]
 		delete(cpupendienteInterrupcion, id_cpu)
 		mutexInterrupcionesCPU.Unlock()
-		select {
-		case <-InterrumpirCPU:
-			slog.Debug("Señal de InterrumpirCPU consumida")
-		default:
-			slog.Debug("No había señal pendiente en InterrumpirCPU")
+		if pedidaPorKernel {
+			consumirSenialInterrumpirCPU()
 		}
 		w.WriteHeader(http.StatusNotFound)
 		w.Write([]byte("Pcb no encontrado"))
@@ -541,11 +638,19 @@ func RecibirProcesoInterrumpido(w http.ResponseWriter, r *http.Request) {
 	}
 	slog.Debug("antes de borrar la interrupcion del map")
 
+	// un fin de quantum lo decide la CPU sola: solo si el kernel pidio este desalojo la señal de
+	// InterrumpirCPU es nuestra, si no puede ser la de un desalojo por SRT pendiente en otra CPU
 	mutexInterrupcionesCPU.Lock()
+	pedidaPorKernel := cpupendienteInterrupcion[id_cpu]
 	delete(cpupendienteInterrupcion, id_cpu)
 	mutexInterrupcionesCPU.Unlock()
+	slog.Debug(fmt.Sprintf("## (%d) - Vuelve de la CPU %s por interrupción %s", pid, id_cpu, paquete.MOTIVO))
+	if paquete.MOTIVO == globales.InterrupcionQuantum {
+		slog.Info(fmt.Sprintf("## (%d) - Desalojado por fin de Quantum", pid), "pid", pid) // log obligatorio
+	}
 	slog.Debug("Antes del mutexOrdenandoColaReady")
 	pcb.PC = paquete.PC
+	pcb.Registros = paquete.REGISTROS
 	mutexOrdenandoColaReady.Lock()
 	slog.Debug("Despues del mutexOrdenandoColaReady")
 	AgregarPCBaCola(pcb, ColaReady)
@@ -554,14 +659,20 @@ func RecibirProcesoInterrumpido(w http.ResponseWriter, r *http.Request) {
 	mutexOrdenandoColaReady.Unlock()
 	ProcesosEnReady <- 1
 	//EsperandoInterrupcion <- 1
+	if pedidaPorKernel {
+		consumirSenialInterrumpirCPU()
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
+func consumirSenialInterrumpirCPU() {
 	select {
 	case <-InterrumpirCPU:
 		slog.Debug("Señal de InterrumpirCPU consumida")
 	default:
 		slog.Debug("No había señal pendiente en InterrumpirCPU")
 	}
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("ok"))
 }
 
 func buscarCPUConId(id string) (*globales.HandshakeCPU, error) {
@@ -616,7 +727,14 @@ func AtenderHandshakeCPU(w http.ResponseWriter, r *http.Request) {
 		ConexionesCPU = append(ConexionesCPU, paquete)
 		paquete.CONECTADA = true // marca la CPU como conectada
 		//cpu.CONECTADA = true
+		reconectada := cpusCaidas[paquete.ID_CPU]
+		delete(cpusCaidas, paquete.ID_CPU)
 		mutexConexionesCPU.Unlock() // desbloquea
+
+		if reconectada {
+			slog.Info(fmt.Sprintf("CPU %s reconectada luego de haber sido dada de baja", paquete.ID_CPU))
+		}
+		go monitorearCPU(paquete)
 		//slog.Warn("(despues) MutexConexionesCPU")
 
 		//slog.Warn(fmt.Sprintf("Antes de escribir en paquete.DISPONIBLE con ID: %s, len: %d", paquete.ID_CPU, len(paquete.DISPONIBLE)))
@@ -678,8 +796,7 @@ func loopCPU(cpu *globales.HandshakeCPU) {
 	for cpu.CONECTADA {
 		//slog.Info(fmt.Sprintf("CPU CONECTADA %v", cpu.CONECTADA))
 
-		_, err := buscarCPUConId(cpu.ID_CPU)
-		if err != nil {
+		if !cpuSigueConectada(cpu) {
 			slog.Error(fmt.Sprintf("CPU %s desconectada, no se planifica", cpu.ID_CPU))
 			cpu.CONECTADA = false // desconectar
 			return
@@ -740,7 +857,8 @@ func finalizadorDeProcesos() {
 		*cola = append(*cola, pcb)
 		mutex.Unlock()
 
-		if !FinalizarProceso(pcb.PID, cola) {
+		// solo llegan aca los procesos de un dispositivo IO que se desconecto
+		if !FinalizarProceso(pcb.PID, cola, SalidaDispositivoIOCaido) {
 			slog.Error(fmt.Sprintf("No se pudo finalizar el proceso con PID %d", pcb.PID))
 			*ProcesosEsperandoAFinalizar = append(*ProcesosEsperandoAFinalizar, pcb)
 			//VerificadorEstadoProcesos()
@@ -759,6 +877,10 @@ func finalizadorDeProcesos() {
 func planificarSinEstimador(cpu *globales.HandshakeCPU) {
 
 	<-cpu.DISPONIBLE
+	if !cpuSigueConectada(cpu) {
+		ProcesosEnReady <- 1 // devuelvo la señal para que la use otra CPU
+		return
+	}
 
 	mutexCPUporProceso.Lock()
 	CPUporProceso[cpu.ID_CPU] = -1
@@ -802,6 +924,10 @@ func planificarConEstimador(cpu *globales.HandshakeCPU) {
 	//slog.Warn(fmt.Sprintf("Valor del channel de disponibilidad de cpu antes del wait cpu %s: %v", cpu.ID_CPU, len(cpu.DISPONIBLE)))
 
 	<-cpu.DISPONIBLE
+	if !cpuSigueConectada(cpu) {
+		ProcesosEnReady <- 1 // devuelvo la señal para que la use otra CPU
+		return
+	}
 	//<-Planificando
 	//slog.Warn(fmt.Sprintf("Valor del channel de disponibilidad de cpu despues del wait cpu %s: %v", cpu.ID_CPU, len(cpu.DISPONIBLE)))
 	slog.Debug("Planificar Con desalojo 2")
@@ -869,8 +995,10 @@ func planificarConEstimador(cpu *globales.HandshakeCPU) {
 
 func EnviarProcesoACPU(pcb *PCB, cpu *globales.HandshakeCPU) {
 	peticionCPU := globales.ProcesoAEjecutar{
-		PID: pcb.PID,
-		PC:  pcb.PC,
+		PID:       pcb.PID,
+		PC:        pcb.PC,
+		REGISTROS: pcb.Registros,
+		TAMANIO:   pcb.Tamanio,
 	}
 
 	ip := cpu.IP_CPU
@@ -880,7 +1008,7 @@ func EnviarProcesoACPU(pcb *PCB, cpu *globales.HandshakeCPU) {
 
 	slog.Debug("Intentando enviar pcb a cpu ...")
 
-	slog.Info(fmt.Sprintf("## (%d) Pasa del estado READY al estado RUNNING", pcb.PID))
+	slog.Info(fmt.Sprintf("## (%d) Pasa del estado READY al estado RUNNING", pcb.PID), "pid", pcb.PID, "state_from", "READY", "state", "RUNNING") // log obligatorio
 	AgregarPCBaCola(pcb, ColaRunning)
 
 	/*
@@ -897,14 +1025,17 @@ func EnviarProcesoACPU(pcb *PCB, cpu *globales.HandshakeCPU) {
 		}
 		mutexCPUporProceso.Unlock()*/
 
-	resp, _ := globales.GenerarYEnviarPaquete(&peticionCPU, ip, puerto, url)
-	if resp.StatusCode != 200 {
-		slog.Error(fmt.Sprintf("Error al enviar el proceso a la CPU: %s", resp.Status))
+	if err := globales.Enviar(context.Background(), ip, puerto, url, &peticionCPU); err != nil {
+		slog.Error(fmt.Sprintf("Error al enviar el proceso a la CPU: %v", err))
 		//CpusDisponibles <- 1 // vuelvo a liberar el canal de cpus disponibles
 		mutexCPUporProceso.Lock()
 		delete(CPUporProceso, cpu.ID_CPU)
 		mutexCPUporProceso.Unlock()
-		cpu.DISPONIBLE <- 1
+		select {
+		case cpu.DISPONIBLE <- 1:
+		default:
+			// la CPU se dio de baja y ya se despertó a su planificador
+		}
 		buscarPCBYSacarDeCola(pcb.PID, ColaRunning)
 		ReinsertarEnFrenteCola(ColaReady, pcb)
 		ProcesosEnReady <- 1
@@ -952,9 +1083,9 @@ func IniciarProceso(w http.ResponseWriter, r *http.Request) {
 	paquete := globales.SolicitudProceso{}
 	paquete = globales.DecodificarPaquete(w, r, &paquete)
 
-	slog.Info(fmt.Sprintf("## (%d) - Solicitó syscall - INIT_PROC", paquete.PID)) // log obligatorio
+	slog.Info(fmt.Sprintf("## (%d) - Solicitó syscall - INIT_PROC", paquete.PID), "pid", paquete.PID, "syscall", "INIT_PROC") // log obligatorio
 
-	go CrearProceso(paquete.ARCHIVO_PSEUDOCODIGO, paquete.TAMAÑO_PROCESO)
+	go CrearProceso(paquete.ARCHIVO_PSEUDOCODIGO, paquete.TAMAÑO_PROCESO, paquete.PRIORIDAD)
 
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("ok"))
@@ -964,11 +1095,11 @@ func TerminarProceso(w http.ResponseWriter, r *http.Request) {
 	pid := globales.PID{}
 	pid = globales.DecodificarPaquete(w, r, &pid)
 
-	slog.Info(fmt.Sprintf("## (%d) - Solicitó syscall - EXIT", pid.NUMERO_PID)) // log obligatorio
+	slog.Info(fmt.Sprintf("## (%d) - Solicitó syscall - EXIT", pid.NUMERO_PID), "pid", pid.NUMERO_PID, "syscall", "EXIT") // log obligatorio
 
 	slog.Debug(fmt.Sprintf("Finalizando proceso (terminar proceso) con PID: %d", pid))
 	//planificadorCortoPlazo.Lock()
-	FinalizarProceso(pid.NUMERO_PID, ColaRunning)
+	FinalizarProceso(pid.NUMERO_PID, ColaRunning, SalidaExit)
 
 	/* idcpu, err := buscarCPUConPid(pid.NUMERO_PID)
 	if err != nil {
@@ -991,7 +1122,20 @@ func TerminarProceso(w http.ResponseWriter, r *http.Request) {
 
 }
 
-func FinalizarProceso(pid int, cola *[]*PCB) bool {
+// La CPU detecto un fallo del proceso en ejecucion (acceso fuera de su espacio, instruccion invalida)
+func AtenderExcepcion(w http.ResponseWriter, r *http.Request) {
+	excepcion := globales.Excepcion{}
+	excepcion = globales.DecodificarPaquete(w, r, &excepcion)
+
+	slog.Info(fmt.Sprintf("## (%d) - Excepcion %s en PC %d: %s", excepcion.PID, excepcion.TIPO, excepcion.PC, excepcion.MOTIVO))
+
+	FinalizarProceso(excepcion.PID, ColaRunning, MotivoSalida(excepcion.TIPO))
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
+func FinalizarProceso(pid int, cola *[]*PCB, motivo MotivoSalida) bool {
 	slog.Debug(fmt.Sprintf("Cola READY (finalizar proceso): %v \n", &ColaReady))
 	slog.Debug(fmt.Sprintf("Cola RUNNING (finalizar proceso): %v \n", &ColaRunning))
 	slog.Debug(fmt.Sprintf("Cola EXIT (finalizar proceso): %v \n", &ColaExit))
@@ -1002,17 +1146,31 @@ func FinalizarProceso(pid int, cola *[]*PCB) bool {
 		slog.Error(fmt.Sprintf("No se encontró el PCB del PID %d en la cola", pid))
 		return false
 	} else {
+		pcb.MotivoSalida = motivo
+
+		// si estaba esperando una IO (o tenia IO asincronas en curso), liberamos el dispositivo sin esperar a que termine.
+		// Va en otra goroutine porque el finalizador puede llamarnos con locks tomados
+		go cancelarPeticionesIO(pid)
+
 		pid_a_eliminar := globales.PID{
 			NUMERO_PID: pid,
 		}
 
-		// peticion a memoria para liberar el espacio
-		slog.Debug(fmt.Sprintf("Eliminando proceso con PID: %d de memoria", pid))
-		globales.GenerarYEnviarPaquete(&pid_a_eliminar, ClientConfig.IP_MEMORY, ClientConfig.PORT_MEMORY, "/kernel/finalizar_proceso")
-		slog.Debug(fmt.Sprintf("Se elimino proceso con PID: %d de memoria", pid))
+		// peticion a memoria para liberar el espacio. Los procesos en NEW todavia no se crearon en memoria
+		// (por ejemplo los que memoria rechazo por pseudocodigo invalido), no hay nada que liberar
+		if cola != ColaNew {
+			slog.Debug(fmt.Sprintf("Eliminando proceso con PID: %d de memoria", pid))
+			if err := globales.Enviar(context.Background(), ClientConfig.IP_MEMORY, ClientConfig.PORT_MEMORY, "/kernel/finalizar_proceso", &pid_a_eliminar); err != nil {
+				// el proceso termina igual; el espacio queda ocupado en memoria hasta que se reinicie
+				slog.Error(fmt.Sprintf("## (%d) - No se pudo liberar el proceso en memoria: %v", pid, err))
+			} else {
+				slog.Debug(fmt.Sprintf("Se elimino proceso con PID: %d de memoria", pid))
+			}
+		}
 		AgregarPCBaCola(pcb, ColaExit)
 
-		slog.Info(fmt.Sprintf("## (%d) - Finaliza el proceso \n", pid)) // log obligatorio
+		slog.Info(fmt.Sprintf("## (%d) - Finaliza el proceso \n", pid), "pid", pid, "state", "EXIT", "motivo", string(motivo)) // log obligatorio
+		globales.TerminarSpanProceso(pid, string(motivo))
 
 		actualizarEsperandoFinalizacion(ColaSuspendedReady)
 		actualizarEsperandoFinalizacion(ColaNew)
@@ -1086,7 +1244,7 @@ func DumpearMemoria(w http.ResponseWriter, r *http.Request) {
 	paquete := globales.SolicitudDump{}
 	paquete = globales.DecodificarPaquete(w, r, &paquete)
 
-	slog.Info(fmt.Sprintf("## (%d) - Solicitó syscall - DUMP MEMORY", paquete.PID)) // log obligatorio
+	slog.Info(fmt.Sprintf("## (%d) - Solicitó syscall - DUMP MEMORY", paquete.PID), "pid", paquete.PID, "syscall", "DUMP_MEMORY") // log obligatorio
 
 	pidABloquear := paquete.PID
 	pc := paquete.PC
@@ -1110,16 +1268,17 @@ func DumpearMemoria(w http.ResponseWriter, r *http.Request) {
 	//CpusDisponibles <- 1
 
 	pcbABloquear.PC = pc
+	pcbABloquear.Registros = paquete.REGISTROS
 	recalcularEstimados(pcbABloquear) // recalculo el estimado del pcb
 	//AgregarPCBaCola(pcbABloquear, ColaBlocked)
 	PasarAEstadoBlocked(pcbABloquear)
-	slog.Info(fmt.Sprintf("## (%d) Pasa del estado RUNNING al estado BLOCKED", pidABloquear))
+	slog.Info(fmt.Sprintf("## (%d) Pasa del estado RUNNING al estado BLOCKED", pidABloquear), "pid", pidABloquear, "state_from", "RUNNING", "state", "BLOCKED") // log obligatorio
 
 	peticion := globales.PID{
 		NUMERO_PID: pidABloquear,
 	}
 
-	var peticionEnviada, _ = globales.GenerarYEnviarPaquete(&peticion, ClientConfig.IP_MEMORY, ClientConfig.PORT_MEMORY, "/kernel/dump_de_proceso")
+	errDump := globales.Enviar(context.Background(), ClientConfig.IP_MEMORY, ClientConfig.PORT_MEMORY, "/kernel/dump_de_proceso", &peticion)
 
 	for _, p := range *ProcesosSiendoSwapeados {
 		if p.PID == pidABloquear {
@@ -1130,7 +1289,7 @@ func DumpearMemoria(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if peticionEnviada.StatusCode == 200 { // me llega fin de operacion de memoria
+	if errDump == nil { // me llega fin de operacion de memoria
 
 		// desbloqueo el proceso y lo envio a ready
 		pcbADesbloquear, err := buscarPCBYSacarDeCola(pidABloquear, ColaBlocked)
@@ -1145,7 +1304,7 @@ func DumpearMemoria(w http.ResponseWriter, r *http.Request) {
 			}
 		} else {
 
-			pudoDesalojar, cpu := intentarDesalojo(pcbADesbloquear)
+			pudoDesalojar, cpu := intentarDesalojo(pcbADesbloquear, globales.InterrupcionDesalojo)
 			if pudoDesalojar {
 				ReinsertarEnFrenteCola(ColaReady, pcbADesbloquear)
 				actualizarMetricasEstado(pcbADesbloquear, "READY")
@@ -1166,18 +1325,20 @@ func DumpearMemoria(w http.ResponseWriter, r *http.Request) {
 			w.Write([]byte("ok"))
 		}
 	} else {
-		FinalizarProceso(pidABloquear, ColaBlocked) // en caso de error --> exit
+		slog.Error(fmt.Sprintf("## (%d) - Fallo el dump de memoria: %v", pidABloquear, errDump))
+		FinalizarProceso(pidABloquear, ColaBlocked, SalidaErrorDump) // en caso de error --> exit
 	}
 
 }
 
-func CrearProceso(rutaPseudocodigo string, tamanio int) {
+func CrearProceso(rutaPseudocodigo string, tamanio int, prioridad int) {
 	mutexCrearPID.Lock()
 	pid := UltimoPID
 	UltimoPID++
 	mutexCrearPID.Unlock()
 
-	slog.Info(fmt.Sprintf("## (%d) Se crea el proceso - Estado: NEW", pid))
+	slog.Info(fmt.Sprintf("## (%d) Se crea el proceso - Estado: NEW", pid), "pid", pid, "state", "NEW") // log obligatorio
+	globales.IniciarSpanProceso(pid)
 
 	pcb := PCB{
 		PID:                                pid,
@@ -1192,6 +1353,9 @@ func CrearProceso(rutaPseudocodigo string, tamanio int) {
 		RafagaAnterior:                     0,
 		EsperandoFinalizacionDeOtroProceso: false,
 		EstaEnSwap:                         make(chan int, 1),
+		Prioridad:                          prioridad,
+		HandlesIO:                          make(map[int]bool),
+		EsperandoHandle:                    -1,
 	}
 	pcb.EstaEnSwap <- 1
 
@@ -1199,7 +1363,10 @@ func CrearProceso(rutaPseudocodigo string, tamanio int) {
 	ordenarColaNew()
 }
 
-func CrearProcesoEnMemoria(pcb *PCB) bool {
+// memoria rechazo el archivo de pseudocodigo, reintentar no sirve
+var errPseudocodigoInvalido = errors.New("pseudocodigo invalido")
+
+func CrearProcesoEnMemoria(pcb *PCB) error {
 
 	archivoProceso := globales.MEMORIA_CREACION_PROCESO{ // Ida y vuelta con memoria
 		PID:                     pcb.PID,
@@ -1207,16 +1374,19 @@ func CrearProcesoEnMemoria(pcb *PCB) bool {
 		Tamanio:                 pcb.Tamanio,
 	}
 
-	resp, _ := globales.GenerarYEnviarPaquete(&archivoProceso, ClientConfig.IP_MEMORY, ClientConfig.PORT_MEMORY, "/kernel/inicializar_proceso")
+	err := globales.Enviar(context.Background(), ClientConfig.IP_MEMORY, ClientConfig.PORT_MEMORY, "/kernel/inicializar_proceso", &archivoProceso)
 
-	// espera 1 segundo para simular la creación del proceso
-	if resp.StatusCode == http.StatusOK {
+	var errorRespuesta *globales.ErrorRespuesta
+	if err == nil {
 		slog.Debug(fmt.Sprintf("Proceso con PID %d creado en memoria", pcb.PID))
-		return true
+		return nil
+	} else if errors.As(err, &errorRespuesta) && errorRespuesta.Estado == http.StatusBadRequest {
+		slog.Error(fmt.Sprintf("## (%d) - Pseudocodigo %s rechazado por memoria:\n%s", pcb.PID, pcb.RutaPseudocodigo, errorRespuesta.Cuerpo))
+		return errPseudocodigoInvalido
 	} else {
 		pcb.EsperandoFinalizacionDeOtroProceso = true // si no se pudo crear, queda esperando a que finalice otro proceso
 		slog.Error(fmt.Sprintf("Error al crear el proceso con PID %d en memoria", pcb.PID))
-		return false
+		return fmt.Errorf("no se pudo crear el proceso %d en memoria: %w", pcb.PID, err)
 	}
 
 }
@@ -1291,7 +1461,7 @@ func EnviarProcesoASwap(pcb *PCB) bool {
 	slog.Debug(fmt.Sprintf("EL PROCESO %d ESTA ENVIANDOSE A SWAP", pcb.PID))
 
 	*ProcesosSiendoSwapeados = append(*ProcesosSiendoSwapeados, pcb)
-	globales.GenerarYEnviarPaquete(&peticion, ip, puerto, "/kernel/suspender_proceso")
+	err := globales.Enviar(context.Background(), ip, puerto, "/kernel/suspender_proceso", &peticion)
 	for i, p := range *ProcesosSiendoSwapeados {
 		if p.PID == pcb.PID {
 			*ProcesosSiendoSwapeados = append((*ProcesosSiendoSwapeados)[:i], (*ProcesosSiendoSwapeados)[i+1:]...)
@@ -1299,6 +1469,10 @@ func EnviarProcesoASwap(pcb *PCB) bool {
 			break
 		}
 	}
+	if err != nil {
+		slog.Error(fmt.Sprintf("## (%d) - Memoria no pudo suspender el proceso: %v", pcb.PID, err))
+		return false
+	}
 	return true
 }
 
@@ -1427,14 +1601,15 @@ func PlanificadorLargoPlazo() {
 			//	continue
 			//}
 
-			if CrearProcesoEnMemoria(pcb) {
+			errCreacion := CrearProcesoEnMemoria(pcb)
+			if errCreacion == nil {
 				//mutexColaNew.Lock()
 				if len(*ColaNew) > 0 && (*ColaNew)[0].PID == pcb.PID {
 					*ColaNew = (*ColaNew)[1:]
 				}
 				mutexColaNew.Unlock()	
 						
-				pudoDesalojar, cpu := intentarDesalojo(pcb)
+				pudoDesalojar, cpu := intentarDesalojo(pcb, globales.InterrupcionDesalojo)
 				if pudoDesalojar {
 					ReinsertarEnFrenteCola(ColaReady, pcb)
 					actualizarMetricasEstado(pcb, "READY")
@@ -1451,7 +1626,11 @@ func PlanificadorLargoPlazo() {
 
 				}
 				ProcesosEnReady <- 1
-				slog.Info(fmt.Sprintf("## (%d) Pasa del estado NEW al estado READY", pcb.PID))
+				slog.Info(fmt.Sprintf("## (%d) Pasa del estado NEW al estado READY", pcb.PID), "pid", pcb.PID, "state_from", "NEW", "state", "READY") // log obligatorio
+			} else if errors.Is(errCreacion, errPseudocodigoInvalido) {
+				mutexColaNew.Unlock()
+				// no va a poder ejecutar nunca, pasa directo a EXIT
+				FinalizarProceso(pcb.PID, ColaNew, SalidaPseudocodigoInvalido)
 			} else {
 				mutexColaNew.Unlock()	
 
@@ -1463,7 +1642,8 @@ func PlanificadorLargoPlazo() {
 	}
 }
 
-func intentarDesalojo(pcbReady *PCB) (bool, *globales.HandshakeCPU) {
+// tipoInterrupcion es el motivo que le llega a la CPU (DESALOJO o FIN_IO)
+func intentarDesalojo(pcbReady *PCB, tipoInterrupcion string) (bool, *globales.HandshakeCPU) {
 	if algoritmoColaReady == "SRT" && len(ConexionesCPU) <= len(CPUporProceso) {
 		var tiempoRestante float32
 		pcbMasLento, errRunning := obtenerMayorEstimadoDeRunning()
@@ -1498,8 +1678,8 @@ func intentarDesalojo(pcbReady *PCB) (bool, *globales.HandshakeCPU) {
 				}
 				slog.Debug("SRTTTT 3")
 
-				InterrumpirProceso(pcbMasLento, cpuEjecutando)
-				slog.Info(fmt.Sprintf("## (%d) - Desalojado por algoritmo SJF/SRT", pcbMasLento.PID))
+				InterrumpirProceso(pcbMasLento, cpuEjecutando, tipoInterrupcion)
+				slog.Info(fmt.Sprintf("## (%d) - Desalojado por algoritmo SJF/SRT", pcbMasLento.PID), "pid", pcbMasLento.PID) // log obligatorio
 				cpu, _ := buscarCPUConId(cpuEjecutando)
 				return true, cpu
 				//planificadorCortoPlazo.Unlock()
@@ -1554,7 +1734,7 @@ func atenderColaSuspendidosReady() {
         mutexColaSuspendedReady.Unlock()
 
 		slog.Debug("Antes de intentar desalojo")
-		pudoDesalojar, cpu := intentarDesalojo(pcb)
+		pudoDesalojar, cpu := intentarDesalojo(pcb, globales.InterrupcionDesalojo)
 		if pudoDesalojar {
 			ReinsertarEnFrenteCola(ColaReady, pcb)
 			actualizarMetricasEstado(pcb, "READY")
@@ -1572,7 +1752,7 @@ func atenderColaSuspendidosReady() {
 		ProcesosEnReady <- 1
 
 		pcb.EstaEnSwap <- 1
-		slog.Info(fmt.Sprintf("## (%d) Pasa del estado SUSPENDED_READY al estado READY", pcb.PID))
+		slog.Info(fmt.Sprintf("## (%d) Pasa del estado SUSPENDED_READY al estado READY", pcb.PID), "pid", pcb.PID, "state_from", "SUSPENDED_READY", "state", "READY") // log obligatorio
 	} else {
 		mutexColaSuspendedReady.Unlock()
 		slog.Debug("No se pudo desuspender el proceso")
@@ -1591,9 +1771,11 @@ func desuspenderProceso(pcb *PCB) bool {
 		NUMERO_PID: pcb.PID,
 	}
 
-	resp, _ := globales.GenerarYEnviarPaquete(&peticion, ClientConfig.IP_MEMORY, ClientConfig.PORT_MEMORY, "/kernel/dessuspender_proceso")
-
-	return resp.StatusCode == 200
+	if err := globales.Enviar(context.Background(), ClientConfig.IP_MEMORY, ClientConfig.PORT_MEMORY, "/kernel/dessuspender_proceso", &peticion); err != nil {
+		slog.Debug(fmt.Sprintf("## (%d) - Memoria no pudo desuspender el proceso: %v", pcb.PID, err))
+		return false
+	}
+	return true
 }
 
 func BuscarCPULibre() (globales.HandshakeCPU, error) {
@@ -1622,7 +1804,7 @@ func BuscarCPULibre() (globales.HandshakeCPU, error) {
 	return globales.HandshakeCPU{}, fmt.Errorf("no hay CPUs disponibles")
 }
 
-func InterrumpirProceso(pcb *PCB, id_cpu string) {
+func InterrumpirProceso(pcb *PCB, id_cpu string, tipo string) {
 
 	slog.Debug(fmt.Sprintf("Enviando interrupción a CPU %s para desalojar PID %d", id_cpu, pcb.PID))
 
@@ -1638,15 +1820,13 @@ func InterrumpirProceso(pcb *PCB, id_cpu string) {
 
 	interrupcion := globales.Interrupcion{
 		PID:    pcb.PID,
-		MOTIVO: "Desalojo por SRT",
+		MOTIVO: tipo,
 	}
 
 	endpoint := fmt.Sprintf("/cpu/%s/interruptDesalojo", cpu.ID_CPU)
 
-	resp, _ := globales.GenerarYEnviarPaquete(&interrupcion, cpu.IP_CPU, cpu.PORT_CPU, endpoint)
-
-	if resp.StatusCode != 200 {
-		slog.Error(fmt.Sprintf("Error al enviar la interrupción a la CPU %s: %s", cpu.ID_CPU, resp.Status))
+	if err := globales.Enviar(context.Background(), cpu.IP_CPU, cpu.PORT_CPU, endpoint, &interrupcion); err != nil {
+		slog.Error(fmt.Sprintf("Error al enviar la interrupción a la CPU %s: %v", cpu.ID_CPU, err))
 		mutexInterrupcionesCPU.Lock()
 		delete(cpupendienteInterrupcion, id_cpu)
 		mutexInterrupcionesCPU.Unlock()
@@ -1723,20 +1903,20 @@ func iniciarTimerSuspension(pcb *PCB) {
 			pcbASuspender.EstaEnSwap <- 1
 			actualizarEsperandoFinalizacion(ColaSuspendedReady)
 			actualizarEsperandoFinalizacion(ColaNew)
-			slog.Info(fmt.Sprintf("## (%d) Pasa de BLOCKED a SUSPENDED_BLOCKED", pcb.PID))
+			slog.Info(fmt.Sprintf("## (%d) Pasa del estado BLOCKED al estado SUSPENDED_BLOCKED", pcb.PID), "pid", pcb.PID, "state_from", "BLOCKED", "state", "SUSPENDED_BLOCKED") // log obligatorio
 		} else {
-			// Si falla el swap, lo devuelvo a BLOCKED
-			//AgregarPCBaCola(pcbASuspender, ColaBlocked)
+			// Si falla el swap, lo devuelvo a BLOCKED para finalizarlo desde ahi
+			AgregarPCBaCola(pcbASuspender, ColaBlocked)
 			pcbASuspender.EstaEnSwap <- 1
-			go iniciarTimerSuspension(pcbASuspender)
-			slog.Error(fmt.Sprintf("## (%d) Falló swap, se mantiene en BLOCKED", pcb.PID))
+			slog.Error(fmt.Sprintf("## (%d) Falló swap, se finaliza el proceso", pcb.PID))
+			FinalizarProceso(pcb.PID, ColaBlocked, SalidaErrorSwap)
 		}
 		return
 	}
 }
 
 func ImprimirMetricasProceso(pcb PCB) {
-	slog.Info(fmt.Sprintf("## (%d) - Métricas de estado: NEW (%d) (%d), READY (%d) (%d), RUNNING (%d) (%d), BLOCKED (%d) (%d), SUSPENDED_BLOCKED (%d) (%d), SUSPENDED_READY (%d) (%d), EXIT (%d) (%d)",
+	slog.Info(fmt.Sprintf("## (%d) - Métricas de estado: NEW (%d) (%d), READY (%d) (%d), RUNNING (%d) (%d), BLOCKED (%d) (%d), SUSPENDED_BLOCKED (%d) (%d), SUSPENDED_READY (%d) (%d), EXIT (%d) (%d) - Motivo: %s",
 		pcb.PID,
 		pcb.ME.NEW, pcb.MT.NEW,
 		pcb.ME.READY, pcb.MT.READY,
@@ -1744,7 +1924,8 @@ func ImprimirMetricasProceso(pcb PCB) {
 		pcb.ME.BLOCKED, pcb.MT.BLOCKED,
 		pcb.ME.SUSPENDED_BLOCKED, pcb.MT.SUSPENDED_BLOCKED,
 		pcb.ME.SUSPENDED_READY, pcb.MT.SUSPENDED_READY,
-		pcb.ME.EXIT, pcb.MT.EXIT))
+		pcb.ME.EXIT, pcb.MT.EXIT, pcb.MotivoSalida),
+		"pid", pcb.PID, "motivo", string(pcb.MotivoSalida)) // log obligatorio
 	slog.Debug(fmt.Sprintf("\nEstimado Anterior: %f, Estimado Actual: %f",
 		pcb.EstimadoAnterior, pcb.EstimadoActual))
 }
@@ -1756,7 +1937,7 @@ func mandarProcesoAIO(instancia *InstanciaIO, dispositivoIO *DispositivoIO) {
 	//ipIO := instancia.IP
 	//puertoIO := instancia.Puerto
 
-	for instancia.EstaConectada {
+	for instanciaConectada(instancia) {
 		//time.Sleep(1 * time.Second) // espera 1 segundo antes de verificar la cola nuevamente
 		slog.Debug(fmt.Sprintf("La IO esta Conectada, estaDisponible: %v", instancia.EstaDisponible))
 		_, ok := <-instancia.EstaDisponible
@@ -1768,12 +1949,16 @@ func mandarProcesoAIO(instancia *InstanciaIO, dispositivoIO *DispositivoIO) {
 		slog.Debug("La IO esta Disponible")
 		//slog.Debug(fmt.Sprintf("antes del channel"))
 		<-dispositivoIO.procesosEsperandoIO
+		if !instanciaConectada(instancia) {
+			// la instancia se cayo mientras esperaba: la señal es de otra instancia del dispositivo
+			dispositivoIO.procesosEsperandoIO <- 1
+			break
+		}
 		//slog.Debug(fmt.Sprintf("despues del channel"))
 		slog.Debug(fmt.Sprintf("## Dispositivo IO %s revisando cola %v", dispositivoIO.Nombre, (*cola)))
 		dispositivoIO.MutexCola.Lock()
 		if len(*cola) > 0 {
-			proceso := (*cola)[0]
-			(*cola) = (*cola)[1:]
+			proceso := sacarSiguientePeticionIO(dispositivoIO)
 			dispositivoIO.MutexCola.Unlock()
 			/*
 				if !instancia.EstaConectada {
@@ -1796,80 +1981,234 @@ func mandarProcesoAIO(instancia *InstanciaIO, dispositivoIO *DispositivoIO) {
 					break
 				}*/
 
+			// EstaDisponible no se devuelve hasta que termine, se cancele o no se pueda mandar, asi la
+			// instancia atiende de a una peticion y EnCurso es siempre la que se esta ejecutando
+			mutexInstanciasIO.Lock()
+			instancia.Enviada = proceso
+			mutexInstanciasIO.Unlock()
+
 			// Usar puntero a instancia para modificar el mismo valor compartido
 			peticionEnviada := EnviarPeticionIO(proceso.PCB, instancia.IP, instancia.Puerto, proceso.Tiempo)
 
 			slog.Debug(fmt.Sprintf("## valor de peticion enviada: %t", peticionEnviada))
 
 			if !peticionEnviada {
+				mutexInstanciasIO.Lock()
+				instancia.Enviada = nil
+				mutexInstanciasIO.Unlock()
+				mutexDispositivosIO.Lock()
+				devolverDisponibilidad(instancia)
+				mutexDispositivosIO.Unlock()
+
 				slog.Debug(fmt.Sprintf("## Error al enviar la peticion de IO al dispositivo %s", dispositivoIO.Nombre))
-				if len(dispositivoIO.Instancias) > 0 {
-					dispositivoIO.MutexCola.Lock()
-					(*cola) = append([]*ProcesoEsperandoIO{proceso}, (*cola)...)
-					dispositivoIO.MutexCola.Unlock()
-				} else {
-					colaDelProceso := BuscarColaPorPID(proceso.PCB.PID)
-					FinalizarProceso(proceso.PCB.PID, colaDelProceso)
-				}
-				//FinalizarProceso(proceso.PCB.PID, ColaBlocked)
+				reencolarPeticionIO(proceso, dispositivoIO.Nombre)
+				continue
 			}
-			// Solo marcar como disponible si la petición fue exitosa y la instancia sigue conectada
-			if instancia.EstaConectada {
-				select {
-				case instancia.EstaDisponible <- 1:
-					// Canal no cerrado, instancia marcada como disponible
-				default:
-					// Canal cerrado o lleno, la instancia ya no está disponible
-					break
-				}
+
+			mutexInstanciasIO.Lock()
+			if instancia.Enviada == proceso { // si el fin de IO llego antes que la respuesta, ya se libero
+				instancia.EnCurso = proceso
+				instancia.Enviada = nil
 			}
+			mutexInstanciasIO.Unlock()
 
+			// si la instancia se dio de baja mientras se mandaba, darDeBajaInstanciaCaida pudo no ver la peticion
+			if !instanciaConectada(instancia) && tomarPeticionEnCurso(instancia, proceso) {
+				reencolarPeticionIO(proceso, dispositivoIO.Nombre)
+			}
 		} else {
 			dispositivoIO.MutexCola.Unlock()
+			// la señal era de una peticion que ya no esta (se cancelo), la instancia sigue libre
+			mutexDispositivosIO.Lock()
+			devolverDisponibilidad(instancia)
+			mutexDispositivosIO.Unlock()
+		}
+	}
+}
+
+// EstaConectada la cambia DesconectarInstancia con mutexDispositivosIO tomado
+func instanciaConectada(instancia *InstanciaIO) bool {
+	mutexDispositivosIO.Lock()
+	defer mutexDispositivosIO.Unlock()
+	return instancia.EstaConectada
+}
+
+// Devuelve el token de EstaDisponible de una instancia que quedo libre. El llamador tiene que tener
+// tomado mutexDispositivosIO, que protege EstaConectada y el cierre del canal en DesconectarInstancia
+func devolverDisponibilidad(instancia *InstanciaIO) {
+	if !instancia.EstaConectada {
+		return
+	}
+	select {
+	case instancia.EstaDisponible <- 1:
+	default:
+	}
+}
+
+// Saca la peticion de EnCurso si sigue ahi; devuelve false si otro ya la tomo
+func tomarPeticionEnCurso(instancia *InstanciaIO, peticion *ProcesoEsperandoIO) bool {
+	mutexInstanciasIO.Lock()
+	defer mutexInstanciasIO.Unlock()
+	if instancia.EnCurso != peticion {
+		return false
+	}
+	instancia.EnCurso = nil
+	return true
+}
+
+// Vuelve a poner al frente de la cola una peticion que una instancia no llego a atender, o pasa el
+// proceso a EXIT si no quedan instancias del dispositivo
+func reencolarPeticionIO(peticion *ProcesoEsperandoIO, nombreDispositivo string) {
+	dispositivo := buscarDispositivoIO(nombreDispositivo)
+	if dispositivo != nil {
+		dispositivo.MutexCola.Lock()
+		dispositivo.Cola = append([]*ProcesoEsperandoIO{peticion}, dispositivo.Cola...)
+		dispositivo.MutexCola.Unlock()
+		dispositivo.procesosEsperandoIO <- 1 // la peticion vuelve a estar pendiente
+		slog.Info(fmt.Sprintf("## (%d) - IO reencolada en el dispositivo %s", peticion.PCB.PID, nombreDispositivo))
+		return
+	}
+
+	slog.Info(fmt.Sprintf("## (%d) - No quedan instancias del dispositivo %s, el proceso pasa a EXIT", peticion.PCB.PID, nombreDispositivo))
+	FinalizarProceso(peticion.PCB.PID, BuscarColaPorPID(peticion.PCB.PID), SalidaDispositivoIOCaido)
+}
+
+// Saca de la cola del dispositivo la proxima peticion segun su politica.
+// El llamador tiene que tener tomado el MutexCola del dispositivo.
+func sacarSiguientePeticionIO(dispositivoIO *DispositivoIO) *ProcesoEsperandoIO {
+	cola := dispositivoIO.Cola
+	indice := 0
+
+	switch dispositivoIO.Politica {
+	case "SRF":
+		for i, p := range cola {
+			if p.Tiempo < cola[indice].Tiempo {
+				indice = i
+			}
+		}
+	case "PRIORIDAD":
+		for i, p := range cola {
+			if p.PCB.Prioridad < cola[indice].PCB.Prioridad {
+				indice = i
+			}
+		}
+	}
+	// ante empates se respeta el orden de llegada
+
+	proceso := cola[indice]
+	dispositivoIO.Cola = append(cola[:indice], cola[indice+1:]...)
+	slog.Debug(fmt.Sprintf("## (%d) - Elegido por %s en la cola del dispositivo IO %s", proceso.PCB.PID, dispositivoIO.Politica, dispositivoIO.Nombre))
+	return proceso
+}
+
+// Devuelve la politica de cola y el largo maximo configurados para un dispositivo
+func configuracionDispositivoIO(nombre string) (string, int) {
+	politica := "FIFO"
+	maxCola := 0
+	if configDispositivo, ok := ClientConfig.IO_DEVICES[nombre]; ok {
+		if configDispositivo.QUEUE_POLICY != "" {
+			politica = configDispositivo.QUEUE_POLICY
 		}
+		maxCola = configDispositivo.MAX_QUEUE_LENGTH
 	}
+	return politica, maxCola
+}
+
+var politicasColaIO = []string{"FIFO", "SRF", "PRIORIDAD"}
+
+// Revisa la configuracion por dispositivo de io_devices: una politica mal escrita se atenderia como FIFO sin avisar
+func validarDispositivosIO(dispositivos map[string]ConfigDispositivoIO) error {
+	for nombre, dispositivo := range dispositivos {
+		if dispositivo.QUEUE_POLICY != "" && !slices.Contains(politicasColaIO, dispositivo.QUEUE_POLICY) {
+			return fmt.Errorf("io_devices.%s.queue_policy=%q invalido, se espera uno de: %s",
+				nombre, dispositivo.QUEUE_POLICY, strings.Join(politicasColaIO, ", "))
+		}
+		if dispositivo.MAX_QUEUE_LENGTH < 0 {
+			return fmt.Errorf("io_devices.%s.max_queue_length=%d invalido, tiene que ser mayor o igual a 0",
+				nombre, dispositivo.MAX_QUEUE_LENGTH)
+		}
+	}
+	return nil
 }
 
 func IO(w http.ResponseWriter, r *http.Request) {
 	paquete := globales.SolicitudIO{}
 	paquete = globales.DecodificarPaquete(w, r, &paquete)
 
-	go SolicitarIO(paquete.PID, paquete.PC, paquete.NOMBRE, paquete.TIEMPO)
+	go SolicitarIO(paquete.PID, paquete.PC, paquete.REGISTROS, paquete.NOMBRE, paquete.TIEMPO)
 
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("ok"))
 	slog.Debug("RESPUESTA ESCRITA EN IO")
 }
 
-func SolicitarIO(PID int, PC int, nombreIO string, tiempo int) {
-	var dispositivoEncontrado bool = false
-	var ioDevice *DispositivoIO
-
-	slog.Info(fmt.Sprintf("## (%d) - Solicitó syscall - IO", PID)) // log obligatorio
-
-	slog.Debug(fmt.Sprintf("Recibido solicitud de syscall IO: %s", nombreIO))
-
-	//Buscar el dispositivo por nombre
+// Busca un dispositivo conectado por nombre, devuelve nil si no existe o no tiene instancias
+func buscarDispositivoIO(nombreIO string) *DispositivoIO {
 	mutexDispositivosIO.Lock()
+	defer mutexDispositivosIO.Unlock()
 	for _, dispositivo := range DispositivosIO {
-		if dispositivo.Nombre == nombreIO {
-			ioDevice = dispositivo
-			dispositivoEncontrado = true
-			//mutexDispositivosIO.Unlock()
-			break
+		if dispositivo.Nombre == nombreIO && len(dispositivo.Instancias) > 0 {
+			return dispositivo
 		}
 	}
-	mutexDispositivosIO.Unlock()
+	return nil
+}
+
+// Reserva un lugar en la cola del dispositivo, o devuelve false si esta llena. El control y la reserva van
+// en la misma seccion critica para que dos syscalls a la vez no pasen de MaxCola; la reserva la consume
+// encolarPeticionIO o la devuelve liberarLugarIO si la peticion no se llega a encolar
+func reservarLugarIO(ioDevice *DispositivoIO) bool {
+	ioDevice.MutexCola.Lock()
+	defer ioDevice.MutexCola.Unlock()
+	if ioDevice.MaxCola > 0 && len(ioDevice.Cola)+ioDevice.reservas >= ioDevice.MaxCola {
+		return false
+	}
+	ioDevice.reservas++
+	return true
+}
+
+func liberarLugarIO(ioDevice *DispositivoIO) {
+	ioDevice.MutexCola.Lock()
+	ioDevice.reservas--
+	ioDevice.MutexCola.Unlock()
+}
+
+// Encola una peticion que ya tiene su lugar reservado con reservarLugarIO
+func encolarPeticionIO(ioDevice *DispositivoIO, procesoEsperandoIO *ProcesoEsperandoIO) {
+	ioDevice.MutexCola.Lock()
+	ioDevice.reservas--
+	ioDevice.Cola = append(ioDevice.Cola, procesoEsperandoIO) // Agregar el proceso a la cola del dispositivo IO
+	ioDevice.MutexCola.Unlock()
+	slog.Debug(fmt.Sprintf("## (%d) - Agregado a la cola del dispositivo IO %s", procesoEsperandoIO.PCB.PID, ioDevice.Nombre))
+	slog.Debug(fmt.Sprintf("## Cola del dispositivo IO %s: %+v", ioDevice.Nombre, ioDevice.Cola))
+
+	ioDevice.procesosEsperandoIO <- 1
+	slog.Debug("despues del channel procesos esperando io")
+}
+
+func SolicitarIO(PID int, PC int, registros globales.Registros, nombreIO string, tiempo int) {
+	slog.Info(fmt.Sprintf("## (%d) - Solicitó syscall - IO", PID), "pid", PID, "syscall", "IO") // log obligatorio
+
+	slog.Debug(fmt.Sprintf("Recibido solicitud de syscall IO: %s", nombreIO))
+
 	// Verficar si el DispositivoIO existe
-	if !dispositivoEncontrado || len(ioDevice.Instancias) == 0 {
+	ioDevice := buscarDispositivoIO(nombreIO)
+	if ioDevice == nil {
 		slog.Error(fmt.Sprintf("No encuentro el dispositivo IO %s", nombreIO))
-		FinalizarProceso(PID, ColaRunning)
+		FinalizarProceso(PID, ColaRunning, SalidaDispositivoInexistente)
+		return
+	}
+
+	if !reservarLugarIO(ioDevice) {
+		slog.Error(fmt.Sprintf("## (%d) - Cola del dispositivo IO %s llena (%d peticiones), se rechaza la peticion", PID, nombreIO, ioDevice.MaxCola))
+		FinalizarProceso(PID, ColaRunning, SalidaColaIOLlena)
 		return
 	}
 
 	pcbABloquear, err := buscarPCBYSacarDeCola(PID, ColaRunning)
 	if err != nil {
 		slog.Error(fmt.Sprintf("No se encontró el PCB del PID %d a bloquear en la cola", PID))
+		liberarLugarIO(ioDevice)
 		return
 	}
 
@@ -1878,26 +2217,169 @@ func SolicitarIO(PID int, PC int, nombreIO string, tiempo int) {
 	PasarAEstadoBlocked(pcbABloquear)
 	slog.Debug("Despues de bloquear el pcb")
 
-	slog.Info(fmt.Sprintf("## (%d) - Bloqueado por IO: %s", pcbABloquear.PID, nombreIO)) // log obligatorio
-	slog.Info(fmt.Sprintf("## (%d) Pasa del estado RUNNING al estado BLOCKED", pcbABloquear.PID))
+	slog.Info(fmt.Sprintf("## (%d) - Bloqueado por IO: %s", pcbABloquear.PID, nombreIO), "pid", pcbABloquear.PID, "io", nombreIO) // log obligatorio
+	slog.Info(fmt.Sprintf("## (%d) Pasa del estado RUNNING al estado BLOCKED", pcbABloquear.PID), "pid", pcbABloquear.PID, "state_from", "RUNNING", "state", "BLOCKED") // log obligatorio
 
 	(*pcbABloquear).PC = PC
+	(*pcbABloquear).Registros = registros
 
 	procesoEsperandoIO := ProcesoEsperandoIO{
 		PCB:    pcbABloquear,
 		Tiempo: tiempo,
 	}
+	encolarPeticionIO(ioDevice, &procesoEsperandoIO)
+}
 
-	ioDevice.MutexCola.Lock()
-	ioDevice.Cola = append(ioDevice.Cola, &procesoEsperandoIO) // Agregar el proceso a la cola del dispositivo IO
-	ioDevice.MutexCola.Unlock()
-	slog.Debug(fmt.Sprintf("## (%d) - Agregado a la cola del dispositivo IO %s", pcbABloquear.PID, nombreIO))
-	slog.Debug(fmt.Sprintf("## Cola del dispositivo IO %s: %+v", nombreIO, ioDevice.Cola))
+// syscall IO_ASYNC: se encola la IO pero el proceso sigue en RUNNING.
+// La respuesta es sincronica para que la CPU sepa si puede seguir ejecutando.
+func IOAsincrona(w http.ResponseWriter, r *http.Request) {
+	paquete := globales.SolicitudIO{}
+	paquete = globales.DecodificarPaquete(w, r, &paquete)
 
-	slog.Debug("despues del MutexCola")
-	ioDevice.procesosEsperandoIO <- 1
-	slog.Debug("despues del channel procesos esperando io")
+	slog.Info(fmt.Sprintf("## (%d) - Solicitó syscall - IO_ASYNC", paquete.PID), "pid", paquete.PID, "syscall", "IO_ASYNC") // log obligatorio
+
+	ioDevice := buscarDispositivoIO(paquete.NOMBRE)
+	if ioDevice == nil || !reservarLugarIO(ioDevice) {
+		slog.Error(fmt.Sprintf("## (%d) - No se puede atender la IO asincrona en el dispositivo %s", paquete.PID, paquete.NOMBRE))
+		motivo := SalidaColaIOLlena
+		if ioDevice == nil {
+			motivo = SalidaDispositivoInexistente
+		}
+		go FinalizarProceso(paquete.PID, ColaRunning, motivo)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("dispositivo no disponible"))
+		return
+	}
+
+	pcb, err := buscarPCBEnCola(paquete.PID, ColaRunning)
+	if err != nil {
+		slog.Error(fmt.Sprintf("No se encontró el PCB del PID %d en RUNNING", paquete.PID))
+		liberarLugarIO(ioDevice)
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("pcb no encontrado"))
+		return
+	}
+
+	mutexHandlesIO.Lock()
+	_, handleEnUso := pcb.HandlesIO[paquete.HANDLE]
+	if !handleEnUso {
+		pcb.HandlesIO[paquete.HANDLE] = false
+	}
+	mutexHandlesIO.Unlock()
+
+	if handleEnUso {
+		slog.Error(fmt.Sprintf("## (%d) - El handle %d ya esta en uso", paquete.PID, paquete.HANDLE))
+		liberarLugarIO(ioDevice)
+		go FinalizarProceso(paquete.PID, ColaRunning, SalidaHandleIOInvalido)
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte("handle en uso"))
+		return
+	}
+
+	encolarPeticionIO(ioDevice, &ProcesoEsperandoIO{
+		PCB:       pcb,
+		Tiempo:    paquete.TIEMPO,
+		Asincrona: true,
+		Handle:    paquete.HANDLE,
+	})
+	slog.Debug(fmt.Sprintf("## (%d) - IO asincrona en %s - Handle: %d", paquete.PID, paquete.NOMBRE, paquete.HANDLE))
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
+// syscall IO_WAIT: si la IO del handle ya termino el proceso sigue ejecutando, sino se bloquea hasta que termine
+func EsperarIO(w http.ResponseWriter, r *http.Request) {
+	paquete := globales.SolicitudEsperaIO{}
+	paquete = globales.DecodificarPaquete(w, r, &paquete)
+
+	slog.Info(fmt.Sprintf("## (%d) - Solicitó syscall - IO_WAIT", paquete.PID), "pid", paquete.PID, "syscall", "IO_WAIT") // log obligatorio
+
+	pcb, err := buscarPCBEnCola(paquete.PID, ColaRunning)
+	if err != nil {
+		slog.Error(fmt.Sprintf("No se encontró el PCB del PID %d en RUNNING", paquete.PID))
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("pcb no encontrado"))
+		return
+	}
+
+	// el lock se mantiene hasta que el proceso queda en BLOCKED para que el fin de IO no se pierda
+	mutexHandlesIO.Lock()
+	completada, existe := pcb.HandlesIO[paquete.HANDLE]
+	if !existe {
+		mutexHandlesIO.Unlock()
+		slog.Error(fmt.Sprintf("## (%d) - IO_WAIT de un handle inexistente: %d", paquete.PID, paquete.HANDLE))
+		go FinalizarProceso(paquete.PID, ColaRunning, SalidaHandleIOInvalido)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("handle inexistente"))
+		return
+	}
+	if completada {
+		delete(pcb.HandlesIO, paquete.HANDLE)
+		mutexHandlesIO.Unlock()
+		slog.Debug(fmt.Sprintf("## (%d) - IO_WAIT handle %d ya completado, sigue ejecutando", paquete.PID, paquete.HANDLE))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("completada"))
+		return
+	}
+
+	pcbABloquear, err := buscarPCBYSacarDeCola(paquete.PID, ColaRunning)
+	if err != nil {
+		mutexHandlesIO.Unlock()
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("pcb no encontrado"))
+		return
+	}
+	pcbABloquear.EsperandoHandle = paquete.HANDLE
+	pcbABloquear.PC = paquete.PC
+	pcbABloquear.Registros = paquete.REGISTROS
+	recalcularEstimados(pcbABloquear)
+	PasarAEstadoBlocked(pcbABloquear)
+	mutexHandlesIO.Unlock()
+
+	slog.Info(fmt.Sprintf("## (%d) - Bloqueado por IO_WAIT - Handle: %d", paquete.PID, paquete.HANDLE))
+	slog.Info(fmt.Sprintf("## (%d) Pasa del estado RUNNING al estado BLOCKED", paquete.PID), "pid", paquete.PID, "state_from", "RUNNING", "state", "BLOCKED") // log obligatorio
 
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("bloqueado"))
+}
+
+// Marca como completada la IO asincrona y, si el proceso estaba bloqueado esperandola, lo desbloquea
+func completarIOAsincrona(pcb *PCB, handle int) {
+	mutexHandlesIO.Lock()
+	if _, existe := pcb.HandlesIO[handle]; !existe {
+		mutexHandlesIO.Unlock()
+		return
+	}
+	esperando := pcb.EsperandoHandle == handle
+	if esperando {
+		pcb.EsperandoHandle = -1
+		delete(pcb.HandlesIO, handle)
+	} else {
+		pcb.HandlesIO[handle] = true
+	}
+	mutexHandlesIO.Unlock()
+
+	slog.Info(fmt.Sprintf("## (%d) finalizó IO asincrona - Handle: %d", pcb.PID, handle))
+
+	if esperando {
+		desbloquearProcesoPorIO(pcb.PID)
+	}
+}
+
+func buscarPCBEnCola(pid int, cola *[]*PCB) (*PCB, error) {
+	mutex, err := mutexCorrespondiente(cola)
+	if err != nil {
+		return nil, err
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	for _, pcb := range *cola {
+		if pcb.PID == pid {
+			return pcb, nil
+		}
+	}
+	return nil, fmt.Errorf("no se ha encontrado el PCB")
 }
 
 // guarda los IO q se conectan
@@ -1912,12 +2394,18 @@ func AtenderHandshakeIO(w http.ResponseWriter, r *http.Request) {
 		Puerto:         paquete.Puerto,
 		EstaDisponible: make(chan int, 1),
 		EstaConectada:  true,
+		Estado:         "OK",
+		UltimoContacto: time.Now(),
 	}
 	instancia.EstaDisponible <- 1 // la instancia esta disponible al inicio
 
 	slog.Debug(fmt.Sprintf("Puntero de la instancia cuando se crea IO %p", instancia))
 
 	mutexDispositivosIO.Lock()
+	// si la instancia se habia caido y volvio, deja de figurar como CAIDA
+	instanciasIOCaidas[paquete.Nombre] = slices.DeleteFunc(instanciasIOCaidas[paquete.Nombre], func(caida *InstanciaIO) bool {
+		return caida.IP == paquete.IP && caida.Puerto == paquete.Puerto
+	})
 	for _, dispositivo := range DispositivosIO {
 		if dispositivo.Nombre == paquete.Nombre {
 			dispositivo.Instancias = append(dispositivo.Instancias, instancia)
@@ -1925,6 +2413,7 @@ func AtenderHandshakeIO(w http.ResponseWriter, r *http.Request) {
 			slog.Debug(fmt.Sprintf("Dispositivo IO registrado: %+v\n", paquete))
 
 			go mandarProcesoAIO(instancia, dispositivo) // mandar goroutine para atender el dispositivo IO
+			go monitorearInstanciaIO(instancia, paquete.Nombre)
 
 			w.WriteHeader(http.StatusOK)
 			w.Write([]byte("ok"))
@@ -1933,6 +2422,7 @@ func AtenderHandshakeIO(w http.ResponseWriter, r *http.Request) {
 	}
 	mutexDispositivosIO.Unlock()
 
+	politica, maxCola := configuracionDispositivoIO(paquete.Nombre)
 	dispositivoIO := &DispositivoIO{
 		Nombre:              paquete.Nombre,
 		Cola:                make([]*ProcesoEsperandoIO, 0),
@@ -1940,7 +2430,10 @@ func AtenderHandshakeIO(w http.ResponseWriter, r *http.Request) {
 		TengoInstancias:     true,
 		Instancias:          []*InstanciaIO{instancia},
 		procesosEsperandoIO: make(chan int, 60),
+		Politica:            politica,
+		MaxCola:             maxCola,
 	}
+	slog.Debug(fmt.Sprintf("Dispositivo IO %s - Politica de cola: %s - Maximo de cola: %d", paquete.Nombre, politica, maxCola))
 	///dispositivoIO.Instancias = append(dispositivoIO.Instancias, &instancia)
 
 	mutexDispositivosIO.Lock()
@@ -1950,6 +2443,7 @@ func AtenderHandshakeIO(w http.ResponseWriter, r *http.Request) {
 	slog.Debug(fmt.Sprintf("Dispositivo IO registrado: %+v\n", paquete))
 
 	go mandarProcesoAIO(instancia, dispositivoIO)
+	go monitorearInstanciaIO(instancia, paquete.Nombre)
 
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("ok"))
@@ -1963,9 +2457,12 @@ func EnviarPeticionIO(pcbABloquear *PCB, ipIO string, puertoIO int, tiempoIO int
 		Tiempo: tiempoIO,
 	}
 
-	resp, _ := globales.GenerarYEnviarPaquete(&peticion, ipIO, puertoIO, "/io/peticion") // mando la peticion al io
-
-	return resp.StatusCode == 200 // si la respuesta es 200, retorno true, sino false
+	// mando la peticion al io
+	if err := globales.Enviar(context.Background(), ipIO, puertoIO, "/io/peticion", &peticion); err != nil {
+		slog.Error(fmt.Sprintf("## (%d) - No se pudo enviar la peticion a la IO %s:%d: %v", pcbABloquear.PID, ipIO, puertoIO, err))
+		return false
+	}
+	return true
 }
 
 func AtenderFinIOPeticion(w http.ResponseWriter, r *http.Request) {
@@ -1979,7 +2476,7 @@ func AtenderFinIOPeticion(w http.ResponseWriter, r *http.Request) {
 	ip := paquete.IP
 	puerto := paquete.Puerto
 
-	slog.Debug(fmt.Sprintf("IO VOLVIO PORQUE: %s", paquete.Motivo)) // log obligatorio
+	slog.Debug(fmt.Sprintf("IO VOLVIO PORQUE: %s", paquete.Motivo), "pid", paquete.PID, "io", paquete.Nombre_Dispositivo) // log obligatorio
 
 	if paquete.Motivo == "Desconexion" {
 		DesconectarInstancia(paquete)
@@ -1989,9 +2486,39 @@ func AtenderFinIOPeticion(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("ok"))
 		return
 	}
-	slog.Info(fmt.Sprintf("## (%d) finalizó IO y pasa a READY", paquete.PID)) // log obligatorio
+	peticionIO, instanciaRegistrada := liberarInstanciaIO(ip, puerto, nombreIO, pidFinIO)
+	if !instanciaRegistrada {
+		// la instancia se dio por caida y su peticion ya se reencolo o se finalizo
+		slog.Warn(fmt.Sprintf("## (%d) - Fin de IO de la instancia %s:%d que ya no esta registrada, se ignora", pidFinIO, ip, puerto))
+		w.WriteHeader(http.StatusGone)
+		w.Write([]byte("instancia no registrada"))
+		return
+	}
+
+	if peticionIO != nil && peticionIO.Asincrona {
+		// IO_ASYNC: el proceso no estaba bloqueado por esta IO (salvo que haya hecho IO_WAIT)
+		completarIOAsincrona(peticionIO.PCB, peticionIO.Handle)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+		return
+	}
+
+	slog.Info(fmt.Sprintf("## (%d) finalizó IO y pasa a READY", paquete.PID), "pid", paquete.PID, "state", "READY") // log obligatorio
 	// Motivo = "Finalizo IO"
 
+	if !desbloquearProcesoPorIO(pidFinIO) {
+		slog.Error(fmt.Sprintf("No se encontró el PCB del PID %d en las colas blocked/suspended_Blocked", pidFinIO))
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("pcb no encontrado"))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
+// Pasa a READY (o SUSPENDED_READY) un proceso que estaba bloqueado esperando una IO
+func desbloquearProcesoPorIO(pidFinIO int) bool {
 	for _, p := range *ProcesosSiendoSwapeados {
 		if p.PID == pidFinIO {
 			slog.Debug(fmt.Sprintf("## (%d) - Eliminado de la lista de procesos en swap", p.PID))
@@ -2005,9 +2532,7 @@ func AtenderFinIOPeticion(w http.ResponseWriter, r *http.Request) {
 	pcb, err := buscarPCBYSacarDeCola(pidFinIO, ColaBlocked)
 
 	if err == nil {
-		go liberarInstanciaIO(ip, puerto, nombreIO)
-
-		pudoDesalojar, cpu := intentarDesalojo(pcb)
+		pudoDesalojar, cpu := intentarDesalojo(pcb, globales.InterrupcionFinIO)
 		if pudoDesalojar {
 			ReinsertarEnFrenteCola(ColaReady, pcb)
 			actualizarMetricasEstado(pcb, "READY")
@@ -2025,47 +2550,107 @@ func AtenderFinIOPeticion(w http.ResponseWriter, r *http.Request) {
 		}
 		ProcesosEnReady <- 1
 
-		slog.Info(fmt.Sprintf("## (%d) Pasa del estado BLOCKED al estado READY", pcb.PID))
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("ok"))
+		slog.Info(fmt.Sprintf("## (%d) Pasa del estado BLOCKED al estado READY", pcb.PID), "pid", pcb.PID, "state_from", "BLOCKED", "state", "READY") // log obligatorio
+		return true
+	}
 
-		return
-	} else {
-		pcb, err := buscarPCBYSacarDeCola(pidFinIO, ColaSuspendedBlocked)
-		if err == nil {
-			go liberarInstanciaIO(ip, puerto, nombreIO)
-			AgregarPCBaCola(pcb, ColaSuspendedReady)
-			ordenarColaSuspendedReady()
-			slog.Info(fmt.Sprintf("## (%d) Pasa del estado SUSPENDED_BLOCKED al estado SUSPENDED_READY", pcb.PID))
+	pcb, err = buscarPCBYSacarDeCola(pidFinIO, ColaSuspendedBlocked)
+	if err == nil {
+		AgregarPCBaCola(pcb, ColaSuspendedReady)
+		ordenarColaSuspendedReady()
+		slog.Info(fmt.Sprintf("## (%d) Pasa del estado SUSPENDED_BLOCKED al estado SUSPENDED_READY", pcb.PID), "pid", pcb.PID, "state_from", "SUSPENDED_BLOCKED", "state", "SUSPENDED_READY") // log obligatorio
+		return true
+	}
+	return false
+}
 
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte("ok"))
-			return
-		} else {
-			go liberarInstanciaIO(ip, puerto, nombreIO)
+// Saca al proceso de las colas de los dispositivos y corta la IO que tenga en curso,
+// dejando la instancia disponible en el momento.
+func cancelarPeticionesIO(pid int) {
+	var instanciasACancelar []*InstanciaIO
 
-			slog.Error(fmt.Sprintf("No se encontró el PCB del PID %d en las colas blocked/suspended_Blocked", pidFinIO))
-			w.WriteHeader(http.StatusNotFound)
-			w.Write([]byte("pcb no encontrado"))
-			return
+	mutexDispositivosIO.Lock()
+	for _, dispositivo := range DispositivosIO {
+		dispositivo.MutexCola.Lock()
+		colaFiltrada := make([]*ProcesoEsperandoIO, 0, len(dispositivo.Cola))
+		for _, proceso := range dispositivo.Cola {
+			if proceso.PCB.PID == pid {
+				// consumo la señal que habia dejado la peticion para que no quede una de mas
+				select {
+				case <-dispositivo.procesosEsperandoIO:
+				default:
+				}
+				slog.Debug(fmt.Sprintf("## (%d) - Eliminado de la cola del dispositivo IO %s", pid, dispositivo.Nombre))
+				continue
+			}
+			colaFiltrada = append(colaFiltrada, proceso)
 		}
+		dispositivo.Cola = colaFiltrada
+		dispositivo.MutexCola.Unlock()
+
+		mutexInstanciasIO.Lock()
+		for _, instancia := range dispositivo.Instancias {
+			if instancia.EstaConectada && instancia.EnCurso != nil && instancia.EnCurso.PCB.PID == pid {
+				instanciasACancelar = append(instanciasACancelar, instancia)
+			}
+		}
+		mutexInstanciasIO.Unlock()
 	}
+	mutexDispositivosIO.Unlock()
 
+	for _, instancia := range instanciasACancelar {
+		peticion := PeticionIO{
+			PID: pid,
+		}
+		if err := globales.Enviar(context.Background(), instancia.IP, instancia.Puerto, "/io/cancelar", &peticion); err != nil {
+			// la IO ya habia terminado, la instancia se libera cuando llegue el fin de IO
+			slog.Debug(fmt.Sprintf("## (%d) - No se pudo cancelar la IO en %s:%d: %v", pid, instancia.IP, instancia.Puerto, err))
+			continue
+		}
+
+		mutexInstanciasIO.Lock()
+		if instancia.EnCurso != nil && instancia.EnCurso.PCB.PID == pid {
+			instancia.EnCurso = nil
+		}
+		mutexInstanciasIO.Unlock()
+		mutexDispositivosIO.Lock()
+		devolverDisponibilidad(instancia)
+		mutexDispositivosIO.Unlock()
+		slog.Info(fmt.Sprintf("## (%d) - IO cancelada, instancia %s:%d liberada", pid, instancia.IP, instancia.Puerto))
+	}
 }
 
-func liberarInstanciaIO(ip string, puerto int, nombreDispositivo string) {
-	for i, dispositivo := range DispositivosIO {
-		if dispositivo.Nombre == nombreDispositivo {
-			for j, instancia := range dispositivo.Instancias {
-				if instancia.IP == ip && instancia.Puerto == puerto {
-					slog.Debug(fmt.Sprintf("Puntero de la instancia cuando finaliza IO %p", instancia))
-					DispositivosIO[i].Instancias[j].EstaDisponible <- 1 // la instancia vuelve a estar disponible
-					slog.Debug(fmt.Sprintf("Instancia %s:%d marcada como disponible", instancia.IP, instancia.Puerto))
-					break
+// Libera la instancia que aviso el fin de IO de pid y devuelve la peticion de ese pid que estaba
+// atendiendo (nil si no la encuentra) y si la instancia esta registrada
+func liberarInstanciaIO(ip string, puerto int, nombreDispositivo string, pid int) (*ProcesoEsperandoIO, bool) {
+	mutexDispositivosIO.Lock()
+	defer mutexDispositivosIO.Unlock()
+	for _, dispositivo := range DispositivosIO {
+		if dispositivo.Nombre != nombreDispositivo {
+			continue
+		}
+		for _, instancia := range dispositivo.Instancias {
+			if instancia.IP != ip || instancia.Puerto != puerto {
+				continue
+			}
+			slog.Debug(fmt.Sprintf("Puntero de la instancia cuando finaliza IO %p", instancia))
+			var peticion *ProcesoEsperandoIO
+			mutexInstanciasIO.Lock()
+			// la respuesta de /io/peticion puede llegar despues que el fin de IO, por eso tambien se mira Enviada
+			for _, candidata := range []*ProcesoEsperandoIO{instancia.EnCurso, instancia.Enviada} {
+				if candidata != nil && candidata.PCB.PID == pid {
+					peticion = candidata
 				}
 			}
+			instancia.EnCurso = nil
+			instancia.Enviada = nil
+			mutexInstanciasIO.Unlock()
+			devolverDisponibilidad(instancia) // la instancia vuelve a estar disponible
+			slog.Debug(fmt.Sprintf("Instancia %s:%d marcada como disponible", instancia.IP, instancia.Puerto))
+			return peticion, true
 		}
 	}
+	return nil, false
 }
 
 func DesconectarInstancia(instanciaADesconectar RespuestaIO) {
@@ -2169,4 +2754,308 @@ func DesconectarInstancia(instanciaADesconectar RespuestaIO) {
 	}
 	mutexDispositivosIO.Unlock()
 
-}
\ No newline at end of file
+}
+// --------- HEARTBEATS DE IO --------- //
+
+// Instancias dadas de baja por heartbeats, por nombre de dispositivo. Ya no atienden peticiones, se
+// guardan para mostrarlas como CAIDA en /admin/io hasta que vuelvan a hacer el handshake. Usa mutexDispositivosIO
+var instanciasIOCaidas = map[string][]*InstanciaIO{}
+
+// Manda heartbeats a la instancia mientras siga conectada. Si no responde
+// IO_HEARTBEAT_MAX_FAILURES veces seguidas se la da por caida.
+func monitorearInstanciaIO(instancia *InstanciaIO, nombreDispositivo string) {
+	if ClientConfig.IO_HEARTBEAT_INTERVAL <= 0 {
+		return
+	}
+	maxFallos := ClientConfig.IO_HEARTBEAT_MAX_FAILURES
+	if maxFallos <= 0 {
+		maxFallos = 3
+	}
+	intervalo := time.Duration(ClientConfig.IO_HEARTBEAT_INTERVAL) * time.Millisecond
+	ticker := time.NewTicker(intervalo)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if !instanciaConectada(instancia) {
+			return
+		}
+
+		respondio := enviarHeartbeat(intervalo, instancia.IP, instancia.Puerto, "/io/heartbeat")
+
+		mutexInstanciasIO.Lock()
+		estadoAnterior := instancia.Estado
+		if respondio {
+			instancia.Fallos = 0
+			instancia.Estado = "OK"
+			instancia.UltimoContacto = time.Now()
+		} else {
+			instancia.Fallos++
+			instancia.Estado = "SOSPECHOSA"
+			if instancia.Fallos >= maxFallos {
+				instancia.Estado = "CAIDA"
+			}
+		}
+		estado := instancia.Estado
+		fallos := instancia.Fallos
+		mutexInstanciasIO.Unlock()
+
+		if estado != estadoAnterior {
+			slog.Info(fmt.Sprintf("Instancia IO %s %s:%d - Estado: %s - Heartbeats fallidos: %d", nombreDispositivo, instancia.IP, instancia.Puerto, estado, fallos))
+		}
+		if estado == "CAIDA" {
+			darDeBajaInstanciaCaida(instancia, nombreDispositivo)
+			return
+		}
+	}
+}
+
+// Devuelve true si el modulo respondio el heartbeat con 200. El plazo es el intervalo
+// para que un modulo colgado cuente como fallo.
+func enviarHeartbeat(plazo time.Duration, ip string, puerto int, ruta string) bool {
+	ctx, cancelar := context.WithTimeout(context.Background(), plazo)
+	defer cancelar()
+	return globales.Enviar(ctx, ip, puerto, ruta, nil) == nil
+}
+
+// Saca del sistema una instancia que dejo de responder. La peticion que estaba
+// atendiendo vuelve al frente de la cola si quedan otras instancias del dispositivo,
+// sino el proceso pasa a EXIT.
+func darDeBajaInstanciaCaida(instancia *InstanciaIO, nombreDispositivo string) {
+	// PID -1 para que DesconectarInstancia no finalice el proceso en curso, lo resolvemos aca
+	DesconectarInstancia(RespuestaIO{
+		PID:                -1,
+		Motivo:             "Desconexion",
+		Nombre_Dispositivo: nombreDispositivo,
+		IP:                 instancia.IP,
+		Puerto:             instancia.Puerto,
+	})
+	slog.Warn(fmt.Sprintf("Instancia IO %s %s:%d dada de baja por no responder heartbeats", nombreDispositivo, instancia.IP, instancia.Puerto))
+
+	mutexDispositivosIO.Lock()
+	instanciasIOCaidas[nombreDispositivo] = append(instanciasIOCaidas[nombreDispositivo], instancia)
+	mutexDispositivosIO.Unlock()
+
+	// se toma despues de desconectarla: una peticion que la instancia acepte de aca en adelante la
+	// reencola mandarProcesoAIO, y la que todavia no acepto vuelve a la cola cuando falle el envio
+	mutexInstanciasIO.Lock()
+	peticion := instancia.EnCurso
+	mutexInstanciasIO.Unlock()
+	if peticion == nil || !tomarPeticionEnCurso(instancia, peticion) {
+		return
+	}
+	slog.Info(fmt.Sprintf("## (%d) - IO en curso en la instancia caida %s:%d", peticion.PCB.PID, instancia.IP, instancia.Puerto))
+	reencolarPeticionIO(peticion, nombreDispositivo)
+}
+
+// GET /admin/io: estado de salud de cada instancia de IO
+func AdminIO(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	estados := make([]EstadoDispositivoIO, 0)
+
+	mutexDispositivosIO.Lock()
+	listados := map[string]bool{}
+	for _, dispositivo := range DispositivosIO {
+		dispositivo.MutexCola.Lock()
+		estadoDispositivo := EstadoDispositivoIO{
+			Nombre:    dispositivo.Nombre,
+			Politica:  dispositivo.Politica,
+			Encolados: len(dispositivo.Cola),
+		}
+		dispositivo.MutexCola.Unlock()
+
+		estadoDispositivo.Instancias = estadosInstanciasIO(append(slices.Clone(dispositivo.Instancias), instanciasIOCaidas[dispositivo.Nombre]...))
+		estados = append(estados, estadoDispositivo)
+		listados[dispositivo.Nombre] = true
+	}
+	// dispositivos que se quedaron sin instancias vivas: ya no estan en DispositivosIO pero se muestran sus caidas
+	for _, nombre := range slices.Sorted(maps.Keys(instanciasIOCaidas)) {
+		caidas := instanciasIOCaidas[nombre]
+		if listados[nombre] || len(caidas) == 0 {
+			continue
+		}
+		politica, _ := configuracionDispositivoIO(nombre)
+		estados = append(estados, EstadoDispositivoIO{Nombre: nombre, Politica: politica, Instancias: estadosInstanciasIO(caidas)})
+	}
+	mutexDispositivosIO.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(estados)
+}
+
+func estadosInstanciasIO(instancias []*InstanciaIO) []EstadoInstanciaIO {
+	estados := make([]EstadoInstanciaIO, 0, len(instancias))
+	mutexInstanciasIO.Lock()
+	defer mutexInstanciasIO.Unlock()
+	for _, instancia := range instancias {
+		pidEnCurso := -1
+		if instancia.EnCurso != nil {
+			pidEnCurso = instancia.EnCurso.PCB.PID
+		}
+		estados = append(estados, EstadoInstanciaIO{
+			IP:             instancia.IP,
+			Puerto:         instancia.Puerto,
+			Estado:         instancia.Estado,
+			Fallos:         instancia.Fallos,
+			UltimoContacto: instancia.UltimoContacto.Format(time.RFC3339),
+			PIDEnCurso:     pidEnCurso,
+		})
+	}
+	return estados
+}
+
+// GET /admin/cpu: nucleos de CPU que hicieron el handshake y siguen conectados
+func AdminCPU(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	mutexConexionesCPU.Lock()
+	conexiones := append([]globales.HandshakeCPU{}, ConexionesCPU...)
+	mutexConexionesCPU.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(conexiones)
+}
+
+// Devuelve todos los procesos del sistema con su estado y, si terminaron, el motivo
+func AdminProcesos(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	procesos := make([]EstadoProceso, 0)
+
+	colas := []*[]*PCB{ColaNew, ColaReady, ColaRunning, ColaBlocked, ColaSuspendedBlocked, ColaSuspendedReady, ColaExit}
+	for _, cola := range colas {
+		mutex, _ := mutexCorrespondiente(cola)
+		mutex.Lock()
+		for _, pcb := range *cola {
+			procesos = append(procesos, EstadoProceso{
+				PID:          pcb.PID,
+				Estado:       obtenerEstadoDeCola(cola),
+				PC:           pcb.PC,
+				MotivoSalida: pcb.MotivoSalida,
+			})
+		}
+		mutex.Unlock()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(procesos)
+}
+
+// --------- HEARTBEATS DE CPU --------- //
+
+// Devuelve true si la conexion sigue registrada. Se compara el canal de disponibilidad
+// para que una CPU reconectada con el mismo ID no reviva goroutines de la conexion vieja.
+func cpuSigueConectada(cpu *globales.HandshakeCPU) bool {
+	mutexConexionesCPU.Lock()
+	defer mutexConexionesCPU.Unlock()
+	for _, conexion := range ConexionesCPU {
+		if conexion.ID_CPU == cpu.ID_CPU && conexion.DISPONIBLE == cpu.DISPONIBLE {
+			return true
+		}
+	}
+	return false
+}
+
+// Manda heartbeats a la CPU mientras siga conectada. Si no responde
+// CPU_HEARTBEAT_MAX_FAILURES veces seguidas se la da de baja.
+func monitorearCPU(cpu globales.HandshakeCPU) {
+	if ClientConfig.CPU_HEARTBEAT_INTERVAL <= 0 {
+		return
+	}
+	maxFallos := ClientConfig.CPU_HEARTBEAT_MAX_FAILURES
+	if maxFallos <= 0 {
+		maxFallos = 3
+	}
+	intervalo := time.Duration(ClientConfig.CPU_HEARTBEAT_INTERVAL) * time.Millisecond
+	ruta := fmt.Sprintf("/cpu/%s/heartbeat", cpu.ID_CPU)
+
+	ticker := time.NewTicker(intervalo)
+	defer ticker.Stop()
+
+	fallos := 0
+	for range ticker.C {
+		if !cpuSigueConectada(&cpu) {
+			return // se desconecto por /cpu/desconectar
+		}
+
+		if enviarHeartbeat(intervalo, cpu.IP_CPU, cpu.PORT_CPU, ruta) {
+			if fallos > 0 {
+				slog.Info(fmt.Sprintf("CPU %s volvio a responder heartbeats", cpu.ID_CPU))
+			}
+			fallos = 0
+			continue
+		}
+
+		fallos++
+		slog.Warn(fmt.Sprintf("CPU %s no respondio el heartbeat - Fallos: %d/%d", cpu.ID_CPU, fallos, maxFallos))
+		if fallos >= maxFallos {
+			darDeBajaCPUCaida(&cpu)
+			return
+		}
+	}
+}
+
+// Saca a la CPU de las conexiones y devuelve a READY el proceso que estaba ejecutando,
+// con el PC y los registros del ultimo cambio de contexto. Lo que ejecuto desde ahi se pierde.
+func darDeBajaCPUCaida(cpu *globales.HandshakeCPU) {
+	mutexConexionesCPU.Lock()
+	for i, conexion := range ConexionesCPU {
+		if conexion.ID_CPU == cpu.ID_CPU && conexion.DISPONIBLE == cpu.DISPONIBLE {
+			ConexionesCPU = append(ConexionesCPU[:i], ConexionesCPU[i+1:]...)
+			break
+		}
+	}
+	cpusCaidas[cpu.ID_CPU] = true
+	mutexConexionesCPU.Unlock()
+
+	mutexCPUporProceso.Lock()
+	pid, estabaEjecutando := CPUporProceso[cpu.ID_CPU]
+	delete(CPUporProceso, cpu.ID_CPU)
+	mutexCPUporProceso.Unlock()
+
+	mutexInterrupcionesCPU.Lock()
+	if cpupendienteInterrupcion[cpu.ID_CPU] {
+		select {
+		case <-InterrumpirCPU:
+		default:
+		}
+		delete(cpupendienteInterrupcion, cpu.ID_CPU)
+	}
+	mutexInterrupcionesCPU.Unlock()
+
+	slog.Warn(fmt.Sprintf("CPU %s dada de baja por no responder heartbeats", cpu.ID_CPU))
+
+	// despierto al planificador de esta CPU si estaba esperando que se libere
+	select {
+	case cpu.DISPONIBLE <- 1:
+	default:
+	}
+
+	if !estabaEjecutando || pid < 0 {
+		return
+	}
+
+	pcb, err := buscarPCBYSacarDeCola(pid, ColaRunning)
+	if err != nil {
+		slog.Debug(fmt.Sprintf("## (%d) - No estaba en RUNNING al caer la CPU %s", pid, cpu.ID_CPU))
+		return
+	}
+
+	mutexOrdenandoColaReady.Lock()
+	AgregarPCBaCola(pcb, ColaReady)
+	ordenarColaReady()
+	mutexOrdenandoColaReady.Unlock()
+	ProcesosEnReady <- 1
+
+	slog.Info(fmt.Sprintf("## (%d) Pasa del estado RUNNING al estado READY", pcb.PID), "pid", pcb.PID, "state_from", "RUNNING", "state", "READY") // log obligatorio
+	slog.Warn(fmt.Sprintf("## (%d) - Recuperado de la CPU caida %s - Vuelve al PC: %d, se pierden los registros y la syscall que tuviera en curso", pcb.PID, cpu.ID_CPU, pcb.PC), "pid", pcb.PID, "cpu_id", cpu.ID_CPU, "pc", pcb.PC)
+}
@@ -0,0 +1,710 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"globales"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Modulo IO de prueba: avisa cada peticion y cancelacion que le llega y, como el de verdad, atiende
+// de a una peticion. Con retener las peticiones quedan esperando en /io/peticion hasta soltar
+type ioFalsa struct {
+	peticiones    chan PeticionIO
+	cancelaciones chan PeticionIO
+	mutex         sync.Mutex
+	pidActual     int
+	retener       bool
+	soltar        chan bool // true acepta la peticion retenida, false corta la conexion
+	ip            string
+	puerto        int
+}
+
+func nuevaIOFalsa(t *testing.T) *ioFalsa {
+	t.Helper()
+	io := &ioFalsa{
+		peticiones:    make(chan PeticionIO, 10),
+		cancelaciones: make(chan PeticionIO, 10),
+		pidActual:     -1,
+		soltar:        make(chan bool),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/io/peticion", func(w http.ResponseWriter, r *http.Request) {
+		var peticion PeticionIO
+		json.NewDecoder(r.Body).Decode(&peticion)
+		io.peticiones <- peticion
+		io.mutex.Lock()
+		retener := io.retener
+		io.mutex.Unlock()
+		if retener && !<-io.soltar {
+			conexion, _, _ := w.(http.Hijacker).Hijack()
+			conexion.Close()
+			return
+		}
+		io.mutex.Lock()
+		io.pidActual = peticion.PID
+		io.mutex.Unlock()
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/io/cancelar", func(w http.ResponseWriter, r *http.Request) {
+		var peticion PeticionIO
+		json.NewDecoder(r.Body).Decode(&peticion)
+		io.cancelaciones <- peticion
+		io.mutex.Lock()
+		defer io.mutex.Unlock()
+		if io.pidActual != peticion.PID {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		io.pidActual = -1
+		w.Write([]byte("ok"))
+	})
+	servidor := httptest.NewServer(mux)
+	t.Cleanup(servidor.Close)
+	t.Cleanup(func() { close(io.soltar) }) // corre antes que servidor.Close, que espera a los handlers
+	ip, puerto, _ := net.SplitHostPort(servidor.Listener.Addr().String())
+	io.ip = ip
+	io.puerto, _ = strconv.Atoi(puerto)
+	return io
+}
+
+func (io *ioFalsa) retenerPeticiones() {
+	io.mutex.Lock()
+	io.retener = true
+	io.mutex.Unlock()
+}
+
+func esperarPeticion(t *testing.T, canal chan PeticionIO, pid int) {
+	t.Helper()
+	select {
+	case peticion := <-canal:
+		if peticion.PID != pid {
+			t.Fatalf("llego la peticion del PID %d, se esperaba la del %d", peticion.PID, pid)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("no llego la peticion del PID %d", pid)
+	}
+}
+
+func sinPeticiones(t *testing.T, canal chan PeticionIO) {
+	t.Helper()
+	select {
+	case peticion := <-canal:
+		t.Fatalf("llego la peticion del PID %d, no se esperaba ninguna", peticion.PID)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+var dispositivosPrueba = 0
+
+// Deja las colas vacias y el dispositivo como unico dispositivo IO del kernel. Cada prueba usa un nombre
+// distinto para que una peticion que quedo de otra prueba no se reencole en este
+func nuevoDispositivoPrueba(t *testing.T, politica string, maxCola int) *DispositivoIO {
+	t.Helper()
+	InicializarColas()
+	algoritmoColaReady = "FIFO"
+	dispositivosPrueba++
+	dispositivo := &DispositivoIO{
+		Nombre:              "DISCO" + strconv.Itoa(dispositivosPrueba),
+		Cola:                make([]*ProcesoEsperandoIO, 0),
+		MutexCola:           new(sync.Mutex),
+		TengoInstancias:     true,
+		procesosEsperandoIO: make(chan int, 60),
+		Politica:            politica,
+		MaxCola:             maxCola,
+	}
+	mutexDispositivosIO.Lock()
+	DispositivosIO = []*DispositivoIO{dispositivo}
+	instanciasIOCaidas = map[string][]*InstanciaIO{}
+	mutexDispositivosIO.Unlock()
+	t.Cleanup(func() {
+		// las instancias que quedaron dejan de pedir peticiones
+		mutexDispositivosIO.Lock()
+		for _, instancia := range dispositivo.Instancias {
+			instancia.EstaConectada = false
+			close(instancia.EstaDisponible)
+		}
+		DispositivosIO = nil
+		mutexDispositivosIO.Unlock()
+	})
+	return dispositivo
+}
+
+// Conecta una instancia del dispositivo que atiende la IO de prueba, como AtenderHandshakeIO
+func conectarInstancia(dispositivo *DispositivoIO, io *ioFalsa) *InstanciaIO {
+	instancia := &InstanciaIO{
+		IP:             io.ip,
+		Puerto:         io.puerto,
+		EstaDisponible: make(chan int, 1),
+		EstaConectada:  true,
+		Estado:         "OK",
+	}
+	instancia.EstaDisponible <- 1
+	mutexDispositivosIO.Lock()
+	dispositivo.Instancias = append(dispositivo.Instancias, instancia)
+	mutexDispositivosIO.Unlock()
+	go mandarProcesoAIO(instancia, dispositivo)
+	return instancia
+}
+
+func pcbPrueba(pid int, prioridad int) *PCB {
+	pcb := &PCB{PID: pid, Prioridad: prioridad, HandlesIO: map[int]bool{}, EsperandoHandle: -1, EstaEnSwap: make(chan int, 1)}
+	pcb.EstaEnSwap <- 1
+	return pcb
+}
+
+func encolarPrueba(t *testing.T, dispositivo *DispositivoIO, peticion *ProcesoEsperandoIO) {
+	t.Helper()
+	if !reservarLugarIO(dispositivo) {
+		t.Fatalf("cola llena al encolar el PID %d", peticion.PCB.PID)
+	}
+	encolarPeticionIO(dispositivo, peticion)
+}
+
+// EnCurso se asigna cuando vuelve la respuesta de /io/peticion, un poco despues de que llegue la peticion
+func esperarEnCurso(t *testing.T, instancia *InstanciaIO, pid int) {
+	t.Helper()
+	limite := time.Now().Add(2 * time.Second)
+	for {
+		mutexInstanciasIO.Lock()
+		enCurso := -1
+		if instancia.EnCurso != nil {
+			enCurso = instancia.EnCurso.PCB.PID
+		}
+		mutexInstanciasIO.Unlock()
+		if enCurso == pid {
+			return
+		}
+		if time.Now().After(limite) {
+			t.Fatalf("la instancia tiene en curso el PID %d, se esperaba el %d", enCurso, pid)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestCancelarPeticionesIO(t *testing.T) {
+	dispositivo := nuevoDispositivoPrueba(t, "FIFO", 0)
+	io := nuevaIOFalsa(t)
+	instancia := conectarInstancia(dispositivo, io)
+
+	primera, segunda, tercera := pcbPrueba(1, 0), pcbPrueba(2, 0), pcbPrueba(3, 0)
+	encolarPrueba(t, dispositivo, &ProcesoEsperandoIO{PCB: primera, Tiempo: 1000})
+	esperarPeticion(t, io.peticiones, 1)
+	esperarEnCurso(t, instancia, 1)
+	encolarPrueba(t, dispositivo, &ProcesoEsperandoIO{PCB: segunda, Tiempo: 1000})
+	encolarPrueba(t, dispositivo, &ProcesoEsperandoIO{PCB: tercera, Tiempo: 1000})
+
+	// la instancia esta ocupada: las demas esperan en la cola del kernel y no en /io/peticion
+	sinPeticiones(t, io.peticiones)
+
+	// una peticion encolada se saca de la cola sin molestar a la IO
+	cancelarPeticionesIO(3)
+	sinPeticiones(t, io.cancelaciones)
+	dispositivo.MutexCola.Lock()
+	if len(dispositivo.Cola) != 1 || dispositivo.Cola[0].PCB.PID != 2 {
+		t.Errorf("cola = %v, se esperaba solo el PID 2", dispositivo.Cola)
+	}
+	dispositivo.MutexCola.Unlock()
+
+	// la que esta en curso se cancela en la instancia que la atiende, que queda libre para la siguiente
+	cancelarPeticionesIO(1)
+	esperarPeticion(t, io.cancelaciones, 1)
+	esperarPeticion(t, io.peticiones, 2)
+	esperarEnCurso(t, instancia, 2)
+	sinPeticiones(t, io.peticiones)
+}
+
+// Manda a AtenderFinIOPeticion el fin de IO de pid que avisaria la instancia
+func finDeIO(t *testing.T, dispositivo *DispositivoIO, io *ioFalsa, pid int) int {
+	t.Helper()
+	cuerpo, _ := json.Marshal(RespuestaIO{PID: pid, Motivo: "Finalizo IO", Nombre_Dispositivo: dispositivo.Nombre, IP: io.ip, Puerto: io.puerto})
+	respuesta := httptest.NewRecorder()
+	AtenderFinIOPeticion(respuesta, httptest.NewRequest(http.MethodPost, "/io/finalizado", bytes.NewReader(cuerpo)))
+	return respuesta.Code
+}
+
+func enCola(pid int, cola *[]*PCB) bool {
+	_, err := buscarPCBEnCola(pid, cola)
+	return err == nil
+}
+
+func TestFinDeIOCompletaLaPeticionDelPID(t *testing.T) {
+	casos := []struct {
+		nombre           string
+		primeraAsincrona bool
+		segundaAsincrona bool
+	}{
+		{"sincronica con una asincronica detras", false, true},
+		{"asincronica con una sincronica detras", true, false},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			dispositivo := nuevoDispositivoPrueba(t, "FIFO", 0)
+			io := nuevaIOFalsa(t)
+			instancia := conectarInstancia(dispositivo, io)
+
+			// las sincronicas estan en BLOCKED, las asincronicas siguen en RUNNING con el handle 7 en curso
+			peticiones := []*ProcesoEsperandoIO{}
+			for i, asincrona := range []bool{caso.primeraAsincrona, caso.segundaAsincrona} {
+				pcb := pcbPrueba(i+1, 0)
+				peticion := &ProcesoEsperandoIO{PCB: pcb, Tiempo: 1000}
+				if asincrona {
+					peticion.Asincrona, peticion.Handle = true, 7
+					pcb.HandlesIO[7] = false
+					AgregarPCBaCola(pcb, ColaRunning)
+				} else {
+					AgregarPCBaCola(pcb, ColaBlocked)
+				}
+				peticiones = append(peticiones, peticion)
+			}
+
+			encolarPrueba(t, dispositivo, peticiones[0])
+			esperarPeticion(t, io.peticiones, 1)
+			esperarEnCurso(t, instancia, 1)
+			encolarPrueba(t, dispositivo, peticiones[1])
+			sinPeticiones(t, io.peticiones) // la segunda no sale hasta que termine la primera
+
+			for _, peticion := range peticiones {
+				pid := peticion.PCB.PID
+				if estado := finDeIO(t, dispositivo, io, pid); estado != http.StatusOK {
+					t.Fatalf("fin de IO del PID %d: estado %d", pid, estado)
+				}
+				mutexHandlesIO.Lock()
+				completada := peticion.PCB.HandlesIO[7]
+				mutexHandlesIO.Unlock()
+				if peticion.Asincrona && !completada {
+					t.Errorf("el handle del PID %d no se completo", pid)
+				}
+				if !peticion.Asincrona && (!enCola(pid, ColaReady) || enCola(pid, ColaBlocked)) {
+					t.Errorf("el PID %d no paso de BLOCKED a READY", pid)
+				}
+				if pid == 1 {
+					// la otra peticion no se toca hasta su propio fin de IO
+					if otra := peticiones[1]; otra.Asincrona && otra.PCB.HandlesIO[7] || !otra.Asincrona && !enCola(2, ColaBlocked) {
+						t.Error("el fin de IO del PID 1 completo la peticion del PID 2")
+					}
+					esperarPeticion(t, io.peticiones, 2)
+					esperarEnCurso(t, instancia, 2)
+				}
+			}
+		})
+	}
+}
+
+func TestFinDeIOAntesDeLaRespuesta(t *testing.T) {
+	dispositivo := nuevoDispositivoPrueba(t, "FIFO", 0)
+	io := nuevaIOFalsa(t)
+	io.retenerPeticiones()
+	instancia := conectarInstancia(dispositivo, io)
+
+	pcb := pcbPrueba(1, 0)
+	AgregarPCBaCola(pcb, ColaBlocked)
+	encolarPrueba(t, dispositivo, &ProcesoEsperandoIO{PCB: pcb, Tiempo: 0})
+	esperarPeticion(t, io.peticiones, 1)
+
+	// una IO de 0 ms puede avisar el fin antes de que el kernel reciba la respuesta de /io/peticion
+	if estado := finDeIO(t, dispositivo, io, 1); estado != http.StatusOK {
+		t.Fatalf("fin de IO: estado %d", estado)
+	}
+	io.soltar <- true
+	if !enCola(1, ColaReady) {
+		t.Error("el PID 1 no paso a READY")
+	}
+
+	// la peticion ya termino: la instancia no puede quedar ocupada con ella
+	time.Sleep(50 * time.Millisecond)
+	mutexInstanciasIO.Lock()
+	enCurso, enviada := instancia.EnCurso, instancia.Enviada
+	mutexInstanciasIO.Unlock()
+	if enCurso != nil || enviada != nil {
+		t.Errorf("EnCurso = %v, Enviada = %v, se esperaba la instancia libre", enCurso, enviada)
+	}
+	otro := pcbPrueba(2, 0)
+	encolarPrueba(t, dispositivo, &ProcesoEsperandoIO{PCB: otro, Tiempo: 0})
+	esperarPeticion(t, io.peticiones, 2)
+}
+
+// Da de baja la instancia como si no respondiera los heartbeats
+func darDeBajaPrueba(instancia *InstanciaIO, dispositivo *DispositivoIO) {
+	darDeBajaInstanciaCaida(instancia, dispositivo.Nombre)
+	<-ProcesosAFinalizar // DesconectarInstancia despierta al finalizador aunque no haya nada que finalizar
+}
+
+func TestDarDeBajaInstanciaCaida(t *testing.T) {
+	t.Run("la peticion en curso pasa a otra instancia", func(t *testing.T) {
+		dispositivo := nuevoDispositivoPrueba(t, "FIFO", 0)
+		caida, otra := nuevaIOFalsa(t), nuevaIOFalsa(t)
+		instanciaCaida := conectarInstancia(dispositivo, caida)
+
+		AgregarPCBaCola(pcbPrueba(1, 0), ColaBlocked)
+		encolarPrueba(t, dispositivo, &ProcesoEsperandoIO{PCB: (*ColaBlocked)[0], Tiempo: 1000})
+		esperarPeticion(t, caida.peticiones, 1)
+		esperarEnCurso(t, instanciaCaida, 1)
+		instanciaOtra := conectarInstancia(dispositivo, otra)
+
+		darDeBajaPrueba(instanciaCaida, dispositivo)
+		esperarPeticion(t, otra.peticiones, 1)
+		esperarEnCurso(t, instanciaOtra, 1)
+		mutexDispositivosIO.Lock()
+		defer mutexDispositivosIO.Unlock()
+		if len(dispositivo.Instancias) != 1 || dispositivo.Instancias[0] != instanciaOtra || len(instanciasIOCaidas[dispositivo.Nombre]) != 1 {
+			t.Errorf("instancias = %v, caidas = %v", dispositivo.Instancias, instanciasIOCaidas[dispositivo.Nombre])
+		}
+	})
+
+	// La instancia termino la IO del PID 1 y se cae con la del PID 2 esperando en /io/peticion: la que
+	// se reencola es la del 2, una sola vez, y la del 1 (que ya termino) no se repite
+	casos := []struct {
+		nombre  string
+		aceptar bool
+	}{
+		{"el envio retenido falla", false},
+		{"la instancia caida acepta el envio retenido", true},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			dispositivo := nuevoDispositivoPrueba(t, "FIFO", 0)
+			caida, otra := nuevaIOFalsa(t), nuevaIOFalsa(t)
+			instanciaCaida := conectarInstancia(dispositivo, caida)
+
+			primera, segunda := pcbPrueba(1, 0), pcbPrueba(2, 0)
+			AgregarPCBaCola(primera, ColaBlocked)
+			AgregarPCBaCola(segunda, ColaBlocked)
+			encolarPrueba(t, dispositivo, &ProcesoEsperandoIO{PCB: primera, Tiempo: 1000})
+			esperarPeticion(t, caida.peticiones, 1)
+			esperarEnCurso(t, instanciaCaida, 1)
+			caida.retenerPeticiones()
+			encolarPrueba(t, dispositivo, &ProcesoEsperandoIO{PCB: segunda, Tiempo: 1000})
+			sinPeticiones(t, caida.peticiones)
+
+			if estado := finDeIO(t, dispositivo, caida, 1); estado != http.StatusOK {
+				t.Fatalf("fin de IO del PID 1: estado %d", estado)
+			}
+			esperarPeticion(t, caida.peticiones, 2)
+			instanciaOtra := conectarInstancia(dispositivo, otra)
+
+			darDeBajaPrueba(instanciaCaida, dispositivo)
+			caida.soltar <- caso.aceptar
+			esperarPeticion(t, otra.peticiones, 2)
+			esperarEnCurso(t, instanciaOtra, 2)
+			sinPeticiones(t, otra.peticiones)
+			if !enCola(1, ColaReady) || !enCola(2, ColaBlocked) {
+				t.Error("el PID 1 tenia que quedar en READY y el 2 en BLOCKED esperando su IO")
+			}
+		})
+	}
+}
+
+func TestSacarSiguientePeticionIO(t *testing.T) {
+	// PID: tiempo de IO y prioridad de cada peticion, en orden de llegada
+	cola := []struct{ pid, tiempo, prioridad int }{
+		{1, 300, 2},
+		{2, 100, 1},
+		{3, 200, 0},
+		{4, 100, 0},
+	}
+	casos := []struct {
+		politica string
+		orden    []int
+	}{
+		{"FIFO", []int{1, 2, 3, 4}},
+		{"SRF", []int{2, 4, 3, 1}},       // empate en 100: primero el que llego antes
+		{"PRIORIDAD", []int{3, 4, 2, 1}}, // empate en 0: primero el que llego antes
+	}
+	for _, caso := range casos {
+		t.Run(caso.politica, func(t *testing.T) {
+			dispositivo := &DispositivoIO{Nombre: "DISCO", MutexCola: new(sync.Mutex), Politica: caso.politica}
+			for _, p := range cola {
+				dispositivo.Cola = append(dispositivo.Cola, &ProcesoEsperandoIO{PCB: pcbPrueba(p.pid, p.prioridad), Tiempo: p.tiempo})
+			}
+			var orden []int
+			for len(dispositivo.Cola) > 0 {
+				orden = append(orden, sacarSiguientePeticionIO(dispositivo).PCB.PID)
+			}
+			if !slices.Equal(orden, caso.orden) {
+				t.Errorf("orden = %v, se esperaba %v", orden, caso.orden)
+			}
+		})
+	}
+}
+
+func TestReservarLugarIO(t *testing.T) {
+	casos := []struct {
+		nombre    string
+		maxCola   int
+		encoladas int
+		reservas  int
+		acepta    bool
+	}{
+		{"sin limite", 0, 50, 10, true},
+		{"con lugar", 3, 1, 1, true},
+		{"llena de peticiones", 2, 2, 0, false},
+		{"llena contando las reservas", 2, 1, 1, false},
+		{"llena solo de reservas", 2, 0, 2, false},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			dispositivo := &DispositivoIO{Nombre: "DISCO", MutexCola: new(sync.Mutex), MaxCola: caso.maxCola, reservas: caso.reservas}
+			for i := range caso.encoladas {
+				dispositivo.Cola = append(dispositivo.Cola, &ProcesoEsperandoIO{PCB: pcbPrueba(i, 0)})
+			}
+			if acepta := reservarLugarIO(dispositivo); acepta != caso.acepta {
+				t.Fatalf("reservarLugarIO = %t, se esperaba %t", acepta, caso.acepta)
+			}
+			reservas := caso.reservas
+			if caso.acepta {
+				reservas++
+			}
+			if dispositivo.reservas != reservas {
+				t.Errorf("reservas = %d, se esperaban %d", dispositivo.reservas, reservas)
+			}
+		})
+	}
+
+	t.Run("la reserva se devuelve si la peticion no se encola", func(t *testing.T) {
+		dispositivo := &DispositivoIO{Nombre: "DISCO", MutexCola: new(sync.Mutex), MaxCola: 1, procesosEsperandoIO: make(chan int, 1)}
+		if !reservarLugarIO(dispositivo) {
+			t.Fatal("la cola vacia no acepto la reserva")
+		}
+		if reservarLugarIO(dispositivo) {
+			t.Fatal("se acepto una segunda reserva con MaxCola 1")
+		}
+		liberarLugarIO(dispositivo)
+		if !reservarLugarIO(dispositivo) {
+			t.Fatal("el lugar liberado no se pudo volver a reservar")
+		}
+		encolarPeticionIO(dispositivo, &ProcesoEsperandoIO{PCB: pcbPrueba(1, 0)})
+		if dispositivo.reservas != 0 || len(dispositivo.Cola) != 1 {
+			t.Errorf("reservas = %d, cola = %d, se esperaba la reserva consumida por la peticion", dispositivo.reservas, len(dispositivo.Cola))
+		}
+	})
+
+	t.Run("syscalls a la vez no pasan de MaxCola", func(t *testing.T) {
+		dispositivo := &DispositivoIO{Nombre: "DISCO", MutexCola: new(sync.Mutex), MaxCola: 5}
+		var aceptadas sync.WaitGroup
+		var mutex sync.Mutex
+		cantidad := 0
+		for range 50 {
+			aceptadas.Add(1)
+			go func() {
+				defer aceptadas.Done()
+				if reservarLugarIO(dispositivo) {
+					mutex.Lock()
+					cantidad++
+					mutex.Unlock()
+				}
+			}()
+		}
+		aceptadas.Wait()
+		if cantidad != 5 {
+			t.Errorf("%d reservas aceptadas, se esperaban 5", cantidad)
+		}
+	})
+}
+
+func TestValidarDispositivosIO(t *testing.T) {
+	casos := []struct {
+		nombre       string
+		dispositivos map[string]ConfigDispositivoIO
+		error        string
+	}{
+		{"sin configuracion", nil, ""},
+		{"politicas validas", map[string]ConfigDispositivoIO{
+			"DISCO":     {QUEUE_POLICY: "SRF", MAX_QUEUE_LENGTH: 3},
+			"TECLADO":   {QUEUE_POLICY: "PRIORIDAD"},
+			"IMPRESORA": {MAX_QUEUE_LENGTH: 0},
+		}, ""},
+		{"politica mal escrita", map[string]ConfigDispositivoIO{"DISCO": {QUEUE_POLICY: "srf"}},
+			`io_devices.DISCO.queue_policy="srf" invalido, se espera uno de: FIFO, SRF, PRIORIDAD`},
+		{"largo negativo", map[string]ConfigDispositivoIO{"DISCO": {MAX_QUEUE_LENGTH: -1}},
+			"io_devices.DISCO.max_queue_length=-1 invalido, tiene que ser mayor o igual a 0"},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			err := validarDispositivosIO(caso.dispositivos)
+			if caso.error == "" && err != nil || caso.error != "" && (err == nil || err.Error() != caso.error) {
+				t.Errorf("error = %v, se esperaba %q", err, caso.error)
+			}
+		})
+	}
+}
+
+// La CPU contesta los heartbeats hasta que deja de responder: con un fallo suelto sigue conectada
+// y con CPU_HEARTBEAT_MAX_FAILURES seguidos se la da de baja y su proceso vuelve a READY
+func TestDarDeBajaCPUCaida(t *testing.T) {
+	InicializarColas()
+	algoritmoColaReady = "FIFO"
+	for len(ProcesosEnReady) > 0 {
+		<-ProcesosEnReady
+	}
+
+	var responder atomic.Bool
+	responder.Store(true)
+	heartbeats := make(chan bool, 100)
+	servidor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok := responder.Load()
+		heartbeats <- ok
+		if !ok {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(servidor.Close)
+	ip, puerto, _ := net.SplitHostPort(servidor.Listener.Addr().String())
+	numeroPuerto, _ := strconv.Atoi(puerto)
+
+	configAnterior := ClientConfig
+	ClientConfig = &Config{CPU_HEARTBEAT_INTERVAL: 10, CPU_HEARTBEAT_MAX_FAILURES: 3}
+	cpu := globales.HandshakeCPU{ID_CPU: "HB1", IP_CPU: ip, PORT_CPU: numeroPuerto, DISPONIBLE: make(chan int, 1)}
+	mutexConexionesCPU.Lock()
+	ConexionesCPU = append(ConexionesCPU, cpu)
+	mutexConexionesCPU.Unlock()
+	t.Cleanup(func() {
+		ClientConfig = configAnterior
+		mutexConexionesCPU.Lock()
+		delete(cpusCaidas, cpu.ID_CPU)
+		mutexConexionesCPU.Unlock()
+	})
+
+	pcb := pcbPrueba(1, 0)
+	pcb.PC = 42
+	pcb.Registros = globales.Registros{AX: 7}
+	AgregarPCBaCola(pcb, ColaRunning)
+	mutexCPUporProceso.Lock()
+	CPUporProceso[cpu.ID_CPU] = pcb.PID
+	mutexCPUporProceso.Unlock()
+
+	terminado := make(chan struct{})
+	go func() {
+		monitorearCPU(cpu)
+		close(terminado)
+	}()
+
+	// un heartbeat perdido entre dos respondidos no la da de baja
+	<-heartbeats
+	responder.Store(false)
+	for <-heartbeats {
+	}
+	responder.Store(true)
+	for !<-heartbeats {
+	}
+	if !cpuSigueConectada(&cpu) {
+		t.Fatal("la CPU se dio de baja con un solo heartbeat perdido")
+	}
+
+	responder.Store(false)
+	select {
+	case <-terminado:
+	case <-time.After(2 * time.Second):
+		t.Fatal("la CPU no se dio de baja despues de perder los heartbeats")
+	}
+
+	if cpuSigueConectada(&cpu) {
+		t.Error("la CPU sigue en las conexiones")
+	}
+	mutexCPUporProceso.Lock()
+	_, ejecutando := CPUporProceso[cpu.ID_CPU]
+	mutexCPUporProceso.Unlock()
+	if ejecutando {
+		t.Error("la CPU caida sigue asignada al PID 1")
+	}
+	if !enCola(1, ColaReady) || enCola(1, ColaRunning) {
+		t.Fatal("el PID 1 no paso de RUNNING a READY")
+	}
+	if pcb.PC != 42 || pcb.Registros.AX != 7 {
+		t.Errorf("el PID 1 volvio con PC %d y AX %d, se esperaba el ultimo contexto guardado (PC 42, AX 7)", pcb.PC, pcb.Registros.AX)
+	}
+	select {
+	case <-ProcesosEnReady:
+	default:
+		t.Error("no se aviso al planificador del proceso en READY")
+	}
+	select {
+	case <-cpu.DISPONIBLE:
+	default:
+		t.Error("no se desperto al planificador de la CPU caida")
+	}
+}
+
+// La senial de InterrumpirCPU solo se consume si el kernel pidio el desalojo de esa CPU: un fin de
+// quantum lo decide la CPU sola y la senial puede ser de un desalojo pendiente en otra CPU
+func TestRecibirProcesoInterrumpido(t *testing.T) {
+	casos := []struct {
+		nombre          string
+		motivo          string
+		pedidaPorKernel bool
+		enRunning       bool
+		seConsume       bool
+	}{
+		{"desalojo pedido por el kernel", globales.InterrupcionDesalojo, true, true, true},
+		{"fin de IO pedido por el kernel", globales.InterrupcionFinIO, true, true, true},
+		{"fin de quantum", globales.InterrupcionQuantum, false, true, false},
+		{"fin de quantum con un desalojo pedido", globales.InterrupcionQuantum, true, true, true},
+		{"proceso que ya no esta en RUNNING", globales.InterrupcionDesalojo, true, false, true},
+		{"proceso que ya no esta en RUNNING sin pedido", globales.InterrupcionQuantum, false, false, false},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			InicializarColas()
+			algoritmoColaReady = "FIFO"
+			for len(ProcesosEnReady) > 0 {
+				<-ProcesosEnReady
+			}
+			consumirSenialInterrumpirCPU()
+			InterrumpirCPU <- 1
+			t.Cleanup(consumirSenialInterrumpirCPU)
+
+			pcb := pcbPrueba(1, 0)
+			if caso.enRunning {
+				AgregarPCBaCola(pcb, ColaRunning)
+			}
+			mutexCPUporProceso.Lock()
+			CPUporProceso["INT1"] = pcb.PID
+			mutexCPUporProceso.Unlock()
+			mutexInterrupcionesCPU.Lock()
+			cpupendienteInterrupcion["INT1"] = caso.pedidaPorKernel
+			mutexInterrupcionesCPU.Unlock()
+			t.Cleanup(func() {
+				mutexCPUporProceso.Lock()
+				delete(CPUporProceso, "INT1")
+				mutexCPUporProceso.Unlock()
+			})
+
+			cuerpo, _ := json.Marshal(globales.Interrupcion{PID: 1, PC: 9, MOTIVO: caso.motivo, REGISTROS: globales.Registros{BX: 3}})
+			w := httptest.NewRecorder()
+			RecibirProcesoInterrumpido(w, httptest.NewRequest(http.MethodPost, "/cpu/interrupt", bytes.NewReader(cuerpo)))
+
+			if consumida := len(InterrumpirCPU) == 0; consumida != caso.seConsume {
+				t.Errorf("senial de InterrumpirCPU consumida = %v, se esperaba %v", consumida, caso.seConsume)
+			}
+			mutexInterrupcionesCPU.Lock()
+			_, pendiente := cpupendienteInterrupcion["INT1"]
+			mutexInterrupcionesCPU.Unlock()
+			if pendiente {
+				t.Error("la CPU sigue con una interrupcion pendiente")
+			}
+			if !caso.enRunning {
+				if w.Code != http.StatusNotFound {
+					t.Errorf("estado %d, se esperaba %d", w.Code, http.StatusNotFound)
+				}
+				return
+			}
+			if w.Code != http.StatusOK {
+				t.Fatalf("estado %d", w.Code)
+			}
+			if !enCola(1, ColaReady) || enCola(1, ColaRunning) {
+				t.Fatal("el PID 1 no paso de RUNNING a READY")
+			}
+			if pcb.PC != 9 || pcb.Registros.BX != 3 {
+				t.Errorf("el PID 1 volvio con PC %d y BX %d, se esperaba el contexto de la CPU (PC 9, BX 3)", pcb.PC, pcb.Registros.BX)
+			}
+		})
+	}
+}
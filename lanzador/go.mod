@@ -0,0 +1,3 @@
+module lanzador
+
+go 1.24
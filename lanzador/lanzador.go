@@ -0,0 +1,485 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Levanta el sistema completo a partir de un archivo de topologia: compila cada modulo, los arranca en
+// orden (memoria, kernel, CPUs, IOs) esperando cada handshake, muestra sus logs juntos y con Ctrl-C los
+// cierra en orden inverso.
+// Uso: lanzador [-manual] topologia.json
+func main() {
+	manual := flag.Bool("manual", false, "no arranca el planificador solo: el ENTER se le pasa al kernel")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Uso: lanzador [-manual] topologia.json")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	topologia, err := leerTopologia(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	binarios, err := os.MkdirTemp("", "lanzador")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	defer os.RemoveAll(binarios)
+
+	if err := compilarModulos(topologia, binarios); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	// Los hijos van en su propio grupo de procesos, asi el Ctrl-C solo lo recibe el lanzador y los cierra en orden
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	var procesos []*proceso
+	for _, instancia := range topologia.MODULOS {
+		p, err := arrancar(topologia, instancia, binarios)
+		if err == nil {
+			procesos = append(procesos, p)
+			err = esperarHandshake(topologia, p, sigChan)
+		}
+		if err != nil {
+			imprimir("lanzador", colorLanzador, err.Error())
+			detener(procesos)
+			os.Exit(1)
+		}
+		imprimir("lanzador", colorLanzador, fmt.Sprintf("%s listo", instancia.nombre()))
+	}
+
+	kernel := procesos[slices.IndexFunc(procesos, func(p *proceso) bool { return p.MODULO == "kernel" })]
+	if *manual {
+		imprimir("lanzador", colorLanzador, "Sistema listo, presione ENTER para iniciar el planificador...")
+		go io.Copy(kernel.entrada, os.Stdin)
+	} else {
+		imprimir("lanzador", colorLanzador, "Sistema listo, iniciando el planificador")
+		io.WriteString(kernel.entrada, "\n")
+	}
+
+	<-sigChan
+	imprimir("lanzador", colorLanzador, "Cerrando el sistema...")
+	detener(procesos)
+}
+
+// ------ TOPOLOGIA ------ //
+type Instancia struct {
+	MODULO       string `json:"modulo"`       // memoria, kernel, cpu o io
+	ID           string `json:"id"`           // id de la CPU o nombre del dispositivo IO
+	CONFIG       string `json:"config"`       // archivo dentro de <modulo>/configs
+	PSEUDOCODIGO string `json:"pseudocodigo"` // solo kernel: proceso inicial
+	TAMANIO      int    `json:"tamanio"`      // solo kernel: tamanio del proceso inicial
+}
+
+type Topologia struct {
+	RAIZ    string      `json:"raiz"`    // carpeta del repo, relativa a la topologia (default: la carpeta de la topologia)
+	ESPERA  int         `json:"espera"`  // segundos maximos para cada handshake, default 10
+	MODULOS []Instancia `json:"modulos"` // en cualquier orden, se arrancan por dependencias
+}
+
+// Orden de arranque: cada modulo hace el handshake con los anteriores
+var ordenModulos = map[string]int{"memoria": 0, "kernel": 1, "cpu": 2, "io": 3}
+
+func (i Instancia) nombre() string {
+	switch i.MODULO {
+	case "cpu":
+		return "cpu-" + i.ID
+	case "io":
+		return "io_" + i.ID
+	}
+	return i.MODULO
+}
+
+func (i Instancia) argumentos() []string {
+	switch i.MODULO {
+	case "kernel":
+		return []string{i.PSEUDOCODIGO, strconv.Itoa(i.TAMANIO), i.CONFIG}
+	case "cpu", "io":
+		return []string{i.ID, i.CONFIG}
+	}
+	return []string{i.CONFIG}
+}
+
+func leerTopologia(ruta string) (Topologia, error) {
+	var topologia Topologia
+	archivo, err := os.Open(ruta)
+	if err != nil {
+		return topologia, fmt.Errorf("no se pudo abrir la topologia '%s': %w", ruta, err)
+	}
+	defer archivo.Close()
+
+	decoder := json.NewDecoder(archivo)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&topologia); err != nil {
+		return topologia, fmt.Errorf("topologia '%s' invalida: %w", ruta, err)
+	}
+
+	if !filepath.IsAbs(topologia.RAIZ) {
+		topologia.RAIZ = filepath.Join(filepath.Dir(ruta), topologia.RAIZ)
+	}
+	if topologia.RAIZ, err = filepath.Abs(topologia.RAIZ); err != nil {
+		return topologia, err
+	}
+	if topologia.ESPERA <= 0 {
+		topologia.ESPERA = 10
+	}
+
+	cantidad := map[string]int{}
+	nombres := map[string]bool{}
+	for _, instancia := range topologia.MODULOS {
+		if _, ok := ordenModulos[instancia.MODULO]; !ok {
+			return topologia, fmt.Errorf("modulo desconocido '%s' (se espera memoria, kernel, cpu o io)", instancia.MODULO)
+		}
+		if instancia.CONFIG == "" {
+			return topologia, fmt.Errorf("%s no tiene config", instancia.MODULO)
+		}
+		if (instancia.MODULO == "cpu" || instancia.MODULO == "io") && instancia.ID == "" {
+			return topologia, fmt.Errorf("falta el id de una instancia de %s", instancia.MODULO)
+		}
+		if instancia.MODULO == "kernel" && instancia.PSEUDOCODIGO == "" {
+			return topologia, fmt.Errorf("falta el pseudocodigo del proceso inicial del kernel")
+		}
+		if nombres[instancia.nombre()] {
+			return topologia, fmt.Errorf("%s aparece dos veces", instancia.nombre())
+		}
+		nombres[instancia.nombre()] = true
+		cantidad[instancia.MODULO]++
+	}
+	if cantidad["memoria"] != 1 || cantidad["kernel"] != 1 {
+		return topologia, fmt.Errorf("la topologia necesita exactamente una memoria y un kernel")
+	}
+
+	slices.SortStableFunc(topologia.MODULOS, func(a, b Instancia) int {
+		return ordenModulos[a.MODULO] - ordenModulos[b.MODULO]
+	})
+	return topologia, nil
+}
+
+// Solo los campos de los configs que el lanzador necesita para esperar los handshakes
+type configModulo struct {
+	PORT_MEMORY int    `json:"port_memory"`
+	IP_MEMORY   string `json:"ip_memory"`
+	PORT_KERNEL int    `json:"port_kernel"`
+	IP_KERNEL   string `json:"ip_kernel"`
+	PORT_CPU    int    `json:"port_cpu"`
+	IP_CPU      string `json:"ip_cpu"`
+	PORT_IO     int    `json:"port_io"`
+	IP_IO       string `json:"ip_io"`
+}
+
+func leerConfigModulo(topologia Topologia, instancia Instancia) (configModulo, error) {
+	var config configModulo
+	ruta := filepath.Join(topologia.RAIZ, instancia.MODULO, "configs", instancia.CONFIG)
+	contenido, err := os.ReadFile(ruta)
+	if err != nil {
+		return config, fmt.Errorf("no se pudo leer el config de %s: %w", instancia.nombre(), err)
+	}
+	if err := json.Unmarshal(contenido, &config); err != nil {
+		return config, fmt.Errorf("config de %s invalido: %w", instancia.nombre(), err)
+	}
+	return config, nil
+}
+
+// ------ PROCESOS ------ //
+type proceso struct {
+	Instancia
+	comando   *exec.Cmd
+	config    configModulo
+	entrada   io.WriteCloser
+	terminado chan struct{} // se cierra cuando el proceso termina
+	err       error
+}
+
+func compilarModulos(topologia Topologia, binarios string) error {
+	compilados := map[string]bool{}
+	for _, instancia := range topologia.MODULOS {
+		if compilados[instancia.MODULO] {
+			continue
+		}
+		imprimir("lanzador", colorLanzador, fmt.Sprintf("Compilando %s...", instancia.MODULO))
+		compilar := exec.Command("go", "build", "-o", filepath.Join(binarios, instancia.MODULO), ".")
+		compilar.Dir = filepath.Join(topologia.RAIZ, instancia.MODULO)
+		if salida, err := compilar.CombinedOutput(); err != nil {
+			return fmt.Errorf("no se pudo compilar %s: %w\n%s", instancia.MODULO, err, salida)
+		}
+		compilados[instancia.MODULO] = true
+	}
+	return nil
+}
+
+func arrancar(topologia Topologia, instancia Instancia, binarios string) (*proceso, error) {
+	config, err := leerConfigModulo(topologia, instancia)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &proceso{Instancia: instancia, config: config, terminado: make(chan struct{})}
+	// si el puerto ya atiende, el handshake lo contestaria otro proceso (una corrida anterior que quedo viva)
+	if ip, puerto := p.direccion(); escuchando(ip, puerto) {
+		return nil, fmt.Errorf("el puerto %d de %s ya esta en uso", puerto, instancia.nombre())
+	}
+	dir := filepath.Join(topologia.RAIZ, instancia.MODULO)
+	p.comando = exec.Command(filepath.Join(binarios, instancia.MODULO), instancia.argumentos()...)
+	p.comando.Dir = dir // los modulos buscan sus configs en ./configs y dejan el log en la carpeta actual
+	// grupo propio para no recibir el Ctrl-C de la terminal, y SIGTERM si el lanzador muere sin cerrarlos
+	p.comando.SysProcAttr = &syscall.SysProcAttr{Setpgid: true, Pdeathsig: syscall.SIGTERM}
+	color := colorDeInstancia(instancia)
+	p.comando.Stdout = &salidaConPrefijo{nombre: instancia.nombre(), color: color}
+	p.comando.Stderr = &salidaConPrefijo{nombre: instancia.nombre(), color: color}
+	if p.entrada, err = p.comando.StdinPipe(); err != nil {
+		return nil, err
+	}
+
+	// el log se sigue desde donde esta ahora, las corridas anteriores quedan afuera
+	rutaLog := filepath.Join(dir, instancia.nombre()+".log")
+	desde := int64(0)
+	if info, err := os.Stat(rutaLog); err == nil {
+		desde = info.Size()
+	}
+
+	if err := p.comando.Start(); err != nil {
+		return nil, fmt.Errorf("no se pudo arrancar %s: %w", instancia.nombre(), err)
+	}
+	go func() {
+		p.err = p.comando.Wait()
+		close(p.terminado)
+		if p.err != nil {
+			imprimir("lanzador", colorLanzador, fmt.Sprintf("%s termino: %v", instancia.nombre(), p.err))
+		} else {
+			imprimir("lanzador", colorLanzador, fmt.Sprintf("%s termino", instancia.nombre()))
+		}
+	}()
+	go seguirLog(rutaLog, desde, instancia.nombre(), color, p.terminado)
+	return p, nil
+}
+
+// Espera a que el modulo atienda pedidos (memoria, kernel) o a que el kernel lo tenga registrado (CPU, IO)
+func esperarHandshake(topologia Topologia, p *proceso, sigChan chan os.Signal) error {
+	plazo := time.After(time.Duration(topologia.ESPERA) * time.Second)
+	for {
+		if listo(p) {
+			return nil
+		}
+		select {
+		case <-p.terminado:
+			return fmt.Errorf("%s termino antes de estar listo: %v", p.nombre(), p.err)
+		case <-plazo:
+			return fmt.Errorf("%s no hizo el handshake en %d segundos", p.nombre(), topologia.ESPERA)
+		case senial := <-sigChan:
+			return fmt.Errorf("arranque interrumpido (%s)", senial)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+var clienteAdmin = &http.Client{Timeout: time.Second}
+
+// Respuestas de /admin/cpu y /admin/io del kernel
+type cpuRegistrada struct {
+	IP     string `json:"ip_cpu"`
+	Puerto int    `json:"port_cpu"`
+}
+
+type dispositivoRegistrado struct {
+	Nombre     string `json:"nombre"`
+	Instancias []struct {
+		IP     string `json:"ip"`
+		Puerto int    `json:"puerto"`
+		Estado string `json:"estado"` // las CAIDA siguen listadas pero no atienden
+	} `json:"instancias"`
+}
+
+// IP y puerto donde atiende el modulo
+func (p *proceso) direccion() (string, int) {
+	switch p.MODULO {
+	case "memoria":
+		return p.config.IP_MEMORY, p.config.PORT_MEMORY
+	case "kernel":
+		return p.config.IP_KERNEL, p.config.PORT_KERNEL
+	case "cpu":
+		return p.config.IP_CPU, p.config.PORT_CPU
+	}
+	return p.config.IP_IO, p.config.PORT_IO
+}
+
+func listo(p *proceso) bool {
+	switch p.MODULO {
+	case "memoria", "kernel":
+		return escuchando(p.direccion())
+	case "cpu":
+		var cpus []cpuRegistrada
+		if !consultarKernel(p.config, "/admin/cpu", &cpus) {
+			return false
+		}
+		return slices.ContainsFunc(cpus, func(cpu cpuRegistrada) bool {
+			return cpu.IP == p.config.IP_CPU && cpu.Puerto == p.config.PORT_CPU
+		})
+	case "io":
+		var dispositivos []dispositivoRegistrado
+		if !consultarKernel(p.config, "/admin/io", &dispositivos) {
+			return false
+		}
+		for _, dispositivo := range dispositivos {
+			for _, instancia := range dispositivo.Instancias {
+				if dispositivo.Nombre == p.ID && instancia.IP == p.config.IP_IO && instancia.Puerto == p.config.PORT_IO && instancia.Estado != "CAIDA" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func escuchando(ip string, puerto int) bool {
+	conexion, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", ip, puerto), time.Second)
+	if err != nil {
+		return false
+	}
+	conexion.Close()
+	return true
+}
+
+func consultarKernel(config configModulo, ruta string, respuesta any) bool {
+	resp, err := clienteAdmin.Get(fmt.Sprintf("http://%s:%d%s", config.IP_KERNEL, config.PORT_KERNEL, ruta))
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode == http.StatusOK && json.NewDecoder(resp.Body).Decode(respuesta) == nil
+}
+
+// Cierra los modulos en orden inverso al de arranque: las IOs y CPUs avisan su desconexion al kernel
+func detener(procesos []*proceso) {
+	for i := len(procesos) - 1; i >= 0; i-- {
+		p := procesos[i]
+		select {
+		case <-p.terminado:
+			continue
+		default:
+		}
+		p.comando.Process.Signal(syscall.SIGINT)
+		select {
+		case <-p.terminado:
+		case <-time.After(5 * time.Second):
+			imprimir("lanzador", colorLanzador, fmt.Sprintf("%s no cerro a tiempo, se mata", p.nombre()))
+			p.comando.Process.Kill()
+			<-p.terminado
+		}
+	}
+}
+
+// ------ SALIDA ------ //
+var mutexSalida sync.Mutex
+
+const (
+	colorReset    = "\033[0m"
+	colorLanzador = "\033[1m"
+)
+
+var coloresModulo = map[string]string{"memoria": "\033[35m", "kernel": "\033[34m", "cpu": "\033[32m", "io": "\033[33m"}
+
+func colorDeInstancia(instancia Instancia) string {
+	return coloresModulo[instancia.MODULO]
+}
+
+func imprimir(nombre string, color string, linea string) {
+	mutexSalida.Lock()
+	defer mutexSalida.Unlock()
+	fmt.Printf("%s%-10s%s %s\n", color, "["+nombre+"]", colorReset, linea)
+}
+
+// Stdout y stderr de un modulo, linea por linea con el nombre de la instancia adelante
+type salidaConPrefijo struct {
+	nombre    string
+	color     string
+	pendiente []byte
+	mutex     sync.Mutex
+}
+
+func (s *salidaConPrefijo) Write(p []byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.pendiente = append(s.pendiente, p...)
+	for {
+		fin := strings.IndexByte(string(s.pendiente), '\n')
+		if fin < 0 {
+			break
+		}
+		imprimir(s.nombre, s.color, string(s.pendiente[:fin]))
+		s.pendiente = s.pendiente[fin+1:]
+	}
+	return len(p), nil
+}
+
+// Sigue el archivo de log del modulo mientras corre. Si el archivo se achica es que roto, y se sigue desde el principio
+func seguirLog(ruta string, desde int64, nombre string, color string, terminado chan struct{}) {
+	var archivo *os.File
+	var lector *bufio.Reader
+	defer func() {
+		if archivo != nil {
+			archivo.Close()
+		}
+	}()
+
+	for fin := false; ; {
+		if archivo == nil {
+			if abierto, err := os.Open(ruta); err == nil {
+				abierto.Seek(desde, io.SeekStart)
+				archivo, lector = abierto, bufio.NewReader(abierto)
+			}
+		}
+		if archivo != nil {
+			for {
+				linea, err := lector.ReadString('\n')
+				if err != nil {
+					// la linea incompleta se vuelve a leer entera en la proxima vuelta
+					archivo.Seek(-int64(len(linea)), io.SeekCurrent)
+					lector.Reset(archivo)
+					break
+				}
+				desde += int64(len(linea))
+				imprimir(nombre, color, strings.TrimRight(linea, "\n"))
+			}
+			if info, err := os.Stat(ruta); err == nil && info.Size() < desde {
+				archivo.Close()
+				archivo, desde = nil, 0
+				continue
+			}
+		}
+
+		if fin {
+			return
+		}
+		select {
+		case <-terminado:
+			fin = true // una vuelta mas para mostrar lo ultimo que escribio
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+}
@@ -34,11 +34,11 @@ func main() {
 	utils.InicializarMemoria()
 
 	// ------ LOGGING ------ //
-	globales.ConfigurarLogger("memoria.log", utils.ClientConfig.LOG_LEVEL)
+	globales.ConfigurarLogger("memoria.log", utils.ClientConfig.LOG_LEVEL, utils.ClientConfig.ConfigLog)
 	slog.Info("Iniciando módulo Memoria", "puerto", utils.ClientConfig.PORT_MEMORY)
-
-	if utils.ClientConfig == nil {
-		slog.Error("No se pudo crear el config")
+	globales.ConfigurarRPC(utils.ClientConfig.ConfigRPC)
+	if err := globales.IniciarSpans("memoria", utils.ClientConfig.SPANS_FILE); err != nil {
+		slog.Error(err.Error())
 	}
 
 	// ------ INICIALIZACION DE VARIABLES ------ //
@@ -57,25 +57,40 @@ func main() {
 	mux.HandleFunc("/cpu/handshake", utils.AtenderHandshakeCPU)
 	mux.HandleFunc("/cpu/leer_pagina", utils.LeerPaginaCompleta)
 	mux.HandleFunc("/cpu/buscar_instruccion", utils.DevolverInstruccion)
+	mux.HandleFunc("/cpu/buscar_instrucciones", utils.DevolverInstrucciones) // lote para la cache de instrucciones
 	mux.HandleFunc("/cpu/leer_direccion", utils.LeerDireccion)
 	mux.HandleFunc("/cpu/escribir_direccion", utils.EscribirDireccion)
 	mux.HandleFunc("/cpu/obtener_marco", utils.ObtenerMarco)
 	mux.HandleFunc("/cpu/escribir_pagina", utils.EscribirPaginaCompleta)
+	mux.HandleFunc("/cpu/mprotect", utils.MProteger)
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	go escucharPeticiones(puerto_memoria, mux)
+	if utils.ClientConfig.TCP_PORT != 0 {
+		go escucharPeticionesTCP(utils.ClientConfig.TCP_PORT, mux)
+	}
 
 	<-sigChan // Esperar a recibir una señal
 	//slog.Debug(fmt.Sprintf("Memoria contigua: %x ", utils.MemoriaDeUsuario))
 	//DebugSwapCompleto()
 	slog.Info("Cerrando modulo memoria ...")
+	globales.CerrarSpans()
 }
 
 func escucharPeticiones(puerto string, mux *http.ServeMux) {
-	err := http.ListenAndServe(puerto, mux)
+	err := http.ListenAndServe(puerto, globales.Rastrear(mux))
 	if err != nil {
 		slog.Error(fmt.Sprintf("Error al iniciar el servidor: %s", err.Error()))
 		//panic(err)
 	}
 }
+
+// Mismas rutas que el servidor HTTP, por conexiones TCP persistentes con tramas binarias
+func escucharPeticionesTCP(puerto int, mux *http.ServeMux) {
+	slog.Info(fmt.Sprintf("Escuchando transporte TCP en el puerto %d", puerto))
+	err := globales.ServirTCP(puerto, globales.Rastrear(mux))
+	if err != nil {
+		slog.Error(fmt.Sprintf("Error al iniciar el servidor TCP: %s", err.Error()))
+	}
+}
@@ -1,13 +1,14 @@
 package utils
 
 import (
-	"bufio"
 	"bytes"
+	"context"
 	"encoding/binary"
 	"encoding/gob"
 	"encoding/json"
 	"fmt"
 	"globales"
+	"globales/pseudocodigo"
 	"io"
 	"log"
 	"log/slog"
@@ -44,6 +45,9 @@ var mutexProcesosEnMemoria sync.Mutex // Mutex para proteger el acceso a la list
 
 var mutexArchivoSwap sync.Mutex // Mutex para proteger el acceso al archivo de swap
 
+var cpusConectadas = make(map[string]globales.HandshakeCPU) // clave ip:puerto, a donde se avisan las invalidaciones por ASID
+var mutexCpusConectadas sync.Mutex
+
 // --------- ESTRUCTURAS DE MEMORIA --------- //
 type Config struct {
 	PORT_MEMORY      int    `json:"port_memory"`
@@ -58,6 +62,10 @@ type Config struct {
 	LOG_LEVEL        string `json:"log_level"`
 	DUMP_PATH        string `json:"dump_path"`
 	SCRIPTS_PATH     string `json:"scripts_path"`
+	TCP_PORT         int    `json:"tcp_port"` // 0 = solo HTTP. Si no, atiende tambien el transporte binario de la CPU
+
+	globales.ConfigRPC // rpc_timeout, rpc_retries, rpc_backoff y spans_file
+	globales.ConfigLog // log_format, log_stdout, log_max_size, log_max_age y log_max_files
 }
 
 // Para la memoria, un proceso se reduce a su ID y su Tabla de Paginas.
@@ -82,8 +90,9 @@ type METRICAS_PROCESO struct { //Cuando se reserva espacio en memoria inicializa
 }
 
 type NodoTablaPaginas struct {
-	Children []*NodoTablaPaginas // Para niveles intermedios
-	Marcos   []*int
+	Children    []*NodoTablaPaginas // Para niveles intermedios
+	Marcos      []*int
+	SoloLectura []bool // proteccion de cada pagina (ultimo nivel), se conserva al ir y volver de swap
 }
 
 // --------- INICIO DE MEMORIA FISICA --------- //
@@ -107,16 +116,30 @@ func InicializarMemoria() {
 }
 
 // --------- FUNCIONES AUXILIARES --------- //
+// Lo que tiene que cumplir el config para que memoria arranque
+var esquemaConfig = globales.Esquema{
+	"port_memory":      {Obligatorio: true, Rango: []float64{1, 65535}},
+	"ip_memory":        {Obligatorio: true},
+	"memory_size":      {Obligatorio: true, Rango: []float64{1}},
+	"page_size":        {Obligatorio: true, Rango: []float64{1}},
+	"entries_per_page": {Obligatorio: true, Rango: []float64{1}},
+	"number_of_levels": {Obligatorio: true, Rango: []float64{1}},
+	"memory_delay":     {Rango: []float64{0}},
+	"swapfile_path":    {Obligatorio: true},
+	"swap_delay":       {Rango: []float64{0}},
+	"dump_path":        {Obligatorio: true},
+	"scripts_path":     {Obligatorio: true},
+	"tcp_port":         {Rango: []float64{0, 65535}},
+}
+
 func IniciarConfiguracion(filePath string) *Config {
-	var config *Config
-	configFile, err := os.Open(filePath)
-	if err != nil {
+	config := &Config{}
+	if err := globales.CargarConfiguracion("memoria", filePath, config, esquemaConfig); err != nil {
 		log.Fatal(err.Error())
 	}
-	defer configFile.Close()
-
-	jsonParser := json.NewDecoder(configFile)
-	jsonParser.Decode(&config)
+	if config.MEMORY_SIZE%config.PAGE_SIZE != 0 {
+		log.Fatalf("config %s invalido: memory_size=%d tiene que ser multiplo de page_size=%d", filePath, config.MEMORY_SIZE, config.PAGE_SIZE)
+	}
 
 	slog.Debug("Configuración de memoria cargada correctamente", "config", config)
 
@@ -131,46 +154,39 @@ func delayDeSwap() {
 	time.Sleep(time.Duration(ClientConfig.SWAP_DELAY) * time.Millisecond) // Simula el delay de acceso a swap
 }
 
-func LeerArchivoDePseudocodigo(rutaArchivo string, pid int) {
+// Valida el archivo de pseudocodigo y guarda sus instrucciones para el PID.
+// Si el archivo tiene errores no se guarda nada y se devuelven todos con su archivo:linea.
+func LeerArchivoDePseudocodigo(rutaArchivo string, pid int, tamanioProceso int) error {
 	filePath := filepath.Join(ClientConfig.SCRIPTS_PATH, rutaArchivo)
-	file, err := os.Open(filePath)
+	instrucciones, err := pseudocodigo.EnsamblarArchivo(filePath, pseudocodigo.Opciones{TamanioProceso: tamanioProceso})
 	if err != nil {
-		log.Fatalf("Error al abrir el archivo '%s': %v", filePath, err)
+		return err
 	}
-	// Asegurar que el archivo se cierre al final de la función main
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	lineNumber := 0
+	// Guardo en el mapa: clave = PC, valor = instruccion ya validada
+	instruccionesDelProceso := make(map[int]string, len(instrucciones))
+	for pc, instruccion := range instrucciones {
+		instruccionesDelProceso[pc] = instruccion
+	}
 
-	// Inicializo el mapa de instrucciones para el proceso
 	mutexInstrucciones.Lock()
-	if instruccionesProcesos[pid] == nil {
-		instruccionesProcesos[pid] = make(map[int]string)
-	}
+	instruccionesProcesos[pid] = instruccionesDelProceso
 	mutexInstrucciones.Unlock()
 
-	// Leo el archivo línea por línea
-	for scanner.Scan() {
-		lineText := scanner.Text()
-
-		// Guardo en el mapa: PID, valor = texto de la línea
-		mutexInstrucciones.Lock()
-		instruccionesProcesos[pid][lineNumber] = lineText
-		mutexInstrucciones.Unlock()
-
-		lineNumber++
-	}
-
-	// 7. Verificar si hubo errores durante el escaneo (distintos a EOF)
-	if err := scanner.Err(); err != nil {
-		log.Fatalf("Error al leer el archivo '%s': %v", rutaArchivo, err)
-	}
-
+	return nil
 }
 
 // --------- HANDLERS DEL CPU --------- //
 func AtenderHandshakeCPU(w http.ResponseWriter, r *http.Request) {
+	var handshake globales.HandshakeCPU
+	handshake = globales.DecodificarPaquete(w, r, &handshake)
+
+	if handshake.IP_CPU != "" {
+		mutexCpusConectadas.Lock()
+		cpusConectadas[fmt.Sprintf("%s:%d", handshake.IP_CPU, handshake.PORT_CPU)] = handshake
+		mutexCpusConectadas.Unlock()
+		slog.Debug(fmt.Sprintf("CPU %s conectada en %s:%d", handshake.ID_CPU, handshake.IP_CPU, handshake.PORT_CPU))
+	}
 
 	respuesta := globales.ParametrosMemoria{
 		CantidadEntradas: ClientConfig.ENTRIES_PER_PAGE,
@@ -200,7 +216,7 @@ func DevolverInstruccion(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	slog.Info(fmt.Sprintf("## PID %s - Obtener Instruccion: %s - Instruccion: %s", pidString, pcString, instruccion)) // log obligatorio
+	slog.Info(fmt.Sprintf("## PID %s - Obtener Instruccion: %s - Instruccion: %s", pidString, pcString, instruccion), "pid", paquete.PID, "pc", paquete.PC) // log obligatorio
 
 	mutexMetricasPorProceso.Lock()
 	metricas := MetricasPorProceso[paquete.PID]
@@ -212,6 +228,43 @@ func DevolverInstruccion(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(instruccion))
 }
 
+// Devuelve varias instrucciones consecutivas en un solo acceso, para la cache de instrucciones del CPU
+func DevolverInstrucciones(w http.ResponseWriter, r *http.Request) {
+	paquete := globales.PeticionInstrucciones{}
+	paquete = globales.DecodificarPaquete(w, r, &paquete)
+
+	delayDeMemoria()
+
+	instrucciones := make([]string, 0, paquete.CANTIDAD)
+	mutexInstrucciones.Lock()
+	for pc := paquete.PC; pc < paquete.PC+paquete.CANTIDAD; pc++ {
+		instruccion, existe := instruccionesProcesos[paquete.PID][pc]
+		if !existe {
+			break // se termino el programa
+		}
+		instrucciones = append(instrucciones, instruccion)
+	}
+	mutexInstrucciones.Unlock()
+
+	// solo se pidio la instruccion del PC; las siguientes son prefetch y puede que nunca se ejecuten
+	// (GOTO, EXIT, desalojo), asi que no cuentan como solicitadas ni van al log obligatorio
+	if len(instrucciones) > 0 {
+		slog.Info(fmt.Sprintf("## PID %d - Obtener Instruccion: %d - Instruccion: %s", paquete.PID, paquete.PC, instrucciones[0]), "pid", paquete.PID, "pc", paquete.PC) // log obligatorio
+
+		mutexMetricasPorProceso.Lock()
+		metricas := MetricasPorProceso[paquete.PID]
+		metricas.CANT_INSTRUCCIONES_SOLICITADAS += 1
+		MetricasPorProceso[paquete.PID] = metricas
+		mutexMetricasPorProceso.Unlock()
+	}
+	if len(instrucciones) > 1 {
+		slog.Debug(fmt.Sprintf("## PID %d - Prefetch de instrucciones %d a %d", paquete.PID, paquete.PC+1, paquete.PC+len(instrucciones)-1))
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(instrucciones)
+}
+
 func LeerDireccion(w http.ResponseWriter, r *http.Request) {
 	paquete := globales.LeerMemoria{}
 	paquete = globales.DecodificarPaquete(w, r, &paquete)
@@ -226,7 +279,7 @@ func LeerDireccion(w http.ResponseWriter, r *http.Request) {
 	}
 	mutexMemoria.Unlock()
 
-	slog.Info(fmt.Sprintf("## PID: %d - Lectura - Dir.Física: %d - Tamaño: %v", paquete.PID, paquete.DIRECCION, paquete.TAMANIO)) // log obligatorio
+	slog.Info(fmt.Sprintf("## PID: %d - Lectura - Dir.Física: %d - Tamaño: %v", paquete.PID, paquete.DIRECCION, paquete.TAMANIO), "pid", paquete.PID, "address", paquete.DIRECCION, "size", paquete.TAMANIO) // log obligatorio
 
 	mutexMetricasPorProceso.Lock()
 	metricas := MetricasPorProceso[paquete.PID]
@@ -245,13 +298,20 @@ func EscribirDireccion(w http.ResponseWriter, r *http.Request) {
 
 	informacion := []byte(paquete.DATOS)
 
+	if marcoSoloLectura(paquete.PID, paquete.DIRECCION/ClientConfig.PAGE_SIZE) {
+		slog.Error(fmt.Sprintf("## PID: %d - Escritura rechazada en pagina de solo lectura - Dir.Física: %d", paquete.PID, paquete.DIRECCION))
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("La pagina es de solo lectura."))
+		return
+	}
+
 	mutexMemoria.Lock()
 	for i := 0; i < len(informacion); i++ {
 		MemoriaDeUsuario[paquete.DIRECCION+i] = informacion[i]
 	}
 	mutexMemoria.Unlock()
 
-	slog.Info(fmt.Sprintf("## PID: %d - Escritura - Dir.Física: %d - Tamaño: %v", paquete.PID, paquete.DIRECCION, len(paquete.DATOS))) // log obligatorio
+	slog.Info(fmt.Sprintf("## PID: %d - Escritura - Dir.Física: %d - Tamaño: %v", paquete.PID, paquete.DIRECCION, len(paquete.DATOS)), "pid", paquete.PID, "address", paquete.DIRECCION, "size", len(paquete.DATOS)) // log obligatorio
 
 	mutexMetricasPorProceso.Lock()
 	metricas := MetricasPorProceso[paquete.PID]
@@ -267,7 +327,7 @@ func DumpearProceso(w http.ResponseWriter, r *http.Request) {
 	paquete := globales.PID{}
 	paquete = globales.DecodificarPaquete(w, r, &paquete)
 
-	slog.Info(fmt.Sprintf("## PID: %d - Memory Dump solicitado", paquete.NUMERO_PID)) // log obligatorio
+	slog.Info(fmt.Sprintf("## PID: %d - Memory Dump solicitado", paquete.NUMERO_PID), "pid", paquete.NUMERO_PID) // log obligatorio
 
 	delayDeMemoria()
 
@@ -276,6 +336,8 @@ func DumpearProceso(w http.ResponseWriter, r *http.Request) {
 		slog.Error(fmt.Sprintf("Error buscando el proceso, %v", err))
 	}
 
+	invalidarASIDEnCPUs(paquete.NUMERO_PID, "DUMP") // las CPUs bajan las paginas modificadas antes de leer la memoria
+
 	<-proceso.Suspendido
 	slog.Debug("CHANNEL SUSPENDIDO-DUMPEAR PROCESO (-1) ")
 
@@ -322,6 +384,14 @@ func InicializarProceso(w http.ResponseWriter, r *http.Request) {
 
 	delayDeMemoria()
 
+	// 0. Valido y cargo el archivo de pseudocodigo antes de reservar memoria
+	if err := LeerArchivoDePseudocodigo(peticion.RutaArchivoPseudocodigo, peticion.PID, peticion.Tamanio); err != nil {
+		slog.Error(fmt.Sprintf("## PID: %d - Pseudocodigo invalido:\n%s", peticion.PID, err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
 	// 1. Creo la tabla de paginas del proceso y la guardo.
 	TablaDePaginas := CrearTablaPaginas(1, ClientConfig.NUMBER_OF_LEVELS, ClientConfig.ENTRIES_PER_PAGE)
 
@@ -329,6 +399,10 @@ func InicializarProceso(w http.ResponseWriter, r *http.Request) {
 	asignado := ReservarMemoria(peticion.Tamanio, TablaDePaginas)
 
 	if !asignado {
+		mutexInstrucciones.Lock()
+		delete(instruccionesProcesos, peticion.PID)
+		mutexInstrucciones.Unlock()
+
 		w.WriteHeader(http.StatusInsufficientStorage)
 		w.Write([]byte("No se pudo asignar la memoria solicitada."))
 		return
@@ -350,10 +424,7 @@ func InicializarProceso(w http.ResponseWriter, r *http.Request) {
 	MetricasPorProceso[peticion.PID] = METRICAS_PROCESO{}
 	mutexMetricasPorProceso.Unlock()
 
-	slog.Info(fmt.Sprintf("## PID: %d - Proceso Creado - Tamaño: %d", peticion.PID, peticion.Tamanio)) // log obligatorio
-
-	// 4. Cargar el archivo de pseudocodigo
-	LeerArchivoDePseudocodigo(peticion.RutaArchivoPseudocodigo, peticion.PID)
+	slog.Info(fmt.Sprintf("## PID: %d - Proceso Creado - Tamaño: %d", peticion.PID, peticion.Tamanio), "pid", peticion.PID, "size", peticion.Tamanio) // log obligatorio
 
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("ok"))
@@ -367,6 +438,8 @@ func FinalizarProceso(w http.ResponseWriter, r *http.Request) {
 
 	delayDeMemoria()
 
+	invalidarASIDEnCPUs(paquete.NUMERO_PID, "FINALIZAR")
+
 	slog.Debug(fmt.Sprintf("Procesos en memoria al inicio de la funcion: %v", ProcesosEnMemoria))
 
 	for i, p := range ProcesosEnMemoria {
@@ -401,6 +474,27 @@ func FinalizarProceso(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("Proceso eliminado con exito."))
 }
 
+// Avisa a todas las CPUs que las entradas de TLB y cache del PID dejan de valer.
+// Es sincronico para que las paginas modificadas lleguen a memoria antes de leerla.
+func invalidarASIDEnCPUs(pid int, motivo string) {
+	mutexCpusConectadas.Lock()
+	cpus := make([]globales.HandshakeCPU, 0, len(cpusConectadas))
+	for _, cpu := range cpusConectadas {
+		cpus = append(cpus, cpu)
+	}
+	mutexCpusConectadas.Unlock()
+
+	invalidacion := globales.InvalidacionASID{
+		PID:    pid,
+		MOTIVO: motivo,
+	}
+	for _, cpu := range cpus {
+		if err := globales.Enviar(context.Background(), cpu.IP_CPU, cpu.PORT_CPU, "/memoria/invalidar_asid", &invalidacion); err != nil {
+			slog.Error(fmt.Sprintf("PID: %d - No se pudo invalidar el ASID en la CPU %s: %v", pid, cpu.ID_CPU, err))
+		}
+	}
+}
+
 func MostrarMetricasProceso(pid int) {
 	mutexMetricasPorProceso.Lock()
 	metricas, existe := MetricasPorProceso[pid]
@@ -409,7 +503,7 @@ func MostrarMetricasProceso(pid int) {
 		slog.Error(fmt.Sprintf("No existen métricas para el PID %d\n", pid))
 		return
 	}
-	slog.Info(fmt.Sprintf("## PID: %d - Proceso Destruido - Métricas - Acc.T.Pag: %d; Inst.Sol.: %d; SWAP: %d; Mem.Prin.: %d; Lec.Mem.: %d; Esc.Mem.: %d", pid, metricas.CANT_ACCESOS_TABLA_DE_PAGINAS, metricas.CANT_INSTRUCCIONES_SOLICITADAS, metricas.CANT_BAJADAS_A_SWAP, metricas.CANT_SUBIDAS_A_MEMORIA, metricas.CANT_LECTURAS_MEMORIA, metricas.CANT_ESCRITURAS_MEMORIA))
+	slog.Info(fmt.Sprintf("## PID: %d - Proceso Destruido - Métricas - Acc.T.Pag: %d; Inst.Sol.: %d; SWAP: %d; Mem.Prin.: %d; Lec.Mem.: %d; Esc.Mem.: %d", pid, metricas.CANT_ACCESOS_TABLA_DE_PAGINAS, metricas.CANT_INSTRUCCIONES_SOLICITADAS, metricas.CANT_BAJADAS_A_SWAP, metricas.CANT_SUBIDAS_A_MEMORIA, metricas.CANT_LECTURAS_MEMORIA, metricas.CANT_ESCRITURAS_MEMORIA), "pid", pid) // log obligatorio
 }
 
 func ObtenerMarco(w http.ResponseWriter, r *http.Request) {
@@ -419,9 +513,12 @@ func ObtenerMarco(w http.ResponseWriter, r *http.Request) {
 	// Obtener el marco de memoria correspondiente
 	mutexProcesosEnMemoria.Lock()
 	var marco int = -1
+	var soloLectura bool
 	for _, proceso := range ProcesosEnMemoria {
 		if proceso.PID == paquete.PID {
 			marco = ObtenerMarcoDeTDP(paquete.PID, proceso.TablaPaginas, paquete.Entradas_Nivel_X, 1)
+			hoja, indice := hojaDeTDP(proceso.TablaPaginas, paquete.Entradas_Nivel_X)
+			soloLectura = hoja.SoloLectura[indice]
 			break
 		}
 	}
@@ -433,9 +530,96 @@ func ObtenerMarco(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	slog.Debug(fmt.Sprintf("PID: %d - Marco obtenido: %d", paquete.PID, marco))
+	slog.Debug(fmt.Sprintf("PID: %d - Marco obtenido: %d, solo lectura: %t", paquete.PID, marco, soloLectura))
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(strconv.Itoa(marco)))
+	json.NewEncoder(w).Encode(globales.MarcoObtenido{
+		NUMERO_MARCO: marco,
+		SOLO_LECTURA: soloLectura,
+	})
+}
+
+// --------- PROTECCION DE PAGINAS --------- //
+// Devuelve la tabla de ultimo nivel y el indice de la pagina, sin contar el acceso en las metricas
+func hojaDeTDP(TDP *NodoTablaPaginas, entrada_nivel_X []int) (*NodoTablaPaginas, int) {
+	for level := 1; level < ClientConfig.NUMBER_OF_LEVELS; level++ {
+		TDP = TDP.Children[entrada_nivel_X[level-1]]
+	}
+	return TDP, entrada_nivel_X[ClientConfig.NUMBER_OF_LEVELS-1]
+}
+
+// Mismo calculo que la MMU de la CPU
+func entradasDePagina(nroPagina int) []int {
+	entrada_nivel_X := make([]int, ClientConfig.NUMBER_OF_LEVELS)
+	for x := 1; x <= ClientConfig.NUMBER_OF_LEVELS; x++ {
+		divisor := int(math.Pow(float64(ClientConfig.ENTRIES_PER_PAGE), float64(ClientConfig.NUMBER_OF_LEVELS-x)))
+		entrada_nivel_X[x-1] = (nroPagina / divisor) % ClientConfig.ENTRIES_PER_PAGE
+	}
+	return entrada_nivel_X
+}
+
+// Busca en la tabla del proceso la pagina cargada en el marco
+func marcoSoloLectura(pid int, marco int) bool {
+	mutexProcesosEnMemoria.Lock()
+	defer mutexProcesosEnMemoria.Unlock()
+
+	proceso, err := ObtenerProceso(pid)
+	if err != nil {
+		return false
+	}
+	return buscarProteccionDeMarco(proceso.TablaPaginas, 1, marco)
+}
+
+func buscarProteccionDeMarco(node *NodoTablaPaginas, level int, marco int) bool {
+	if level == ClientConfig.NUMBER_OF_LEVELS {
+		for i, m := range node.Marcos {
+			if m != nil && *m == marco {
+				return node.SoloLectura[i]
+			}
+		}
+		return false
+	}
+	for _, hijo := range node.Children {
+		if buscarProteccionDeMarco(hijo, level+1, marco) {
+			return true
+		}
+	}
+	return false
+}
+
+func MProteger(w http.ResponseWriter, r *http.Request) {
+	paquete := globales.SolicitudMProtect{}
+	paquete = globales.DecodificarPaquete(w, r, &paquete)
+
+	delayDeMemoria()
+
+	// las CPUs bajan las paginas modificadas antes de que cambie la proteccion y olvidan la anterior
+	invalidarASIDEnCPUs(paquete.PID, "MPROTECT")
+
+	primeraPagina := paquete.DIRECCION / ClientConfig.PAGE_SIZE
+	ultimaPagina := (paquete.DIRECCION + max(paquete.TAMANIO, 1) - 1) / ClientConfig.PAGE_SIZE
+
+	mutexProcesosEnMemoria.Lock()
+	proceso, err := ObtenerProceso(paquete.PID)
+	if err != nil {
+		mutexProcesosEnMemoria.Unlock()
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("No se encontro el proceso solicitado."))
+		return
+	}
+	for nroPagina := primeraPagina; nroPagina <= ultimaPagina; nroPagina++ {
+		hoja, indice := hojaDeTDP(proceso.TablaPaginas, entradasDePagina(nroPagina))
+		hoja.SoloLectura[indice] = paquete.SOLO_LECTURA
+	}
+	mutexProcesosEnMemoria.Unlock()
+
+	proteccion := "RW"
+	if paquete.SOLO_LECTURA {
+		proteccion = "RO"
+	}
+	slog.Info(fmt.Sprintf("## PID: %d - MPROTECT - Paginas: %d-%d - %s", paquete.PID, primeraPagina, ultimaPagina, proteccion))
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
 }
 
 func remove(s []*Proceso, i int) []*Proceso {
@@ -454,6 +638,7 @@ func CrearTablaPaginas(semilla, numNiveles, entradasPorPagina int) *NodoTablaPag
 	} else { // si es la de ultimo nivel
 		nodo.Children = nil
 		nodo.Marcos = make([]*int, entradasPorPagina)
+		nodo.SoloLectura = make([]bool, entradasPorPagina)
 	}
 	return nodo
 }
@@ -602,6 +787,13 @@ func EscribirPaginaCompleta(w http.ResponseWriter, r *http.Request) {
 
 	delayDeMemoria()
 
+	if marcoSoloLectura(paquete.PID, paquete.DIRECCION/ClientConfig.PAGE_SIZE) {
+		slog.Error(fmt.Sprintf("## PID: %d - Escritura rechazada en pagina de solo lectura - Dir.Física: %d", paquete.PID, paquete.DIRECCION))
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("La pagina es de solo lectura."))
+		return
+	}
+
 	mutexMemoria.Lock()
 	for i := 0; i < len(paquete.DATOS); i++ {
 		MemoriaDeUsuario[paquete.DIRECCION+i] = paquete.DATOS[i]
@@ -691,6 +883,7 @@ func SuspenderProceso(w http.ResponseWriter, r *http.Request) {
 	paquete := globales.PID{}
 	paquete = globales.DecodificarPaquete(w, r, &paquete)
 	slog.Debug(fmt.Sprintf("Proceso a swapear: %d", paquete.NUMERO_PID))
+	invalidarASIDEnCPUs(paquete.NUMERO_PID, "SWAP") // las CPUs bajan las paginas modificadas y olvidan los marcos
 	procesoMemoria, err := ObtenerProceso(paquete.NUMERO_PID)
 	<-procesoMemoria.Suspendido
 	slog.Debug("channel suspendido (suspender - 1)")
@@ -0,0 +1,3 @@
+module reproductor
+
+go 1.24
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"globales/traza"
+)
+
+// Vuelve a correr una traza grabada por la CPU contra uno o mas configs de CPU, sin levantar los modulos.
+// Uso: reproductor -traza cpu-1.trace config...
+func main() {
+	rutaTraza := flag.String("traza", "", "archivo de traza grabado por la CPU (TRACE_DIR)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Uso: reproductor -traza archivo.trace config.json...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *rutaTraza == "" || flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	tamanioPagina, eventos, err := traza.LeerArchivo(*rutaTraza)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	tabla := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tabla, "config\taccesos\tTLB hits\tTLB misses\tcache hits\tcache misses\tpedidos marco\tdesalojos sucios\tescrituras pagina\tescrituras direccion\tbytes escritos\t")
+	imprimirFila(tabla, "(grabada)", traza.EstadisticasGrabadas(eventos))
+
+	for _, rutaConfig := range flag.Args() {
+		config, err := leerConfiguracion(rutaConfig)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		imprimirFila(tabla, filepath.Base(rutaConfig), traza.Simular(tamanioPagina, eventos, config))
+	}
+	tabla.Flush()
+}
+
+func leerConfiguracion(ruta string) (traza.Configuracion, error) {
+	config := traza.ConfiguracionPorDefecto()
+	archivo, err := os.Open(ruta)
+	if err != nil {
+		return config, fmt.Errorf("no se pudo abrir el config '%s': %w", ruta, err)
+	}
+	defer archivo.Close()
+
+	if err := json.NewDecoder(archivo).Decode(&config); err != nil {
+		return config, fmt.Errorf("config '%s' invalido: %w", ruta, err)
+	}
+	return config, nil
+}
+
+func imprimirFila(tabla *tabwriter.Writer, nombre string, e traza.Estadisticas) {
+	fmt.Fprintf(tabla, "%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t\n", nombre, e.ACCESOS, e.TLB_HITS, e.TLB_MISSES,
+		e.CACHE_HITS, e.CACHE_MISSES, e.PEDIDOS_MARCO, e.DESALOJOS_SUCIOS, e.ESCRITURAS_PAGINA, e.ESCRITURAS_DIRECCION, e.BYTES_ESCRITOS_MEMORIA)
+}
@@ -0,0 +1,3 @@
+module validador
+
+go 1.24
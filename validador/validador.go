@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"globales/pseudocodigo"
+)
+
+// Valida archivos de pseudocodigo sin levantar los modulos.
+// Uso: validador [-tamanio N] archivo...
+func main() {
+	tamanio := flag.Int("tamanio", 0, "tamaño del proceso para validar las direcciones (0 = no validar)")
+	mostrar := flag.Bool("mostrar", false, "imprime las instrucciones ya ensambladas")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Uso: validador [-tamanio N] [-mostrar] archivo...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	hayErrores := false
+	for _, ruta := range flag.Args() {
+		instrucciones, err := pseudocodigo.EnsamblarArchivo(ruta, pseudocodigo.Opciones{TamanioProceso: *tamanio})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			hayErrores = true
+			continue
+		}
+
+		fmt.Printf("%s: OK (%d instrucciones)\n", ruta, len(instrucciones))
+		if *mostrar {
+			for pc, instruccion := range instrucciones {
+				fmt.Printf("%4d  %s\n", pc, instruccion)
+			}
+		}
+	}
+
+	if hayErrores {
+		os.Exit(1)
+	}
+}